@@ -0,0 +1,418 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Name of the cookie holding the signed session
+const sessionCookieName = "leetgo_session"
+
+// How long a session stays valid after login
+const sessionDuration = 7 * 24 * time.Hour
+
+// Shortest password accepted at registration
+const minPasswordLength = 8
+
+// Longest password accepted at registration, in bytes, as bcrypt hashes no more
+const maxPasswordLength = 72
+
+// Hash compared against when logging in as an unknown user, so that the
+// response takes as long as for a known one and doesn't reveal which exist
+const dummyPasswordHash = "$2a$10$hcxAROnzNrxpd1YKqFBMWOKotsMuRZHsw8/HgvadvXv3GGV9OW1qO"
+
+// errUserTaken reports that the username or email of a new user is already registered
+var errUserTaken = errors.New("username or email already registered")
+
+type contextKey string
+
+// Request context key under which AuthMiddleware stores the current user
+const userContextKey contextKey = "user"
+
+var (
+	sessionSecretOnce sync.Once
+	sessionSecret     []byte
+)
+
+// Return the key used to sign session cookies, the configured session secret.
+// Without one a random key is generated, so sessions don't survive a restart.
+func getSessionSecret() []byte {
+	sessionSecretOnce.Do(func() {
+		if secret := serverConfig.SessionSecret; secret != "" {
+			sessionSecret = []byte(secret)
+			return
+		}
+		log.Printf("SESSION_SECRET not set, generating a random session key")
+		sessionSecret = make([]byte, 32)
+		if _, err := rand.Read(sessionSecret); err != nil {
+			log.Fatalf("Failed to generate session key: %v", err)
+		}
+	})
+	return sessionSecret
+}
+
+// Handle a registration request, logging the new user in
+func RegisterUser(store Store, w http.ResponseWriter, r *http.Request) {
+	var credentials Credentials
+	if err := decodeRequest(r, &credentials); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	credentials.Username = strings.TrimSpace(credentials.Username)
+	credentials.Email = strings.TrimSpace(credentials.Email)
+
+	if credentials.Username == "" {
+		respondWithError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+	if len(credentials.Password) < minPasswordLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+	if len(credentials.Password) > maxPasswordLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at most %d bytes", maxPasswordLength))
+		return
+	}
+
+	taken, err := store.UserExists(credentials.Username, credentials.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if taken {
+		respondWithError(w, http.StatusConflict, "Username or email already registered")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		log.Printf("Password hashing error: %v", err)
+		return
+	}
+
+	// Another registration may have taken the name since it was checked
+	id, err := store.CreateUser(credentials.Username, credentials.Email, string(hash))
+	if errors.Is(err, errUserTaken) {
+		respondWithError(w, http.StatusConflict, "Username or email already registered")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to register user")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	user := User{ID: id, Username: credentials.Username, Email: credentials.Email}
+	setSessionCookie(w, r, user)
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+// Handle a login request, setting the session cookie on success
+func LoginUser(store Store, w http.ResponseWriter, r *http.Request) {
+	var credentials Credentials
+	if err := decodeRequest(r, &credentials); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	user, hash, err := store.GetUserByUsername(strings.TrimSpace(credentials.Username))
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(credentials.Password))
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(credentials.Password)) != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	setSessionCookie(w, r, user)
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// Handle a logout request by expiring the session cookie and revoking the
+// user's sessions, so copies of the cookie stop working too
+func LogoutUser(store Store, w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	if user, ok := CurrentUser(r); ok {
+		if err := store.RevokeSessions(user.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+			log.Printf("Database error: %v", err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Return the logged in user
+func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := CurrentUser(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Not logged in")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// Middleware attaching the user of a valid, unrevoked session cookie to the
+// request context. Requests without one pass through anonymously.
+func AuthMiddleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(sessionCookieName)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			userID, version, ok := verifySession(cookie.Value, time.Now())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := store.GetUserByID(userID)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					log.Printf("Database error: %v", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if user.SessionVersion != version {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+		})
+	}
+}
+
+// Return the user attached to the request by AuthMiddleware
+func CurrentUser(r *http.Request) (User, bool) {
+	user, ok := r.Context().Value(userContextKey).(User)
+	return user, ok
+}
+
+// Wrap a handler so that only admins may call it
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := CurrentUser(r)
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "Not logged in")
+			return
+		}
+		if !user.IsAdmin {
+			respondWithError(w, http.StatusForbidden, "Admin access required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Hash any passwords still stored in plaintext
+func MigratePasswords(store Store) error {
+	passwords, err := store.ListPasswords()
+	if err != nil {
+		return err
+	}
+
+	hashed := 0
+	for id, password := range passwords {
+		if _, err := bcrypt.Cost([]byte(password)); err == nil {
+			continue
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if err := store.SetPassword(id, string(hash)); err != nil {
+			return err
+		}
+		hashed++
+	}
+	if hashed > 0 {
+		log.Printf("Hashed %d plaintext passwords", hashed)
+	}
+	return nil
+}
+
+// Set a cookie holding a signed session for the user
+func setSessionCookie(w http.ResponseWriter, r *http.Request, user User) {
+	expires := time.Now().Add(sessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    signSession(user.ID, user.SessionVersion, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Report whether the client made the request over HTTPS, either to the server
+// itself or to a proxy in front of it such as fly.io's, which says so in
+// X-Forwarded-Proto
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// Encode a session as "<user id>.<session version>.<expiry>.<signature>"
+func signSession(userID, version int, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d.%d", userID, version, expires.Unix())
+	return payload + "." + sessionSignature(payload)
+}
+
+// Return the user ID and session version of a session that is correctly signed
+// and not yet expired
+func verifySession(value string, now time.Time) (int, int, bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return 0, 0, false
+	}
+	payload, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(sessionSignature(payload))) {
+		return 0, 0, false
+	}
+
+	fields := strings.Split(payload, ".")
+	if len(fields) != 3 {
+		return 0, 0, false
+	}
+	userID, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return 0, 0, false
+	}
+	return userID, version, true
+}
+
+// Sign a session payload with the session key
+func sessionSignature(payload string) string {
+	mac := hmac.New(sha256.New, getSessionSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Insert a user and return their ID, or errUserTaken if the username or email
+// is already registered. An empty email is stored as NULL.
+func (s *sqlStore) CreateUser(username, email, passwordHash string) (int, error) {
+	id, err := s.insert(s.db, `INSERT INTO users (username, email, password) VALUES (?, ?, ?)`,
+		username, nullString(email), passwordHash)
+	if isUniqueViolation(err) {
+		return 0, errUserTaken
+	}
+	return int(id), err
+}
+
+// Report whether a user with the given username or email already exists
+func (s *sqlStore) UserExists(username, email string) (bool, error) {
+	var count int
+	err := s.queryRow(s.db, `SELECT COUNT(*) FROM users WHERE username = ? OR (email IS NOT NULL AND email = ?)`,
+		username, email).Scan(&count)
+	return count > 0, err
+}
+
+// Fetch a user by ID
+func (s *sqlStore) GetUserByID(id int) (User, error) {
+	var user User
+	var email sql.NullString
+	err := s.queryRow(s.db, `SELECT id, username, email, is_admin, session_version FROM users WHERE id = ?`, id).
+		Scan(&user.ID, &user.Username, &email, &user.IsAdmin, &user.SessionVersion)
+	user.Email = email.String
+	return user, err
+}
+
+// Fetch a user and their password hash by username
+func (s *sqlStore) GetUserByUsername(username string) (User, string, error) {
+	var user User
+	var email sql.NullString
+	var hash string
+	err := s.queryRow(s.db, `SELECT id, username, email, is_admin, session_version, password FROM users WHERE username = ?`,
+		username).Scan(&user.ID, &user.Username, &email, &user.IsAdmin, &user.SessionVersion, &hash)
+	user.Email = email.String
+	return user, hash, err
+}
+
+// Give an existing user admin access
+func (s *sqlStore) GrantAdmin(username string) error {
+	result, err := s.exec(s.db, `UPDATE users SET is_admin = ? WHERE username = ?`, true, username)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return fmt.Errorf("no user named %q", username)
+	}
+	return nil
+}
+
+// Revoke every session of a user by moving their session version on
+func (s *sqlStore) RevokeSessions(userID int) error {
+	result, err := s.exec(s.db, `UPDATE users SET session_version = session_version + 1 WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// Map every user's ID to their stored password
+func (s *sqlStore) ListPasswords() (map[int]string, error) {
+	rows, err := s.query(s.db, `SELECT id, password FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passwords := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var password string
+		if err := rows.Scan(&id, &password); err != nil {
+			return nil, err
+		}
+		passwords[id] = password
+	}
+	return passwords, rows.Err()
+}
+
+// Replace a user's stored password hash
+func (s *sqlStore) SetPassword(id int, passwordHash string) error {
+	_, err := s.exec(s.db, `UPDATE users SET password = ? WHERE id = ?`, passwordHash, id)
+	return err
+}
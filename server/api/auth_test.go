@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegisterUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "SuccessfulRegistration",
+			body: `{"username": "alice", "email": "alice@example.com", "password": "correct horse"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM users").WithArgs("alice", "alice@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectExec("^INSERT INTO users").WithArgs("alice", "alice@example.com", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":2,"username":"alice","email":"alice@example.com"}`,
+		},
+		{
+			name: "UsernameTaken",
+			body: `{"username": "alice", "password": "correct horse"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM users").WithArgs("alice", "").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Username or email already registered"}`,
+		},
+		{
+			name: "UsernameTakenMeanwhile",
+			body: `{"username": "alice", "password": "correct horse"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM users").WithArgs("alice", "").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectExec("^INSERT INTO users").WithArgs("alice", nil, sqlmock.AnyArg()).
+					WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique})
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Username or email already registered"}`,
+		},
+		{
+			name:         "LongPassword",
+			body:         `{"username": "alice", "password": "` + strings.Repeat("a", 73) + `"}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Password must be at most 72 bytes"}`,
+		},
+		{
+			name:         "ShortPassword",
+			body:         `{"username": "alice", "password": "short"}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Password must be at least 8 characters"}`,
+		},
+		{
+			name:         "MissingUsername",
+			body:         `{"password": "correct horse"}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Username is required"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(tt.body))
+
+			RegisterUser(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+			equals(t, tt.expectedCode == http.StatusCreated, sessionCookie(rec) != nil)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	ok(t, err)
+
+	tests := []struct {
+		name         string
+		body         string
+		rows         *sqlmock.Rows
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "SuccessfulLogin",
+			body:         `{"username": "alice", "password": "correct horse"}`,
+			rows:         sqlmock.NewRows([]string{"id", "username", "email", "is_admin", "session_version", "password"}).AddRow(2, "alice", nil, false, 0, string(hash)),
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":2,"username":"alice"}`,
+		},
+		{
+			name:         "WrongPassword",
+			body:         `{"username": "alice", "password": "battery staple"}`,
+			rows:         sqlmock.NewRows([]string{"id", "username", "email", "is_admin", "session_version", "password"}).AddRow(2, "alice", nil, false, 0, string(hash)),
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":"Invalid username or password"}`,
+		},
+		{
+			name:         "UnknownUser",
+			body:         `{"username": "bob", "password": "correct horse"}`,
+			rows:         sqlmock.NewRows([]string{"id", "username", "email", "is_admin", "session_version", "password"}),
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":"Invalid username or password"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("^SELECT id, username, email, is_admin, session_version, password FROM users WHERE username = \\?$").WillReturnRows(tt.rows)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(tt.body))
+			req.Header.Set("X-Forwarded-Proto", "https")
+
+			LoginUser(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+			cookie := sessionCookie(rec)
+			equals(t, tt.expectedCode == http.StatusOK, cookie != nil)
+			// The request reached the proxy in front of the server over HTTPS
+			assert(t, cookie == nil || cookie.Secure, "expected a secure session cookie, got %+v", cookie)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	// Logging in as an unknown user must cost as much as a known one
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	ok(t, err)
+	equals(t, bcrypt.DefaultCost, cost)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	valid := signSession(2, 3, time.Now().Add(time.Hour))
+	expired := signSession(2, 3, time.Now().Add(-time.Hour))
+	revoked := signSession(2, 2, time.Now().Add(time.Hour))
+	tampered := strings.Replace(valid, "2.", "1.", 1)
+
+	tests := []struct {
+		name         string
+		cookie       string
+		expectQuery  bool
+		expectedCode int
+		expectedBody string
+	}{
+		{"ValidSession", valid, true, http.StatusOK, `{"id":2,"username":"alice"}`},
+		{"NoSession", "", false, http.StatusUnauthorized, `{"error":"Not logged in"}`},
+		{"ExpiredSession", expired, false, http.StatusUnauthorized, `{"error":"Not logged in"}`},
+		{"RevokedSession", revoked, true, http.StatusUnauthorized, `{"error":"Not logged in"}`},
+		{"TamperedSession", tampered, false, http.StatusUnauthorized, `{"error":"Not logged in"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			if tt.expectQuery {
+				mock.ExpectQuery("^SELECT id, username, email, is_admin, session_version FROM users WHERE id = \\?$").WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "is_admin", "session_version"}).AddRow(2, "alice", nil, false, 3))
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+			}
+
+			AuthMiddleware(NewSQLiteStore(db))(http.HandlerFunc(GetCurrentUser)).ServeHTTP(rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         *User
+		revokeErr    error
+		expectedCode int
+	}{
+		{"Anonymous", nil, nil, http.StatusNoContent},
+		{"LoggedIn", &User{ID: 2, Username: "alice"}, nil, http.StatusNoContent},
+		{"RevokeError", &User{ID: 2, Username: "alice"}, errors.New("database is locked"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			// Logging out revokes every session of the user, including copies of the cookie
+			if tt.user != nil {
+				expect := mock.ExpectExec("^UPDATE users SET session_version = session_version \\+ 1 WHERE id = \\?$").WithArgs(tt.user.ID)
+				if tt.revokeErr != nil {
+					expect.WillReturnError(tt.revokeErr)
+				} else {
+					expect.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/auth/logout", bytes.NewReader(nil))
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey, *tt.user))
+			}
+
+			LogoutUser(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			cookie := sessionCookie(rec)
+			assert(t, cookie != nil && cookie.MaxAge < 0, "expected the session cookie to be expired, got %+v", cookie)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigratePasswords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("already hashed"), bcrypt.MinCost)
+	ok(t, err)
+
+	mock.ExpectQuery("^SELECT id, password FROM users$").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, "123456").AddRow(2, string(hash)))
+	mock.ExpectExec("^UPDATE users SET password = \\? WHERE id = \\?$").WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok(t, MigratePasswords(NewSQLiteStore(db)))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// Return the session cookie set on the response, if any
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
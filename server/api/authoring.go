@@ -0,0 +1,476 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// errNotFound reports that the problem or test case being changed doesn't exist
+var errNotFound = errors.New("not found")
+
+// errHasSubmissions reports that a problem can't be deleted, since users have
+// submitted solutions to it
+var errHasSubmissions = errors.New("problem has submissions")
+
+// Handle a request creating a problem together with its test cases
+func CreateProblem(store Store, w http.ResponseWriter, r *http.Request) {
+	var def ProblemDefinition
+	if err := decodeRequest(r, &def); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := validateDefinition(&def); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !verifyReferenceSolution(w, def, def.Tests) {
+		return
+	}
+
+	if err := store.CreateProblem(&def); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create problem")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, def)
+}
+
+// Handle a request replacing a problem's definition. Tests in the request
+// replace the existing ones; without them the existing tests must still match.
+func UpdateProblem(store Store, w http.ResponseWriter, r *http.Request) {
+	var def ProblemDefinition
+	if err := decodeRequest(r, &def); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Problem not found")
+		return
+	}
+	def.ID = id
+
+	replaceTests := def.Tests != nil
+	if !replaceTests {
+		if def.Tests, err = GetProblemExamplesWrapper(store, strconv.FormatInt(id, 10)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update problem")
+			log.Printf("Database error: %v", err)
+			return
+		}
+	}
+	if err := validateDefinition(&def); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !verifyReferenceSolution(w, def, def.Tests) {
+		return
+	}
+
+	err = store.UpdateProblem(def, replaceTests)
+	if errors.Is(err, errNotFound) {
+		respondWithError(w, http.StatusNotFound, "Problem not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update problem")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, def)
+}
+
+// Handle a request deleting a problem along with its tests and images. Problems
+// with submissions are kept, so users' history isn't lost.
+func DeleteProblem(store Store, w http.ResponseWriter, r *http.Request) {
+	err := store.DeleteProblem(mux.Vars(r)["id"])
+	if errors.Is(err, errNotFound) {
+		respondWithError(w, http.StatusNotFound, "Problem not found")
+		return
+	}
+	if errors.Is(err, errHasSubmissions) {
+		respondWithError(w, http.StatusConflict, "Problem has submissions and can't be deleted")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete problem")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Fetch every test case of a problem, including hidden ones
+func GetProblemTests(store Store, w http.ResponseWriter, r *http.Request) {
+	tests, err := GetProblemExamplesWrapper(store, mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve test cases")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	if tests == nil {
+		tests = []ProblemExample{}
+	}
+	respondWithJSON(w, http.StatusOK, tests)
+}
+
+// Handle a request adding a test case to a problem
+func CreateProblemTest(store Store, w http.ResponseWriter, r *http.Request) {
+	test, ok := decodeTestCase(store, w, r)
+	if !ok {
+		return
+	}
+
+	if err := store.CreateProblemExample(&test); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create test case")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, test)
+}
+
+// Handle a request replacing one of a problem's test cases
+func UpdateProblemTest(store Store, w http.ResponseWriter, r *http.Request) {
+	test, ok := decodeTestCase(store, w, r)
+	if !ok {
+		return
+	}
+	testID, err := strconv.Atoi(mux.Vars(r)["testId"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Test case not found")
+		return
+	}
+	test.ID = testID
+
+	err = store.UpdateProblemExample(test)
+	if errors.Is(err, errNotFound) {
+		respondWithError(w, http.StatusNotFound, "Test case not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update test case")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, test)
+}
+
+// Handle a request deleting one of a problem's test cases
+func DeleteProblemTest(store Store, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := store.DeleteProblemExample(vars["id"], vars["testId"])
+	if errors.Is(err, errNotFound) {
+		respondWithError(w, http.StatusNotFound, "Test case not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete test case")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Validate a problem definition and each of its tests
+func validateDefinition(def *ProblemDefinition) error {
+	sig, err := validateProblem(def)
+	if err != nil {
+		return fmt.Errorf("Invalid problem: %v", err)
+	}
+	for i := range def.Tests {
+		if def.Tests[i].InputOrder == "" && def.ProblemType == ProblemTypeDesign {
+			def.Tests[i].InputOrder = "[]"
+		}
+		if err := validateTestCase(sig, def.Tests[i]); err != nil {
+			return fmt.Errorf("Invalid test %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Decode a test case from the request and validate it against its problem and
+// reference solution, responding with an error if any of them fails
+func decodeTestCase(store Store, w http.ResponseWriter, r *http.Request) (ProblemExample, bool) {
+	var test ProblemExample
+	if err := decodeRequest(r, &test); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request")
+		return test, false
+	}
+	problemID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Problem not found")
+		return test, false
+	}
+	test.PromblemID = problemID
+
+	def, err := store.GetProblemDefinition(problemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Problem not found")
+		return test, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve problem")
+		log.Printf("Database error: %v", err)
+		return test, false
+	}
+
+	sig, err := validateProblem(&def)
+	if err != nil {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Stored problem is invalid: %v", err))
+		return test, false
+	}
+	if test.InputOrder == "" && def.ProblemType == ProblemTypeDesign {
+		test.InputOrder = "[]"
+	}
+	if err := validateTestCase(sig, test); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid test: %v", err))
+		return test, false
+	}
+	return test, verifyReferenceSolution(w, def, []ProblemExample{test})
+}
+
+// Fetch the stored definition of a problem, without its tests
+func (s *sqlStore) GetProblemDefinition(id int) (ProblemDefinition, error) {
+	var def ProblemDefinition
+	err := s.queryRow(s.db, `
+		SELECT
+			id,
+			name,
+			COALESCE(short_description, ''),
+			COALESCE(long_description, ''),
+			COALESCE(difficulty, ''),
+			COALESCE(problem_seed, ''),
+			COALESCE(examples, ''),
+			COALESCE(problem_type, 'function'),
+			COALESCE(compare_mode, 'exact'),
+			COALESCE(compare_tolerance, 0),
+			COALESCE(checker_code, ''),
+			COALESCE(test_file, ''),
+			COALESCE(reference_solution, '')
+		FROM problems
+		WHERE id = ?`, id).Scan(
+		&def.ID,
+		&def.Name,
+		&def.ShortDescription,
+		&def.LongDescription,
+		&def.Difficulty,
+		&def.ProblemSeed,
+		&def.Examples,
+		&def.ProblemType,
+		&def.CompareMode,
+		&def.Tolerance,
+		&def.Checker,
+		&def.TestFile,
+		&def.ReferenceSolution,
+	)
+	return def, err
+}
+
+// Insert a problem and its tests, setting their IDs
+func (s *sqlStore) CreateProblem(def *ProblemDefinition) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		return s.insertProblemDefinition(tx, def)
+	})
+}
+
+// Replace the stored definition of a problem, and its tests if replaceTests is set
+func (s *sqlStore) UpdateProblem(def ProblemDefinition, replaceTests bool) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		return s.replaceProblemDefinition(tx, def, replaceTests)
+	})
+}
+
+// Delete a problem along with its tests and images, or return errHasSubmissions
+// if it has any submissions
+func (s *sqlStore) DeleteProblem(id string) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var submissions int
+		if err := s.queryRow(tx, `SELECT COUNT(*) FROM user_solutions WHERE problem_id = ?`, id).Scan(&submissions); err != nil {
+			return err
+		}
+		if submissions > 0 {
+			return errHasSubmissions
+		}
+
+		for _, table := range []string{"problem_examples", "problem_images"} {
+			if _, err := s.exec(tx, `DELETE FROM `+table+` WHERE problem_id = ?`, id); err != nil {
+				return err
+			}
+		}
+		result, err := s.exec(tx, `DELETE FROM problems WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+		return requireRow(result)
+	})
+}
+
+// Create or replace problems and their tests in a single transaction, matching
+// them to stored problems by name. Sets the IDs of the problems.
+func (s *sqlStore) SaveProblemsByName(defs []ProblemDefinition) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		for i := range defs {
+			err := s.queryRow(tx, `SELECT id FROM problems WHERE name = ?`, defs[i].Name).Scan(&defs[i].ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				if err := s.insertProblemDefinition(tx, &defs[i]); err != nil {
+					return fmt.Errorf("%s: %w", defs[i].Name, err)
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.replaceProblemDefinition(tx, defs[i], true); err != nil {
+				return fmt.Errorf("%s: %w", defs[i].Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Add a test case to its problem, setting its ID
+func (s *sqlStore) CreateProblemExample(test *ProblemExample) error {
+	tests := []ProblemExample{*test}
+	err := withTx(s.db, func(tx *sql.Tx) error {
+		return s.insertTests(tx, int64(test.PromblemID), tests)
+	})
+	*test = tests[0]
+	return err
+}
+
+// Replace a test case, errNotFound if its problem has no such test
+func (s *sqlStore) UpdateProblemExample(test ProblemExample) error {
+	result, err := s.exec(s.db, `
+		UPDATE problem_examples SET input = ?, input_order = ?, expected_output = ?, is_hidden = ?, explanation = ?
+		WHERE id = ? AND problem_id = ?`,
+		test.Input, test.InputOrder, test.ExpectedOutput, test.IsHidden, nullString(test.Explanation), test.ID, test.PromblemID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// Delete a test case, errNotFound if its problem has no such test
+func (s *sqlStore) DeleteProblemExample(problemID, testID string) error {
+	result, err := s.exec(s.db, `DELETE FROM problem_examples WHERE id = ? AND problem_id = ?`, testID, problemID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// Insert a problem and its tests, setting their IDs
+func (s *sqlStore) insertProblemDefinition(tx *sql.Tx, def *ProblemDefinition) error {
+	id, err := s.insert(tx, `
+		INSERT INTO problems
+			(name, short_description, long_description, problem_seed, examples, difficulty,
+			 problem_type, compare_mode, compare_tolerance, checker_code, test_file, reference_solution)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, definitionArgs(*def)...)
+	if err != nil {
+		return err
+	}
+	def.ID = id
+	return s.insertTests(tx, def.ID, def.Tests)
+}
+
+// Replace the stored definition of a problem, and its tests if replaceTests is set.
+// Attempt and solve counters are kept.
+func (s *sqlStore) replaceProblemDefinition(tx *sql.Tx, def ProblemDefinition, replaceTests bool) error {
+	result, err := s.exec(tx, `
+		UPDATE problems SET
+			name = ?, short_description = ?, long_description = ?, problem_seed = ?, examples = ?, difficulty = ?,
+			problem_type = ?, compare_mode = ?, compare_tolerance = ?, checker_code = ?, test_file = ?,
+			reference_solution = ?
+		WHERE id = ?`, append(definitionArgs(def), def.ID)...)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
+	if !replaceTests {
+		return nil
+	}
+	if _, err := s.exec(tx, `DELETE FROM problem_examples WHERE problem_id = ?`, def.ID); err != nil {
+		return err
+	}
+	return s.insertTests(tx, def.ID, def.Tests)
+}
+
+// Insert test cases for a problem, setting their IDs
+func (s *sqlStore) insertTests(tx *sql.Tx, problemID int64, tests []ProblemExample) error {
+	for i := range tests {
+		id, err := s.insert(tx, `
+			INSERT INTO problem_examples (problem_id, input, input_order, expected_output, is_hidden, explanation)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			problemID, tests[i].Input, tests[i].InputOrder, tests[i].ExpectedOutput, tests[i].IsHidden, nullString(tests[i].Explanation))
+		if err != nil {
+			return err
+		}
+		tests[i].ID, tests[i].PromblemID = int(id), int(problemID)
+	}
+	return nil
+}
+
+// Column values of a problem definition, in the order the INSERT and UPDATE use
+func definitionArgs(def ProblemDefinition) []interface{} {
+	return []interface{}{
+		def.Name,
+		def.ShortDescription,
+		def.LongDescription,
+		def.ProblemSeed,
+		nullString(def.Examples),
+		def.Difficulty,
+		def.ProblemType,
+		def.CompareMode,
+		def.Tolerance,
+		nullString(def.Checker),
+		nullString(def.TestFile),
+		nullString(def.ReferenceSolution),
+	}
+}
+
+// Run fn in a transaction, committing only if it succeeds
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Return errNotFound if the statement changed no rows
+func requireRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// Store empty strings as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
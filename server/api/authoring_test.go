@@ -0,0 +1,375 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+const sumSeed = "func Sum(x, y int) int {\n}"
+
+func TestCreateProblem(t *testing.T) {
+	originalCheckReferenceSolution := checkReferenceSolutionWrapper
+	defer func() { checkReferenceSolutionWrapper = originalCheckReferenceSolution }()
+	checkReferenceSolutionWrapper = func(def ProblemDefinition, tests []ProblemExample) (string, error) {
+		return `test 1: expected {"sum": 4}, got 3`, nil
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "SuccessfulCreate",
+			body: `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {\n}",
+				"tests": [{"input": "{\"x\": 1, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"sum\": 3}"}]}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO problems").
+					WithArgs("Sum", "", "", sumSeed, nil, "easy", ProblemTypeFunction, CompareExact, 0.0, nil, nil, nil).
+					WillReturnResult(sqlmock.NewResult(6, 1))
+				mock.ExpectExec("^INSERT INTO problem_examples").
+					WithArgs(6, `{"x": 1, "y": 2}`, `["x", "y"]`, `{"sum": 3}`, false, nil).
+					WillReturnResult(sqlmock.NewResult(16, 1))
+				mock.ExpectCommit()
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":6,"name":"Sum","short_description":"","long_description":"","difficulty":"easy","problem_seed":"func Sum(x, y int) int {\n}","problem_type":"function","compare_mode":"exact","compare_tolerance":0,"tests":[{"id":16,"problem_id":6,"input":"{\"x\": 1, \"y\": 2}","input_order":"[\"x\", \"y\"]","expected_output":"{\"sum\": 3}","is_hidden":false}]}`,
+		},
+		{
+			name:         "SeedDoesNotCompile",
+			body:         `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {"}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid problem: problem seed: seed.go:1:25: expected '}', found 'EOF'"}`,
+		},
+		{
+			name: "ReferenceSolutionFails",
+			body: `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {\n}", "reference_solution": "wrong",
+				"tests": [{"input": "{\"x\": 1, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"sum\": 4}"}]}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Reference solution failed: test 1: expected {\"sum\": 4}, got 3"}`,
+		},
+		{
+			name: "InvalidTest",
+			body: `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {\n}",
+				"tests": [{"input": "{\"x\": 1, \"y\": \"2\"}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"sum\": 3}"}]}`,
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid test 1: input y: expected number for int, got string"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/problems", strings.NewReader(tt.body))
+
+			CreateProblem(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateProblem(t *testing.T) {
+	originalGetProblemExamples := GetProblemExamplesWrapper
+	defer func() { GetProblemExamplesWrapper = originalGetProblemExamples }()
+
+	tests := []struct {
+		name         string
+		body         string
+		examples     []ProblemExample
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:     "KeepsExistingTests",
+			body:     `{"name": "Sum", "difficulty": "medium", "problem_seed": "func Sum(x, y int) int {\n}"}`,
+			examples: []ProblemExample{{ID: 4, PromblemID: 2, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"sum": 3}`}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("^UPDATE problems SET").
+					WithArgs("Sum", "", "", sumSeed, nil, "medium", ProblemTypeFunction, CompareExact, 0.0, nil, nil, nil, 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":2,"name":"Sum","short_description":"","long_description":"","difficulty":"medium","problem_seed":"func Sum(x, y int) int {\n}","problem_type":"function","compare_mode":"exact","compare_tolerance":0,"tests":[{"id":4,"problem_id":2,"input":"{\"x\": 1, \"y\": 2}","input_order":"[\"x\", \"y\"]","expected_output":"{\"sum\": 3}","is_hidden":false}]}`,
+		},
+		{
+			name:         "ExistingTestsNoLongerMatch",
+			body:         `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y float64) string {\n}"}`,
+			examples:     []ProblemExample{{ID: 4, PromblemID: 2, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"sum": 3}`}},
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid test 1: expected_output: expected string, got number"}`,
+		},
+		{
+			name: "ReplacesTests",
+			body: `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {\n}",
+				"tests": [{"input": "{\"x\": 2, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"sum\": 4}", "is_hidden": true}]}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("^UPDATE problems SET").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("^DELETE FROM problem_examples WHERE problem_id = \\?$").WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("^INSERT INTO problem_examples").
+					WithArgs(2, `{"x": 2, "y": 2}`, `["x", "y"]`, `{"sum": 4}`, true, nil).
+					WillReturnResult(sqlmock.NewResult(17, 1))
+				mock.ExpectCommit()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":2,"name":"Sum","short_description":"","long_description":"","difficulty":"easy","problem_seed":"func Sum(x, y int) int {\n}","problem_type":"function","compare_mode":"exact","compare_tolerance":0,"tests":[{"id":17,"problem_id":2,"input":"{\"x\": 2, \"y\": 2}","input_order":"[\"x\", \"y\"]","expected_output":"{\"sum\": 4}","is_hidden":true}]}`,
+		},
+		{
+			name: "ProblemNotFound",
+			body: `{"name": "Sum", "difficulty": "easy", "problem_seed": "func Sum(x, y int) int {\n}"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("^UPDATE problems SET").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Problem not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			GetProblemExamplesWrapper = func(store Store, problemID string) ([]ProblemExample, error) {
+				equals(t, "2", problemID)
+				return tt.examples, nil
+			}
+			tt.mockSetup(mock)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/problems/2", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "2"})
+
+			UpdateProblem(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteProblem(t *testing.T) {
+	tests := []struct {
+		name         string
+		submissions  int
+		deleted      int64
+		expectedCode int
+		expectedBody string
+	}{
+		{"Deleted", 0, 1, http.StatusNoContent, ""},
+		{"NotFound", 0, 0, http.StatusNotFound, `{"error":"Problem not found"}`},
+		{"HasSubmissions", 2, 0, http.StatusConflict, `{"error":"Problem has submissions and can't be deleted"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_solutions WHERE problem_id = \\?$").WithArgs("3").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.submissions))
+			if tt.submissions == 0 {
+				for _, table := range []string{"problem_examples", "problem_images"} {
+					mock.ExpectExec("^DELETE FROM " + table + " WHERE problem_id = \\?$").WithArgs("3").WillReturnResult(sqlmock.NewResult(0, 2))
+				}
+				mock.ExpectExec("^DELETE FROM problems WHERE id = \\?$").WithArgs("3").WillReturnResult(sqlmock.NewResult(0, tt.deleted))
+			}
+			if tt.deleted > 0 {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			rec := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/problems/3", nil), map[string]string{"id": "3"})
+
+			DeleteProblem(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateProblemTest(t *testing.T) {
+	definitionColumns := []string{"id", "name", "short_description", "long_description", "difficulty", "problem_seed", "examples",
+		"problem_type", "compare_mode", "compare_tolerance", "checker_code", "test_file", "reference_solution"}
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "SuccessfulCreate",
+			body: `{"input": "{\"x\": 1, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"sum\": 3}", "explanation": "1 + 2 = 3"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT (.+) FROM problems WHERE id = \\?$").WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(definitionColumns).AddRow(2, "Sum", "", "", "easy", sumSeed, "", "function", "exact", 0, "", "", ""))
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO problem_examples").
+					WithArgs(2, `{"x": 1, "y": 2}`, `["x", "y"]`, `{"sum": 3}`, false, "1 + 2 = 3").
+					WillReturnResult(sqlmock.NewResult(18, 1))
+				mock.ExpectCommit()
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":18,"problem_id":2,"input":"{\"x\": 1, \"y\": 2}","input_order":"[\"x\", \"y\"]","expected_output":"{\"sum\": 3}","is_hidden":false,"explanation":"1 + 2 = 3"}`,
+		},
+		{
+			name: "DoesNotMatchSignature",
+			body: `{"input": "{\"x\": 1}", "input_order": "[\"x\"]", "expected_output": "{\"sum\": 1}"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT (.+) FROM problems WHERE id = \\?$").WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(definitionColumns).AddRow(2, "Sum", "", "", "easy", sumSeed, "", "function", "exact", 0, "", "", ""))
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid test: expected 2 arguments in input_order, got 1"}`,
+		},
+		{
+			name: "ProblemNotFound",
+			body: `{"input": "{}", "input_order": "[]", "expected_output": "{}"}`,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT (.+) FROM problems WHERE id = \\?$").WithArgs(2).WillReturnRows(sqlmock.NewRows(definitionColumns))
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Problem not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/problems/2/tests", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "2"})
+
+			CreateProblemTest(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteProblemTest(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		expectedCode int
+	}{
+		{"Deleted", 1, http.StatusNoContent},
+		{"NotFound", 0, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("^DELETE FROM problem_examples WHERE id = \\? AND problem_id = \\?$").WithArgs("9", "2").
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/problems/2/tests/9", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "2", "testId": "9"})
+
+			DeleteProblemTest(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         *User
+		expectedCode int
+	}{
+		{"Admin", &User{ID: 1, Username: "admin", IsAdmin: true}, http.StatusNoContent},
+		{"NotAdmin", &User{ID: 2, Username: "alice"}, http.StatusForbidden},
+		{"NotLoggedIn", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/problems/3", nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey, *tt.user))
+			}
+
+			RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})(rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
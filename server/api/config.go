@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds the server settings. Each one has a default, which the config
+// file overrides, then its environment variable, then its flag.
+type Config struct {
+	ConfigFile      string
+	HTTPAddr        string
+	DatabaseURL     string
+	MigrationsDir   string
+	PublicDir       string
+	WorkerURLs      []string
+	WorkerDNS       string
+	WorkerSecret    string
+	SessionSecret   string
+	WorkerTimeout   time.Duration
+	WorkerHealth    time.Duration
+	QueueURL        string
+	QueueWorkers    int
+	QueueSize       int
+	RequeueInterval time.Duration
+	RequeueAfter    time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
+	MaxRequestBytes int64
+}
+
+// Return the settings used when nothing overrides them
+func DefaultConfig() Config {
+	return Config{
+		HTTPAddr:        ":8080",
+		DatabaseURL:     DefaultDatabaseURL,
+		MigrationsDir:   MigrationsDir,
+		PublicDir:       "./public",
+		WorkerURLs:      []string{"http://localhost:8081/process-code"},
+		WorkerTimeout:   10 * time.Second,
+		WorkerHealth:    10 * time.Second,
+		QueueURL:        MemoryQueueURL,
+		QueueWorkers:    4,
+		QueueSize:       100,
+		RequeueInterval: time.Minute,
+		RequeueAfter:    10 * time.Minute,
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		ShutdownTimeout: 20 * time.Second,
+		MaxRequestBytes: 4 << 20,
+	}
+}
+
+// Environment variable overriding each flag
+var configEnv = []struct{ flag, env string }{
+	{"config", "CONFIG_FILE"},
+	{"http", "HTTP_ADDR"},
+	{"db", "DATABASE_URL"},
+	{"migrations-dir", "MIGRATIONS_DIR"},
+	{"public-dir", "PUBLIC_DIR"},
+	{"worker-url", "WORKER_URL"},
+	{"worker-dns", "WORKER_DNS"},
+	{"worker-secret", "WORKER_SECRET"},
+	{"session-secret", "SESSION_SECRET"},
+	{"worker-timeout", "WORKER_TIMEOUT"},
+	{"worker-health-interval", "WORKER_HEALTH_INTERVAL"},
+	{"queue", "QUEUE_URL"},
+	{"queue-workers", "QUEUE_WORKERS"},
+	{"queue-size", "QUEUE_SIZE"},
+	{"requeue-interval", "REQUEUE_INTERVAL"},
+	{"requeue-after", "REQUEUE_AFTER"},
+	{"read-timeout", "READ_TIMEOUT"},
+	{"write-timeout", "WRITE_TIMEOUT"},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT"},
+	{"max-request-bytes", "MAX_REQUEST_BYTES"},
+}
+
+// Settings in effect, replaced by SetConfig at startup
+var serverConfig = DefaultConfig()
+
+// Apply the settings read by the handlers, such as where the worker service is
+func SetConfig(config Config) {
+	serverConfig = config
+}
+
+// Define a flag for each setting, defaulting to its current value
+func (c *Config) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&c.ConfigFile, "config", c.ConfigFile, "JSON config file mapping flag names to values ($CONFIG_FILE)")
+	flags.StringVar(&c.HTTPAddr, "http", c.HTTPAddr, "address to serve HTTP on ($HTTP_ADDR)")
+	flags.StringVar(&c.DatabaseURL, "db", c.DatabaseURL, "database URL, sqlite://<path> or postgres://... ($DATABASE_URL)")
+	flags.StringVar(&c.MigrationsDir, "migrations-dir", c.MigrationsDir, "directory of the per-dialect migration directories ($MIGRATIONS_DIR)")
+	flags.StringVar(&c.PublicDir, "public-dir", c.PublicDir, "directory of the front-end files ($PUBLIC_DIR)")
+	flags.Var((*listFlag)(&c.WorkerURLs), "worker-url", "comma-separated URLs of the workers submissions are sent to for grading ($WORKER_URL)")
+	flags.StringVar(&c.WorkerDNS, "worker-dns", c.WorkerDNS, "worker URL whose host name resolves to every worker, replacing --worker-url ($WORKER_DNS)")
+	flags.DurationVar(&c.WorkerTimeout, "worker-timeout", c.WorkerTimeout, "time allowed for the worker to grade a submission ($WORKER_TIMEOUT)")
+	flags.DurationVar(&c.WorkerHealth, "worker-health-interval", c.WorkerHealth, "time between health checks of the workers ($WORKER_HEALTH_INTERVAL)")
+	flags.StringVar(&c.QueueURL, "queue", c.QueueURL, "queue of submissions to grade, "+MemoryQueueURL+" or nats://host:port ($QUEUE_URL)")
+	flags.IntVar(&c.QueueWorkers, "queue-workers", c.QueueWorkers, "submissions graded at once ($QUEUE_WORKERS)")
+	flags.IntVar(&c.QueueSize, "queue-size", c.QueueSize, "submissions the in-memory queue holds before turning more away ($QUEUE_SIZE)")
+	flags.DurationVar(&c.RequeueInterval, "requeue-interval", c.RequeueInterval, "time between checks for submissions lost by the queue ($REQUEUE_INTERVAL)")
+	flags.DurationVar(&c.RequeueAfter, "requeue-after", c.RequeueAfter, "time a submission's status may go unchanged before it is queued again ($REQUEUE_AFTER)")
+	flags.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "time allowed to read a request ($READ_TIMEOUT)")
+	flags.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "time allowed to handle a request and write its response ($WRITE_TIMEOUT)")
+	flags.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed for running requests to finish at shutdown ($SHUTDOWN_TIMEOUT)")
+	flags.Int64Var(&c.MaxRequestBytes, "max-request-bytes", c.MaxRequestBytes, "largest request body accepted ($MAX_REQUEST_BYTES)")
+	return flags
+}
+
+// Define the flags of flagSet along with those of the secrets, which can only
+// be set by the config file or the environment. Command lines can be read by
+// every process on the machine, including the code the workers run.
+func (c *Config) settingSet(name string) *flag.FlagSet {
+	flags := c.flagSet(name)
+	flags.StringVar(&c.WorkerSecret, "worker-secret", c.WorkerSecret, "secret shared with the workers, which only accept submissions signed with it ($WORKER_SECRET)")
+	flags.StringVar(&c.SessionSecret, "session-secret", c.SessionSecret, "key signing the session cookies, random unless set ($SESSION_SECRET)")
+	return flags
+}
+
+// Load the settings for a command from its flags, the environment and the
+// config file. Returns the arguments left after the flags.
+func LoadConfig(command string, args []string, getenv func(string) string) (Config, []string, error) {
+	// The config file is found first, since everything else overrides it
+	probe := DefaultConfig()
+	if err := probe.flagSet(command).Parse(args); err != nil {
+		return probe, nil, err
+	}
+	path := probe.ConfigFile
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+
+	config := DefaultConfig()
+	flags := config.flagSet(command)
+	settings := config.settingSet(command)
+	if path != "" {
+		if err := loadConfigFile(settings, path); err != nil {
+			return config, nil, err
+		}
+	}
+
+	// WORKER_HOST, WORKER_PORT and WORKER_PATH predate WORKER_URL, which wins over them
+	if getenv("WORKER_URL") == "" && (getenv("WORKER_HOST") != "" || getenv("WORKER_PORT") != "" || getenv("WORKER_PATH") != "") {
+		config.WorkerURLs = []string{legacyWorkerURL(getenv)}
+	}
+	for _, setting := range configEnv {
+		if value := getenv(setting.env); value != "" {
+			if err := settings.Set(setting.flag, value); err != nil {
+				return config, nil, fmt.Errorf("invalid %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+	config.ConfigFile = path
+	return config, flags.Args(), nil
+}
+
+// Set the flags named in a JSON config file, e.g. {"http": ":9090", "worker-timeout": "20s"}
+func loadConfigFile(flags *flag.FlagSet, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	for name, raw := range values {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		// Strings are set unquoted, lists of strings joined by commas, and
+		// numbers and booleans as written
+		var value string
+		var list []string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+			if err := json.Unmarshal(raw, &list); err == nil {
+				value = strings.Join(list, ",")
+			}
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: invalid %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// listFlag is a flag holding a comma-separated list, replaced whenever it is set
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Build the worker URL from the separate host, port and path variables
+func legacyWorkerURL(getenv func(string) string) string {
+	workerHost := getenv("WORKER_HOST")
+	if workerHost == "" {
+		workerHost = "http://localhost"
+	}
+
+	workerPort := getenv("WORKER_PORT")
+	if workerPort == "" {
+		workerPort = "8081"
+	}
+
+	workerPath := getenv("WORKER_PATH")
+	if workerPath == "" {
+		workerPath = "/process-code"
+	}
+
+	return fmt.Sprintf("%s:%s%s", workerHost, workerPort, workerPath)
+}
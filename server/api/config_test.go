@@ -0,0 +1,93 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "leetgo.json")
+	ok(t, os.WriteFile(file, []byte(`{"http": ":9090", "db": "sqlite://file.db", "worker-url": ["http://a:8081/process-code", "http://b:8081/process-code"], "worker-timeout": "20s", "max-request-bytes": 1024}`), 0644))
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(*Config)
+		rest     []string
+	}{
+		{"Defaults", nil, nil, func(c *Config) {}, nil},
+		{"ConfigFileFlag", []string{"--config", file}, nil, func(c *Config) {
+			c.ConfigFile = file
+			c.HTTPAddr = ":9090"
+			c.DatabaseURL = "sqlite://file.db"
+			c.WorkerURLs = []string{"http://a:8081/process-code", "http://b:8081/process-code"}
+			c.WorkerTimeout = 20 * time.Second
+			c.MaxRequestBytes = 1024
+		}, []string{}},
+		{"EnvOverridesFile", nil, map[string]string{"CONFIG_FILE": file, "HTTP_ADDR": ":7070", "WORKER_URL": "http://c:8081/process-code", "WORKER_SECRET": "s3cret", "SESSION_SECRET": "k3y", "WORKER_TIMEOUT": "5s"}, func(c *Config) {
+			c.ConfigFile = file
+			c.WorkerSecret = "s3cret"
+			c.SessionSecret = "k3y"
+			c.HTTPAddr = ":7070"
+			c.DatabaseURL = "sqlite://file.db"
+			c.WorkerURLs = []string{"http://c:8081/process-code"}
+			c.WorkerTimeout = 5 * time.Second
+			c.MaxRequestBytes = 1024
+		}, nil},
+		{"FlagsOverrideEnv", []string{"--http=0.0.0.0:8080", "-db", "postgres://db/leetgo", "import", "packs"}, map[string]string{"HTTP_ADDR": ":7070", "DATABASE_URL": "sqlite://env.db"}, func(c *Config) {
+			c.HTTPAddr = "0.0.0.0:8080"
+			c.DatabaseURL = "postgres://db/leetgo"
+		}, []string{"import", "packs"}},
+		{"LegacyWorkerEnv", nil, map[string]string{"WORKER_HOST": "http://example.com", "WORKER_PATH": "/custom-path"}, func(c *Config) {
+			c.WorkerURLs = []string{"http://example.com:8081/custom-path"}
+		}, nil},
+		{"WorkerURLOverridesLegacyEnv", nil, map[string]string{"WORKER_PORT": "9090", "WORKER_URL": "http://worker/process-code"}, func(c *Config) {
+			c.WorkerURLs = []string{"http://worker/process-code"}
+		}, nil},
+		{"WorkerList", []string{"--worker-url", "http://a/process-code, http://b/process-code,", "--worker-dns=http://workers.internal:8081/process-code"}, nil, func(c *Config) {
+			c.WorkerURLs = []string{"http://a/process-code", "http://b/process-code"}
+			c.WorkerDNS = "http://workers.internal:8081/process-code"
+		}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := DefaultConfig()
+			tt.expected(&expected)
+
+			config, rest, err := LoadConfig("serve", tt.args, func(key string) string { return tt.env[key] })
+			ok(t, err)
+			equals(t, expected, config)
+			equals(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.json")
+	ok(t, os.WriteFile(unknown, []byte(`{"port": 8080}`), 0644))
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected string
+	}{
+		{"UnknownFileSetting", []string{"--config", unknown}, nil, `config file ` + unknown + `: unknown setting "port"`},
+		{"MissingFile", []string{"--config", filepath.Join(dir, "missing.json")}, nil, "reading config file: open " + filepath.Join(dir, "missing.json") + ": no such file or directory"},
+		{"InvalidEnv", nil, map[string]string{"WORKER_TIMEOUT": "soon"}, `invalid WORKER_TIMEOUT: parse error`},
+		{"SecretFlag", []string{"--worker-secret=s3cret"}, nil, "flag provided but not defined: -worker-secret"},
+		{"SessionSecretFlag", []string{"--session-secret=k3y"}, nil, "flag provided but not defined: -session-secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadConfig("serve", tt.args, func(key string) string { return tt.env[key] })
+			assert(t, err != nil && err.Error() == tt.expected, "expected error %q, got %v", tt.expected, err)
+		})
+	}
+}
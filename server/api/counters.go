@@ -0,0 +1,77 @@
+package api
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+)
+
+// Count a submission as an attempt at its problem, and as a solve if it is the
+// first accepted solution of a logged-in owner. The solver's row in
+// problem_solvers decides which submission is first, even when several are
+// completed at once.
+func (s *sqlStore) updateProblemCounters(tx *sql.Tx, submission Submission) error {
+	solves := 0
+	if submission.Verdict == VerdictAccepted && submission.UserID != 0 {
+		result, err := s.exec(tx, `
+			INSERT INTO problem_solvers (problem_id, user_id) VALUES (?, ?)
+			ON CONFLICT DO NOTHING`,
+			submission.ProblemID, submission.UserID)
+		if err != nil {
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		solves = int(inserted)
+	}
+
+	_, err := s.exec(tx, `UPDATE problems SET attempts = attempts + 1, solves = solves + ? WHERE id = ?`, solves, submission.ProblemID)
+	return err
+}
+
+// Recompute every problem's solvers, attempts and solves from the stored submissions
+func (s *sqlStore) BackfillProblemCounters() (int64, error) {
+	var updated int64
+	err := withTx(s.db, func(tx *sql.Tx) error {
+		_, err := s.exec(tx, `
+			INSERT INTO problem_solvers (problem_id, user_id)
+			SELECT DISTINCT problem_id, user_id FROM user_solutions
+			WHERE verdict = ? AND problem_id IS NOT NULL AND user_id IS NOT NULL
+			ON CONFLICT DO NOTHING`, VerdictAccepted)
+		if err != nil {
+			return err
+		}
+
+		result, err := s.exec(tx, `
+			UPDATE problems SET
+				attempts = (
+					SELECT COUNT(*) FROM user_solutions
+					WHERE user_solutions.problem_id = problems.id AND status = ? AND result != ?
+				),
+				solves = (
+					SELECT COUNT(*) FROM problem_solvers
+					WHERE problem_solvers.problem_id = problems.id
+				)`, SubmissionDone, SubmissionError)
+		if err != nil {
+			return err
+		}
+		updated, err = result.RowsAffected()
+		return err
+	})
+	return updated, err
+}
+
+// Return solves as a percentage of attempts, rounded to one decimal place
+func acceptanceRate(attempts, solves string) float64 {
+	a, err := strconv.Atoi(attempts)
+	if err != nil || a <= 0 {
+		return 0
+	}
+	s, err := strconv.Atoi(solves)
+	if err != nil {
+		return 0
+	}
+	return math.Round(1000*float64(s)/float64(a)) / 10
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateProblemCounters(t *testing.T) {
+	tests := []struct {
+		name           string
+		submission     Submission
+		expectSolver   bool
+		solverInserted int64
+		expectedSolves int
+	}{
+		{"WrongAnswer", Submission{ProblemID: "1", UserID: 2, Verdict: VerdictWrongAnswer}, false, 0, 0},
+		{"FirstAccepted", Submission{ProblemID: "1", UserID: 2, Verdict: VerdictAccepted}, true, 1, 1},
+		{"RepeatAccepted", Submission{ID: 8, ProblemID: "1", UserID: 2, Verdict: VerdictAccepted}, true, 0, 0},
+		{"AnonymousAccepted", Submission{ProblemID: "1", SessionID: "abc123", Verdict: VerdictAccepted}, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			if tt.expectSolver {
+				mock.ExpectExec("^INSERT INTO problem_solvers \\(problem_id, user_id\\) VALUES \\(\\?, \\?\\) ON CONFLICT DO NOTHING$").
+					WithArgs("1", tt.submission.UserID).
+					WillReturnResult(sqlmock.NewResult(0, tt.solverInserted))
+			}
+			mock.ExpectExec("^UPDATE problems SET attempts = attempts \\+ 1, solves = solves \\+ \\? WHERE id = \\?$").
+				WithArgs(tt.expectedSolves, "1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			tx, err := db.Begin()
+			ok(t, err)
+			ok(t, NewSQLiteStore(db).(*sqlStore).updateProblemCounters(tx, tt.submission))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+// Accepted submissions completed at once count as one solve for their owner
+func TestConcurrentSolves(t *testing.T) {
+	store := newQueueTestStore(t)
+	userID, err := store.CreateUser("alice", "alice@example.com", "password")
+	ok(t, err)
+
+	var ids []int64
+	for i := 0; i < 8; i++ {
+		id, err := store.SaveSubmission(Submission{ProblemID: "1", UserID: userID, Code: "code", Status: SubmissionQueued})
+		ok(t, err)
+		ids = append(ids, id)
+	}
+	anonymous, err := store.SaveSubmission(Submission{ProblemID: "1", SessionID: "abc123", Code: "code", Status: SubmissionQueued})
+	ok(t, err)
+	ids = append(ids, anonymous)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(ids))
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			errs <- store.CompleteSubmission(Submission{ID: id, Result: "PASSED", Verdict: VerdictAccepted})
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ok(t, err)
+	}
+
+	problem, err := store.GetProblem("1")
+	ok(t, err)
+	equals(t, "9", problem.Attempts)
+	equals(t, "1", problem.Solves)
+}
+
+func TestSaveSubmissionNotCounted(t *testing.T) {
+	tests := []struct {
+		name       string
+		submission Submission
+	}{
+		{"Error", Submission{ProblemID: "1", Code: "code", Status: SubmissionDone, Result: SubmissionError}},
+		{"Queued", Submission{ProblemID: "1", Code: "code", Status: SubmissionQueued}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectExec("^INSERT INTO user_solutions").WillReturnResult(sqlmock.NewResult(3, 1))
+			mock.ExpectCommit()
+
+			_, err = NewSQLiteStore(db).SaveSubmission(tt.submission)
+			ok(t, err)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestBackfillProblemCounters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO problem_solvers (.+) ON CONFLICT DO NOTHING$").
+		WithArgs(VerdictAccepted).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE problems SET attempts = (.+), solves = (.+)$").
+		WithArgs(SubmissionDone, SubmissionError).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	updated, err := NewSQLiteStore(db).BackfillProblemCounters()
+	ok(t, err)
+	equals(t, int64(5), updated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAcceptanceRate(t *testing.T) {
+	tests := []struct {
+		attempts string
+		solves   string
+		expected float64
+	}{
+		{"10", "5", 50},
+		{"3", "1", 33.3},
+		{"0", "0", 0},
+		{"", "", 0},
+	}
+
+	for _, tt := range tests {
+		equals(t, tt.expected, acceptanceRate(tt.attempts, tt.solves))
+	}
+}
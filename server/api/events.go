@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// How often a stream checks the database for changes made by other servers,
+// which grade submissions taken from a shared queue
+const eventPollInterval = 2 * time.Second
+
+// How long a stream stays open before the browser is left to reconnect
+const eventStreamLifetime = 5 * time.Minute
+
+// Events a stream can hold before further progress is dropped
+const eventBufferSize = 16
+
+// Context of every event stream, canceled by CloseEventStreams at shutdown
+var eventStreamsCtx, closeEventStreams = context.WithCancel(context.Background())
+
+// End every event stream so shutdown doesn't wait for them. Browsers
+// reconnect, to another server if this one is gone.
+func CloseEventStreams() {
+	closeEventStreams()
+}
+
+// eventHub passes the events of submissions graded by this server to the
+// streams watching them
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan SubmissionEvent]struct{}
+}
+
+// Events of the submissions graded by this server
+var submissionEvents = &eventHub{subscribers: make(map[int64]map[chan SubmissionEvent]struct{})}
+
+// Watch a submission's events until the returned function is called
+func (h *eventHub) subscribe(id int64) (<-chan SubmissionEvent, func()) {
+	events := make(chan SubmissionEvent, eventBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[id] == nil {
+		h.subscribers[id] = make(map[chan SubmissionEvent]struct{})
+	}
+	h.subscribers[id][events] = struct{}{}
+
+	return events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[id], events)
+		if len(h.subscribers[id]) == 0 {
+			delete(h.subscribers, id)
+		}
+	}
+}
+
+// Send an event to the submission's streams. A stream that has fallen behind
+// misses it, and catches up from the database instead.
+func (h *eventHub) publish(id int64, event SubmissionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for events := range h.subscribers[id] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// Stream a submission's progress as Server-Sent Events: a status event for
+// each change of status, progress events while the worker grades it, and a
+// done event holding the graded submission
+func GetSubmissionEvents(store Store, w http.ResponseWriter, r *http.Request) {
+	owner, ok := existingOwner(r)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// Subscribe first, so nothing that happens after the submission is loaded is missed
+	events, unsubscribe := submissionEvents.subscribe(id)
+	defer unsubscribe()
+
+	submission, err := store.GetSubmission(fmt.Sprint(id), owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve submission")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	// The server's write timeout would otherwise cut the stream short
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(eventStreamLifetime + time.Minute)); err != nil {
+		log.Printf("Event stream limited to the write timeout: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	status := submission.Status
+	writeEvent(w, "status", SubmissionEvent{Status: status})
+	flusher.Flush()
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	lifetime := time.NewTimer(eventStreamLifetime)
+	defer lifetime.Stop()
+
+	for status != SubmissionDone {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-eventStreamsCtx.Done():
+			return
+		case <-lifetime.C:
+			return
+		case event := <-events:
+			if event.Progress != nil {
+				writeEvent(w, "progress", event.Progress)
+				flusher.Flush()
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		// Status events only say something changed; the database says what
+		submission, err = store.GetSubmission(fmt.Sprint(id), owner)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			return
+		}
+		if submission.Status != status {
+			status = submission.Status
+			writeEvent(w, "status", SubmissionEvent{Status: status})
+			flusher.Flush()
+		}
+	}
+
+	writeEvent(w, "done", submission)
+	flusher.Flush()
+}
+
+// Write a Server-Sent Event with its data encoded as JSON
+func writeEvent(w io.Writer, name string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, encoded)
+	return err
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := &eventHub{subscribers: make(map[int64]map[chan SubmissionEvent]struct{})}
+	events, unsubscribe := hub.subscribe(1)
+	other, unsubscribeOther := hub.subscribe(2)
+	defer unsubscribeOther()
+
+	hub.publish(1, SubmissionEvent{Status: SubmissionRunning})
+	equals(t, SubmissionEvent{Status: SubmissionRunning}, <-events)
+	equals(t, 0, len(other))
+
+	// A stream that has fallen behind doesn't hold up the others
+	for i := 0; i < eventBufferSize+1; i++ {
+		hub.publish(1, SubmissionEvent{Progress: &Progress{Stage: "RUNNING", Completed: i}})
+	}
+	equals(t, eventBufferSize, len(events))
+
+	unsubscribe()
+	equals(t, 1, len(hub.subscribers))
+}
+
+func TestGetSubmissionEvents(t *testing.T) {
+	store := newQueueTestStore(t)
+	done, err := store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: "done", Status: SubmissionDone, Result: "PASSED", Verdict: VerdictAccepted})
+	ok(t, err)
+	queued, err := store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: "queued", Status: SubmissionQueued})
+	ok(t, err)
+
+	t.Run("AlreadyDone", func(t *testing.T) {
+		rec := serveSubmissionEvents(store, done, "abc123")
+		equals(t, http.StatusOK, rec.Code)
+		equals(t, "text/event-stream", rec.Header().Get("Content-Type"))
+		equals(t, []string{"status", "done"}, eventNames(rec.Body.String()))
+		assert(t, strings.Contains(rec.Body.String(), `data: {"status":"DONE"}`), "expected a DONE status event, got %s", rec.Body.String())
+		assert(t, strings.Contains(rec.Body.String(), `"verdict":"ACCEPTED"`), "expected the graded submission, got %s", rec.Body.String())
+	})
+
+	t.Run("NotOwner", func(t *testing.T) {
+		equals(t, http.StatusNotFound, serveSubmissionEvents(store, done, "other").Code)
+		equals(t, http.StatusNotFound, serveSubmissionEvents(store, done, "").Code)
+	})
+
+	t.Run("Graded", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.HandleFunc("/submissions/{id}/events", GetSubmissionEventsHandler(store))
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/submissions/%d/events", server.URL, queued), nil)
+		ok(t, err)
+		req.AddCookie(&http.Cookie{Name: anonymousCookieName, Value: "abc123"})
+		resp, err := http.DefaultClient.Do(req)
+		ok(t, err)
+		defer resp.Body.Close()
+		stream := bufio.NewReader(resp.Body)
+		equals(t, "event: status\ndata: {\"status\":\"QUEUED\"}\n", readEvent(t, stream))
+
+		ok(t, store.SetSubmissionStatus(queued, SubmissionRunning))
+		submissionEvents.publish(queued, SubmissionEvent{Status: SubmissionRunning})
+		equals(t, "event: status\ndata: {\"status\":\"RUNNING\"}\n", readEvent(t, stream))
+		submissionEvents.publish(queued, SubmissionEvent{Progress: &Progress{Stage: "RUNNING", Completed: 1, Total: 4}})
+		equals(t, "event: progress\ndata: {\"stage\":\"RUNNING\",\"completed\":1,\"total\":4}\n", readEvent(t, stream))
+
+		ok(t, store.CompleteSubmission(Submission{ID: queued, Status: SubmissionDone, Result: TestFailed, Verdict: VerdictWrongAnswer}))
+		submissionEvents.publish(queued, SubmissionEvent{Status: SubmissionDone})
+		equals(t, "event: status\ndata: {\"status\":\"DONE\"}\n", readEvent(t, stream))
+		done := readEvent(t, stream)
+		assert(t, strings.HasPrefix(done, "event: done\n") && strings.Contains(done, `"verdict":"WRONG_ANSWER"`), "expected the graded submission, got %s", done)
+		_, err = stream.ReadByte()
+		equals(t, io.EOF, err)
+	})
+}
+
+// Request a submission's event stream with the given anonymous session
+func serveSubmissionEvents(store Store, id int64, session string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/submissions/%d/events", id), nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: anonymousCookieName, Value: session})
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(id)})
+	rec := httptest.NewRecorder()
+	GetSubmissionEvents(store, rec, req)
+	return rec
+}
+
+// Read the next event from a stream
+func readEvent(t *testing.T, stream *bufio.Reader) string {
+	var event strings.Builder
+	for {
+		line, err := stream.ReadString('\n')
+		ok(t, err)
+		if line == "\n" {
+			return event.String()
+		}
+		event.WriteString(line)
+	}
+}
+
+// Return the names of the events in a stream
+func eventNames(stream string) []string {
+	var names []string
+	for _, line := range strings.Split(stream, "\n") {
+		if name, found := strings.CutPrefix(line, "event: "); found {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -1,20 +1,17 @@
 package api
 
 import (
-	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"time"
 )
 
-const workerTimeout = 10 * time.Second
-
-// Handle a code execution request
-func ExecuteCode(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+// Handle a code execution request, queueing the submission to be graded
+func ExecuteCode(store Store, queue Queue, w http.ResponseWriter, r *http.Request) {
 	var codeSubmission CodeSubmission
 
 	if err := decodeRequest(r, &codeSubmission); err != nil {
@@ -25,7 +22,7 @@ func ExecuteCode(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("User submission: %+v", codeSubmission)
 
-	examples, err := GetProblemExamplesWrapper(db, codeSubmission.ProblemID)
+	examples, err := GetProblemExamplesWrapper(store, codeSubmission.ProblemID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve problem examples")
 		log.Printf("Database error: %v", err)
@@ -35,17 +32,88 @@ func ExecuteCode(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	log.Printf("Retrieved problem examples: %+v", examples)
 	codeSubmission.ProblemExamples = examples
 
-	codeOutput, err := callWorkerServiceWrapper(codeSubmission)
+	config, err := GetProblemConfigWrapper(store, codeSubmission.ProblemID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to execute code")
-		log.Printf("Worker service error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve problem settings")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	applyProblemConfig(&codeSubmission, config)
+
+	id, err := recordSubmission(store, w, r, Submission{ProblemID: codeSubmission.ProblemID, Code: codeSubmission.Code, Status: SubmissionQueued})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save submission")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	if err := queue.Enqueue(Job{SubmissionID: id, Submission: codeSubmission}); err != nil {
+		log.Printf("Failed to queue submission %d: %v", id, err)
+		if err := CompleteSubmissionWrapper(store, Submission{ID: id, Result: SubmissionError}); err != nil {
+			log.Printf("Failed to record submission %d as failed: %v", id, err)
+		}
+		respondWithError(w, http.StatusServiceUnavailable, "Too many submissions waiting to be graded, try again shortly")
 		return
 	}
 
-	log.Printf("Worker response: %+v", codeOutput)
+	respondWithJSON(w, http.StatusAccepted, QueuedSubmission{SubmissionID: id, Status: SubmissionQueued})
+}
+
+// Copy a problem's grading settings into a submission
+func applyProblemConfig(codeSubmission *CodeSubmission, config ProblemConfig) {
+	if config.Name != "" {
+		codeSubmission.Problem = config.Name
+	}
+	codeSubmission.ProblemSeed = config.ProblemSeed
+	codeSubmission.CompareMode = config.CompareMode
+	codeSubmission.Tolerance = config.Tolerance
+	codeSubmission.Checker = config.Checker
+	codeSubmission.ProblemType = config.ProblemType
+	codeSubmission.TestFile = config.TestFile
+}
+
+// Wrapper function for Store.SetSubmissionStatus
+var SetSubmissionStatusWrapper = func(store Store, id int64, status string) error {
+	return store.SetSubmissionStatus(id, status)
+}
 
-	response := buildCodeOutput(codeOutput, codeSubmission.ProblemExamples)
-	respondWithJSON(w, http.StatusOK, response)
+// Wrapper function for Store.CompleteSubmission
+var CompleteSubmissionWrapper = func(store Store, submission Submission) error {
+	return store.CompleteSubmission(submission)
+}
+
+// Grade a queued submission with the worker service and record the outcome
+func gradeJob(store Store, job Job) {
+	if err := SetSubmissionStatusWrapper(store, job.SubmissionID, SubmissionRunning); err != nil {
+		// Already graded, by another server or before a restart
+		log.Printf("Skipping submission %d: %v", job.SubmissionID, err)
+		return
+	}
+
+	submissionEvents.publish(job.SubmissionID, SubmissionEvent{Status: SubmissionRunning})
+
+	var submission Submission
+	codeOutput, err := callWorkerServiceWrapper(job.Submission, func(progress Progress) {
+		submissionEvents.publish(job.SubmissionID, SubmissionEvent{Progress: &progress})
+	})
+	if err != nil {
+		log.Printf("Worker service error: %v", err)
+		// The output tells the user why the submission wasn't graded, and the
+		// code and status tell clients
+		status, message := workerErrorResponse(err)
+		feedback := Feedback{Output: message, ErrorCode: workerErrorCode(err), ErrorStatus: status}
+		submission = Submission{ID: job.SubmissionID, Result: SubmissionError, Feedback: feedback}
+	} else {
+		log.Printf("Worker response: %+v", codeOutput)
+		response := buildCodeOutput(codeOutput, job.Submission.ProblemExamples)
+		submission = newSubmission(job.SubmissionID, job.Submission, response)
+	}
+
+	if err := CompleteSubmissionWrapper(store, submission); err != nil {
+		log.Printf("Failed to save submission %d: %v", job.SubmissionID, err)
+		return
+	}
+	submissionEvents.publish(job.SubmissionID, SubmissionEvent{Status: SubmissionDone})
 }
 
 // Decode a request body into a struct
@@ -57,59 +125,160 @@ func decodeRequest(r *http.Request, v interface{}) error {
 	return json.Unmarshal(bodyBytes, v)
 }
 
+// Content type of a worker response streaming the submission's progress
+const workerStreamContentType = "application/x-ndjson"
+
 // Wrapper function to get problem examples
-var callWorkerServiceWrapper func(codeSubmission CodeSubmission) (CodeOutput, error) = callWorkerService
+var callWorkerServiceWrapper func(codeSubmission CodeSubmission, report func(Progress)) (CodeOutput, error) = callWorkerService
 
-// Send a code submission to the worker service
-func callWorkerService(codeSubmission CodeSubmission) (CodeOutput, error) {
-	var codeOutput CodeOutput
+// Number of times a submission is sent to the workers while they fail with retryable errors
+const workerAttempts = 2
 
+// Send a code submission to a worker service, passing on the progress it
+// reports while grading. Failures are returned as a *WorkerError.
+func callWorkerService(codeSubmission CodeSubmission, report func(Progress)) (CodeOutput, error) {
 	workerRequestBody, err := json.Marshal(codeSubmission)
 	if err != nil {
-		return codeOutput, err
+		return CodeOutput{}, err
 	}
 
 	log.Printf("Worker request body: %s", string(workerRequestBody))
 
-	ctx, cancel := context.WithTimeout(context.Background(), workerTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.WorkerTimeout)
 	defer cancel()
 
-	workerURL := GetWorkerURL()
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, workerURL, bytes.NewBuffer(workerRequestBody))
-	if err != nil {
-		return codeOutput, err
+	for attempt := 1; ; attempt++ {
+		codeOutput, err := postToWorker(ctx, workerRequestBody, report)
+		var workerErr *WorkerError
+		if err == nil || !errors.As(err, &workerErr) || !workerErr.Retryable || attempt == workerAttempts {
+			return codeOutput, err
+		}
+		log.Printf("Sending submission again after %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// Send a submission to a worker once and read its result
+func postToWorker(ctx context.Context, body []byte, report func(Progress)) (CodeOutput, error) {
+	resp, err := workerPool.post(ctx, body)
 	if err != nil {
-		return codeOutput, err
+		return CodeOutput{}, transportError(ctx, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return codeOutput, err
+		return CodeOutput{}, readWorkerError(resp)
 	}
 
-	workerResponseBody, err := io.ReadAll(resp.Body)
+	var codeOutput CodeOutput
+	if resp.Header.Get("Content-Type") == workerStreamContentType {
+		codeOutput, err = readWorkerStream(resp.Body, report)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&codeOutput)
+	}
+	var workerErr *WorkerError
+	if errors.As(err, &workerErr) {
+		return CodeOutput{}, err
+	}
 	if err != nil {
-		return codeOutput, err
+		return CodeOutput{}, transportError(ctx, err)
 	}
+	return codeOutput, nil
+}
 
-	err = json.Unmarshal(workerResponseBody, &codeOutput)
-	return codeOutput, err
+// Describe a failure to reach a worker or to read its response
+func transportError(ctx context.Context, err error) *WorkerError {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &WorkerError{Code: ErrorWorkerTimeout, Message: fmt.Sprintf("no result within %s", serverConfig.WorkerTimeout)}
+	}
+	return &WorkerError{Code: ErrorWorkerUnavailable, Message: err.Error(), Retryable: true}
+}
+
+// Read the error a worker responded with, or describe the response when it
+// isn't one, e.g. from a proxy in front of the worker
+func readWorkerError(resp *http.Response) *WorkerError {
+	var response WorkerErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || response.Error == nil || response.Error.Code == "" {
+		return &WorkerError{
+			Code:      ErrorWorkerUnavailable,
+			Message:   "unexpected response " + resp.Status,
+			Retryable: resp.StatusCode >= http.StatusInternalServerError,
+		}
+	}
+	return response.Error
+}
+
+// Read the progress events of a streamed worker response up to its result
+func readWorkerStream(body io.Reader, report func(Progress)) (CodeOutput, error) {
+	decoder := json.NewDecoder(body)
+	for {
+		var event WorkerEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("worker response ended without a result")
+			}
+			return CodeOutput{}, err
+		}
+
+		switch {
+		case event.Error != nil:
+			return CodeOutput{}, event.Error
+		case event.Result != nil:
+			return *event.Result, nil
+		case event.Progress != nil:
+			report(*event.Progress)
+		}
+	}
+}
+
+// Status and message to respond with when a submission couldn't be graded:
+// 504 when the worker timed out, 422 when the problem's data can't be graded
+// and 502 when no worker could grade it
+func workerErrorResponse(err error) (int, string) {
+	var workerErr *WorkerError
+	if !errors.As(err, &workerErr) {
+		return http.StatusBadGateway, "Worker service unavailable"
+	}
+	switch workerErr.Code {
+	case ErrorWorkerTimeout:
+		return http.StatusGatewayTimeout, "Worker service timed out"
+	case ErrorInvalidProblem:
+		return http.StatusUnprocessableEntity, "Invalid problem data: " + workerErr.Message
+	default:
+		return http.StatusBadGateway, "Worker service unavailable"
+	}
+}
+
+// Code of the error a submission couldn't be graded with
+func workerErrorCode(err error) string {
+	var workerErr *WorkerError
+	if !errors.As(err, &workerErr) {
+		return ErrorWorkerUnavailable
+	}
+	return workerErr.Code
 }
 
 // Build a response string from the code output and problem examples
 func buildCodeOutput(codeOutput CodeOutput, examples []ProblemExample) CodeOutput {
+	codeOutput.Results = redactHiddenResults(codeOutput.Results, examples)
 	input, expectedOutput, actualOutput := BuildResponse(&codeOutput, examples)
+
+	// The worker's output is the program's own stdout/stderr unless compilation failed
+	stdout := codeOutput.Output
+	if codeOutput.Verdict == VerdictCompileError {
+		stdout = ""
+	}
+
 	return CodeOutput{
-		TestCount:  codeOutput.TestCount,
-		TestPassed: codeOutput.TestPassed,
-		Output:     actualOutput,
-		Input:      input,
-		Expected:   expectedOutput,
-		Result:     codeOutput.Result,
+		TestCount:   codeOutput.TestCount,
+		TestPassed:  codeOutput.TestPassed,
+		Output:      actualOutput,
+		Stdout:      stdout,
+		Results:     codeOutput.Results,
+		Input:       input,
+		Expected:    expectedOutput,
+		Result:      codeOutput.Result,
+		Verdict:     codeOutput.Verdict,
+		Diagnostics: codeOutput.Diagnostics,
 	}
 }
 
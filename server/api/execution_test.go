@@ -2,12 +2,13 @@ package api
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // Mocks
@@ -17,41 +18,69 @@ type TestStruct struct {
 	Email string `json:"email"`
 }
 
-func mockGetProblemExamples(db *sql.DB, problemID string) ([]ProblemExample, error) {
+func mockGetProblemExamples(store Store, problemID string) ([]ProblemExample, error) {
 	if problemID == "1" {
 		return []ProblemExample{{Input: "test input", ExpectedOutput: "test output"}}, nil
 	}
 	return nil, errors.New("database error")
 }
 
-func mockCallWorkerService(codeSubmission CodeSubmission) (CodeOutput, error) {
-	if codeSubmission.Code == "fail" {
+func mockGetProblemConfig(store Store, problemID string) (ProblemConfig, error) {
+	return ProblemConfig{CompareMode: "exact"}, nil
+}
+
+func mockCallWorkerService(codeSubmission CodeSubmission, report func(Progress)) (CodeOutput, error) {
+	switch codeSubmission.Code {
+	case "fail":
 		return CodeOutput{}, errors.New("worker service error")
+	case "timeout":
+		return CodeOutput{}, &WorkerError{Code: ErrorWorkerTimeout, Message: "no result within 10s"}
+	case "invalid":
+		return CodeOutput{}, &WorkerError{Code: ErrorInvalidProblem, Message: "no function Sum"}
 	}
 	return CodeOutput{Result: "PASSED"}, nil
 }
 
+func mockSaveSubmission(store Store, submission Submission) (int64, error) {
+	return 42, nil
+}
+
+func mockCompleteSubmission(store Store, submission Submission) error {
+	return nil
+}
+
 // Tests
 
 func TestExecuteCode(t *testing.T) {
 	// Save original functions and restore them at the end
 	originalGetProblemExamples := GetProblemExamplesWrapper
+	originalGetProblemConfig := GetProblemConfigWrapper
 	originalCallWorkerService := callWorkerServiceWrapper
+	originalSaveSubmission := SaveSubmissionWrapper
+	originalCompleteSubmission := CompleteSubmissionWrapper
 
 	// Mock functions
 	GetProblemExamplesWrapper = mockGetProblemExamples
+	GetProblemConfigWrapper = mockGetProblemConfig
 	callWorkerServiceWrapper = mockCallWorkerService
+	SaveSubmissionWrapper = mockSaveSubmission
+	CompleteSubmissionWrapper = mockCompleteSubmission
 
 	defer func() {
 		GetProblemExamplesWrapper = originalGetProblemExamples
+		GetProblemConfigWrapper = originalGetProblemConfig
 		callWorkerServiceWrapper = originalCallWorkerService
+		SaveSubmissionWrapper = originalSaveSubmission
+		CompleteSubmissionWrapper = originalCompleteSubmission
 	}()
 
 	tests := []struct {
 		name               string
 		input              CodeSubmission
+		queueSize          int
 		expectedStatusCode int
 		expectedResponse   string
+		expectedJobs       int
 	}{
 		{
 			name: "SuccessfulExecution",
@@ -59,8 +88,10 @@ func TestExecuteCode(t *testing.T) {
 				ProblemID: "1",
 				Code:      "valid code",
 			},
-			expectedStatusCode: http.StatusOK,
-			expectedResponse:   "PASSED",
+			queueSize:          1,
+			expectedStatusCode: http.StatusAccepted,
+			expectedResponse:   `{"submission_id":42,"status":"QUEUED"}`,
+			expectedJobs:       1,
 		},
 		{
 			name: "DatabaseError",
@@ -68,21 +99,23 @@ func TestExecuteCode(t *testing.T) {
 				ProblemID: "999", // ProblemID that triggers database error
 				Code:      "valid code",
 			},
+			queueSize:          1,
 			expectedStatusCode: http.StatusInternalServerError,
 			expectedResponse:   "Failed to retrieve problem examples",
 		},
 		{
-			name: "WorkerServiceError",
+			name: "QueueFull",
 			input: CodeSubmission{
 				ProblemID: "1",
-				Code:      "fail", // Code that triggers worker service error
+				Code:      "valid code",
 			},
-			expectedStatusCode: http.StatusInternalServerError,
-			expectedResponse:   "Failed to execute code",
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedResponse:   "Too many submissions waiting to be graded",
 		},
 		{
 			name:               "InvalidRequest",
 			input:              CodeSubmission{}, // Missing fields in the request
+			queueSize:          1,
 			expectedStatusCode: http.StatusInternalServerError,
 			expectedResponse:   "",
 		},
@@ -95,18 +128,271 @@ func TestExecuteCode(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 
 			rec := httptest.NewRecorder()
+			queue := NewMemoryQueue(tt.queueSize)
 
-			ExecuteCode(nil, rec, req)
+			ExecuteCode(nil, queue, rec, req)
 
 			equals(t, tt.expectedStatusCode, rec.Code)
 
 			if !bytes.Contains(rec.Body.Bytes(), []byte(tt.expectedResponse)) {
 				t.Errorf("Expected response to contain %q, got %q", tt.expectedResponse, rec.Body.String())
 			}
+
+			queue.Close()
+			var jobs []Job
+			for job := range queue.Jobs() {
+				jobs = append(jobs, job)
+			}
+			equals(t, tt.expectedJobs, len(jobs))
+			if len(jobs) > 0 {
+				equals(t, int64(42), jobs[0].SubmissionID)
+				equals(t, "valid code", jobs[0].Submission.Code)
+				equals(t, "exact", jobs[0].Submission.CompareMode)
+				equals(t, 1, len(jobs[0].Submission.ProblemExamples))
+			}
+		})
+	}
+}
+
+func TestGradeJob(t *testing.T) {
+	originalCallWorkerService := callWorkerServiceWrapper
+	originalSetSubmissionStatus := SetSubmissionStatusWrapper
+	originalCompleteSubmission := CompleteSubmissionWrapper
+	defer func() {
+		callWorkerServiceWrapper = originalCallWorkerService
+		SetSubmissionStatusWrapper = originalSetSubmissionStatus
+		CompleteSubmissionWrapper = originalCompleteSubmission
+	}()
+
+	tests := []struct {
+		name      string
+		code      string
+		statusErr error
+		expected  []Submission
+	}{
+		{
+			name:     "Graded",
+			code:     "valid code",
+			expected: []Submission{{ID: 7, ProblemID: "1", Code: "valid code", Status: SubmissionDone, Result: "PASSED"}},
+		},
+		{
+			name:     "WorkerServiceError",
+			code:     "fail", // Code that triggers worker service error
+			expected: []Submission{{ID: 7, Result: SubmissionError, Feedback: Feedback{Output: "Worker service unavailable", ErrorCode: ErrorWorkerUnavailable, ErrorStatus: http.StatusBadGateway}}},
+		},
+		{
+			name: "WorkerTimeout",
+			code: "timeout",
+			expected: []Submission{{ID: 7, Result: SubmissionError, Feedback: Feedback{
+				Output: "Worker service timed out", ErrorCode: ErrorWorkerTimeout, ErrorStatus: http.StatusGatewayTimeout,
+			}}},
+		},
+		{
+			name: "InvalidProblem",
+			code: "invalid",
+			expected: []Submission{{ID: 7, Result: SubmissionError, Feedback: Feedback{
+				Output: "Invalid problem data: no function Sum", ErrorCode: ErrorInvalidProblem, ErrorStatus: http.StatusUnprocessableEntity,
+			}}},
+		},
+		{
+			name:      "AlreadyDone",
+			code:      "valid code",
+			statusErr: errNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var statuses []string
+			var completed []Submission
+			callWorkerServiceWrapper = mockCallWorkerService
+			SetSubmissionStatusWrapper = func(store Store, id int64, status string) error {
+				statuses = append(statuses, status)
+				return tt.statusErr
+			}
+			CompleteSubmissionWrapper = func(store Store, submission Submission) error {
+				completed = append(completed, submission)
+				return nil
+			}
+
+			gradeJob(nil, Job{SubmissionID: 7, Submission: CodeSubmission{ProblemID: "1", Code: tt.code}})
+
+			equals(t, []string{SubmissionRunning}, statuses)
+			equals(t, tt.expected, completed)
 		})
 	}
 }
 
+func TestCallWorkerServiceStream(t *testing.T) {
+	tests := []struct {
+		name             string
+		stream           string
+		expectedProgress []Progress
+		expectedResult   string
+		expectedError    string
+	}{
+		{
+			name: "Result",
+			stream: `{"progress":{"stage":"COMPILING","completed":0,"total":2}}
+{"progress":{"stage":"RUNNING","completed":1,"total":2}}
+{"result":{"testCount":2,"testPassed":2,"result":"PASSED"}}
+`,
+			expectedProgress: []Progress{{Stage: "COMPILING", Total: 2}, {Stage: "RUNNING", Completed: 1, Total: 2}},
+			expectedResult:   "PASSED",
+		},
+		{
+			name:          "WorkerError",
+			stream:        `{"error":{"code":"INVALID_PROBLEM","message":"unsupported problem type: \"x\"","retryable":false}}` + "\n",
+			expectedError: `worker service INVALID_PROBLEM: unsupported problem type: "x"`,
+		},
+		{
+			// The worker may have crashed, so the submission is sent again
+			name:             "NoResult",
+			stream:           `{"progress":{"stage":"COMPILING","completed":0,"total":2}}` + "\n",
+			expectedProgress: []Progress{{Stage: "COMPILING", Total: 2}, {Stage: "COMPILING", Total: 2}},
+			expectedError:    "worker service WORKER_UNAVAILABLE: worker response ended without a result",
+		},
+	}
+
+	originalPool := workerPool
+	defer func() { workerPool = originalPool }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				equals(t, workerStreamContentType, r.Header.Get("Accept"))
+				w.Header().Set("Content-Type", workerStreamContentType)
+				w.Write([]byte(tt.stream))
+			}))
+			defer worker.Close()
+			workerPool = NewWorkerPool([]string{worker.URL}, "")
+
+			var progress []Progress
+			codeOutput, err := callWorkerService(CodeSubmission{}, func(p Progress) { progress = append(progress, p) })
+
+			equals(t, tt.expectedProgress, progress)
+			if tt.expectedError != "" {
+				assert(t, err != nil && err.Error() == tt.expectedError, "expected error %q, got %v", tt.expectedError, err)
+				return
+			}
+			ok(t, err)
+			equals(t, tt.expectedResult, codeOutput.Result)
+		})
+	}
+}
+
+func TestCallWorkerServiceErrors(t *testing.T) {
+	originalPool, originalConfig := workerPool, serverConfig
+	defer func() { workerPool, serverConfig = originalPool, originalConfig }()
+	serverConfig.WorkerTimeout = 200 * time.Millisecond
+
+	shuttingDown := `{"error":{"code":"SHUTTING_DOWN","message":"Worker is shutting down","retryable":true}}`
+	tests := []struct {
+		name             string
+		respond          func(w http.ResponseWriter, r *http.Request, attempt int)
+		expectedAttempts int
+		expectedCode     string // Code of the error returned, or empty when the submission is graded
+		expectedStatus   int
+	}{
+		{
+			name: "Result",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.Write([]byte(`{"result":"PASSED"}`))
+			},
+			expectedAttempts: 1,
+		},
+		{
+			name: "InvalidProblem",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"error":{"code":"INVALID_PROBLEM","message":"function Sum not found in problem seed","retryable":false}}`))
+			},
+			expectedAttempts: 1,
+			expectedCode:     ErrorInvalidProblem,
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+		{
+			name: "RetryableErrorSentAgain",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				if attempt == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(shuttingDown))
+					return
+				}
+				w.Write([]byte(`{"result":"PASSED"}`))
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name: "RetryableErrorRepeats",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(shuttingDown))
+			},
+			expectedAttempts: workerAttempts,
+			expectedCode:     ErrorShuttingDown,
+			expectedStatus:   http.StatusBadGateway,
+		},
+		{
+			name: "NotAWorkerError",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				http.Error(w, "<html>Bad Gateway</html>", http.StatusBadGateway)
+			},
+			expectedAttempts: workerAttempts,
+			expectedCode:     ErrorWorkerUnavailable,
+			expectedStatus:   http.StatusBadGateway,
+		},
+		{
+			name: "Timeout",
+			respond: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				// The connection is only watched for closing once the body has been read
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			},
+			expectedAttempts: 1,
+			expectedCode:     ErrorWorkerTimeout,
+			expectedStatus:   http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				tt.respond(w, r, attempts)
+			}))
+			defer worker.Close()
+			workerPool = NewWorkerPool([]string{worker.URL}, "")
+
+			codeOutput, err := callWorkerService(CodeSubmission{}, func(Progress) {})
+
+			equals(t, tt.expectedAttempts, attempts)
+			if tt.expectedCode == "" {
+				ok(t, err)
+				equals(t, "PASSED", codeOutput.Result)
+				return
+			}
+			var workerErr *WorkerError
+			assert(t, errors.As(err, &workerErr), "expected a worker error, got %v", err)
+			equals(t, tt.expectedCode, workerErr.Code)
+			status, _ := workerErrorResponse(err)
+			equals(t, tt.expectedStatus, status)
+		})
+	}
+
+	t.Run("Unreachable", func(t *testing.T) {
+		workerPool = NewWorkerPool([]string{closedURL(t)}, "")
+		_, err := callWorkerService(CodeSubmission{}, func(Progress) {})
+		var workerErr *WorkerError
+		assert(t, errors.As(err, &workerErr), "expected a worker error, got %v", err)
+		equals(t, ErrorWorkerUnavailable, workerErr.Code)
+		status, message := workerErrorResponse(err)
+		equals(t, http.StatusBadGateway, status)
+		equals(t, "Worker service unavailable", message)
+	})
+}
+
 func TestDecodeRequest(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -183,6 +469,7 @@ func TestBuildCodeOutput(t *testing.T) {
 				TestCount:  1,
 				TestPassed: 1,
 				Output:     "2",
+				Stdout:     "2",
 				Input:      "1",
 				Expected:   "2",
 				Result:     "PASSED",
@@ -193,7 +480,8 @@ func TestBuildCodeOutput(t *testing.T) {
 			codeOutput: CodeOutput{
 				TestCount:  1,
 				TestPassed: 0,
-				Output:     "-1",
+				Output:     "debug print",
+				Results:    []TestResult{{ID: 1, Status: TestFailed, Actual: "-1"}},
 				Result:     "FAILED",
 			},
 			examples: []ProblemExample{
@@ -203,11 +491,39 @@ func TestBuildCodeOutput(t *testing.T) {
 				TestCount:  1,
 				TestPassed: 0,
 				Output:     "-1",
+				Stdout:     "debug print",
+				Results:    []TestResult{{ID: 1, Status: TestFailed, Actual: "-1"}},
 				Input:      "1",
 				Expected:   "2",
 				Result:     "FAILED",
 			},
 		},
+		{
+			name: "FailedHiddenTest",
+			codeOutput: CodeOutput{
+				TestCount:  2,
+				TestPassed: 1,
+				Results: []TestResult{
+					{ID: 1, Status: TestPassed, Input: "1", Expected: "2", Actual: "2"},
+					{ID: 2, Status: TestFailed, Input: "5", Expected: "10", Actual: "7"},
+				},
+				Result: "FAILED",
+			},
+			examples: []ProblemExample{
+				{ID: 1, Input: "1", ExpectedOutput: "2"},
+				{ID: 2, Input: "5", ExpectedOutput: "10", IsHidden: true},
+			},
+			expectedResult: CodeOutput{
+				TestCount:  2,
+				TestPassed: 1,
+				Output:     "hidden test 1 failed",
+				Results: []TestResult{
+					{ID: 1, Status: TestPassed, Input: "1", Expected: "2", Actual: "2"},
+					{ID: 2, Status: TestFailed, Actual: "hidden test 1 failed", Hidden: true},
+				},
+				Result: "FAILED",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -217,6 +533,13 @@ func TestBuildCodeOutput(t *testing.T) {
 			equals(t, tt.expectedResult.TestCount, result.TestCount)
 			equals(t, tt.expectedResult.TestPassed, result.TestPassed)
 			equals(t, tt.expectedResult.Result, result.Result)
+			equals(t, tt.expectedResult.Stdout, result.Stdout)
+			equals(t, tt.expectedResult.Results, result.Results)
+			if tt.expectedResult.Result == "FAILED" {
+				equals(t, tt.expectedResult.Input, result.Input)
+				equals(t, tt.expectedResult.Expected, result.Expected)
+				equals(t, tt.expectedResult.Output, result.Output)
+			}
 		})
 	}
 }
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Directory of the numbered migration files, relative to the server directory,
+// with a subdirectory for each dialect
+const MigrationsDir = "db/migrations"
+
+// Migration files are named like 0002_grading_columns.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// Migration is one numbered up migration
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus is a migration and when it was applied, empty if pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt string
+}
+
+// Load the migrations in fsys, ordered by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	versions := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		versions[version] = entry.Name()
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Report which migrations have been applied to the database
+func (s *sqlStore) GetMigrationStatus(migrations []Migration) ([]MigrationStatus, error) {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration, AppliedAt: applied[migration.Version]}
+	}
+	return statuses, nil
+}
+
+// Apply every pending migration in order, each in its own transaction.
+// Returns the number of migrations applied.
+func (s *sqlStore) MigrateUp(migrations []Migration) (int, error) {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := withTx(s.db, func(tx *sql.Tx) error {
+			for _, stmt := range splitSQLStatements(migration.SQL) {
+				// Migrations are written for their dialect, so aren't rebound
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			_, err := s.exec(tx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, migration.Version, migration.Name)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Create the schema_migrations table if needed and map applied versions to when they were applied
+func (s *sqlStore) appliedMigrations() (map[int]string, error) {
+	_, err := s.exec(s.db, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(s.db, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Split a SQL script into statements on the semicolons outside of quoted
+// strings, quoted identifiers and comments. Statements holding nothing but
+// comments are dropped.
+func splitSQLStatements(script string) []string {
+	var statements []string
+	start, empty := 0, true
+	flush := func(end int) {
+		if !empty {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start, empty = end+1, true
+	}
+
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote inside a quoted string is an escaped quote, which
+			// scanning it as closing and reopening handles too
+			if end := strings.IndexByte(script[i+1:], c); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(script)
+			}
+			empty = false
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+		case c == ';':
+			flush(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			empty = false
+		}
+	}
+	flush(len(script))
+	return statements
+}
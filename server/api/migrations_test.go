@@ -0,0 +1,124 @@
+package api
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "Statements",
+			script:   "CREATE TABLE a (id INTEGER);\nINSERT INTO a VALUES (1);\n",
+			expected: []string{"CREATE TABLE a (id INTEGER)", "INSERT INTO a VALUES (1)"},
+		},
+		{
+			name:     "NoTrailingSemicolon",
+			script:   "SELECT 1",
+			expected: []string{"SELECT 1"},
+		},
+		{
+			name:     "SemicolonInString",
+			script:   "INSERT INTO problems (problem_seed) VALUES ('func F() { x := 1; return }');",
+			expected: []string{"INSERT INTO problems (problem_seed) VALUES ('func F() { x := 1; return }')"},
+		},
+		{
+			name:     "EscapedQuote",
+			script:   "INSERT INTO a VALUES ('it''s; fine'); SELECT 2",
+			expected: []string{"INSERT INTO a VALUES ('it''s; fine')", "SELECT 2"},
+		},
+		{
+			name:     "QuotedIdentifier",
+			script:   `SELECT "a;b" FROM t;`,
+			expected: []string{`SELECT "a;b" FROM t`},
+		},
+		{
+			name:     "Comments",
+			script:   "-- first; statement\nSELECT 1; -- trailing; comment\n/* block; comment */ SELECT 2;\n-- only a comment\n",
+			expected: []string{"-- first; statement\nSELECT 1", "-- trailing; comment\n/* block; comment */ SELECT 2"},
+		},
+		{
+			name:   "Empty",
+			script: " ;\n; -- nothing\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equals(t, tt.expected, splitSQLStatements(tt.script))
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0010_later.sql":     {Data: []byte("SELECT 10")},
+		"0002_second.sql":    {Data: []byte("SELECT 2")},
+		"README.md":          {Data: []byte("not a migration")},
+		"0001_first.sql":     {Data: []byte("SELECT 1")},
+		"archive/0003_x.sql": {Data: []byte("SELECT 3")},
+	})
+	ok(t, err)
+	equals(t, []Migration{
+		{Version: 1, Name: "first", SQL: "SELECT 1"},
+		{Version: 2, Name: "second", SQL: "SELECT 2"},
+		{Version: 10, Name: "later", SQL: "SELECT 10"},
+	}, migrations)
+
+	_, err = LoadMigrations(fstest.MapFS{"first.sql": {}})
+	assert(t, err != nil && strings.Contains(err.Error(), "must be named"), "unexpected error: %v", err)
+
+	_, err = LoadMigrations(fstest.MapFS{"1_a.sql": {}, "001_b.sql": {}})
+	assert(t, err != nil && strings.Contains(err.Error(), "share version 1"), "unexpected error: %v", err)
+}
+
+func TestMigrateUp(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.sqlite3"))
+	ok(t, err)
+	defer db.Close()
+	store := NewSQLiteStore(db)
+
+	migrations := []Migration{
+		{Version: 1, Name: "create", SQL: "CREATE TABLE problems (id INTEGER PRIMARY KEY, name TEXT);"},
+		{Version: 2, Name: "seed", SQL: "INSERT INTO problems (name) VALUES ('a;b');\nINSERT INTO problems (name) VALUES ('c');"},
+	}
+
+	applied, err := store.MigrateUp(migrations[:1])
+	ok(t, err)
+	equals(t, 1, applied)
+
+	statuses, err := store.GetMigrationStatus(migrations)
+	ok(t, err)
+	assert(t, statuses[0].AppliedAt != "", "expected migration 1 to be applied")
+	equals(t, "", statuses[1].AppliedAt)
+
+	applied, err = store.MigrateUp(migrations)
+	ok(t, err)
+	equals(t, 1, applied)
+
+	var names string
+	ok(t, db.QueryRow(`SELECT group_concat(name, ',') FROM problems`).Scan(&names))
+	equals(t, "a;b,c", names)
+
+	// A failing migration is rolled back and stays pending
+	broken := append(migrations, Migration{Version: 3, Name: "broken", SQL: "INSERT INTO problems (name) VALUES ('d'); INSERT INTO missing VALUES (1);"})
+	applied, err = store.MigrateUp(broken)
+	assert(t, err != nil && strings.HasPrefix(err.Error(), "migration 0003_broken: "), "unexpected error: %v", err)
+	equals(t, 0, applied)
+
+	var count int
+	ok(t, db.QueryRow(`SELECT COUNT(*) FROM problems`).Scan(&count))
+	equals(t, 2, count)
+	statuses, err = store.GetMigrationStatus(broken)
+	ok(t, err)
+	equals(t, "", statuses[2].AppliedAt)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
@@ -10,55 +11,19 @@ import (
 )
 
 // Fetch all problems
-func GetAllProblems(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+func GetAllProblems(store Store, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	rows, err := db.Query(`
-    SELECT 
-        id, 
-        name, 
-        short_description, 
-        long_description, 
-        problem_seed, 
-        REPLACE(examples, '\\"', "'") AS examples, 
-        difficulty, 
-        attempts, 
-        solves 
-    FROM problems
-`)
+	problems, err := store.ListProblems()
 	if err != nil {
 		http.Error(w, "Error fetching problems from database", http.StatusInternalServerError)
 		log.Printf("Query error: %v\n", err)
 		return
 	}
-	defer rows.Close()
-
-	var problems []Problem
-
-	for rows.Next() {
-		var p Problem
-		err := rows.Scan(
-			&p.ID,
-			&p.Name,
-			&p.ShortDescription,
-			&p.LongDescription,
-			&p.ProblemSeed,
-			&p.Examples,
-			&p.Difficulty,
-			&p.Attempts,
-			&p.Solves,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning problems from database", http.StatusInternalServerError)
-			log.Printf("Row scan error: %v\n", err)
-			return
-		}
-		problems = append(problems, p)
-	}
 
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error iterating over problems", http.StatusInternalServerError)
-		log.Printf("Row iteration error: %v\n", err)
+	if err = deriveExamples(store, problems); err != nil {
+		http.Error(w, "Error fetching problem examples from database", http.StatusInternalServerError)
+		log.Printf("Examples query error: %v\n", err)
 		return
 	}
 
@@ -66,47 +31,21 @@ func GetAllProblems(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 }
 
 // Fetch all problem names
-func GetProblemNames(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+func GetProblemNames(store Store, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	rows, err := db.Query(`
-	SELECT id, name 
-	FROM problems
-`)
+	problems, err := store.ListProblemNames()
 	if err != nil {
 		http.Error(w, "Error fetching problems from database", http.StatusInternalServerError)
 		log.Printf("Query error: %v\n", err)
 		return
 	}
-	defer rows.Close()
-
-	var problems []Problem
-
-	for rows.Next() {
-		var p Problem
-		err := rows.Scan(
-			&p.ID,
-			&p.Name,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning problems from database", http.StatusInternalServerError)
-			log.Printf("Row scan error: %v\n", err)
-			return
-		}
-		problems = append(problems, p)
-	}
-
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error iterating over problems", http.StatusInternalServerError)
-		log.Printf("Row iteration error: %v\n", err)
-		return
-	}
 
 	json.NewEncoder(w).Encode(problems)
 }
 
 // Fetch a single problem by ID
-func GetProblemDetails(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+func GetProblemDetails(store Store, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
@@ -114,68 +53,192 @@ func GetProblemDetails(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("The problem ID is: %v\n", problemID)
 
-	rows, err := db.Query(`
-	SELECT 
-		id, 
-		name, 
-		short_description, 
-		long_description, 
-		problem_seed, 
-		REPLACE(examples, '\\"', "'") AS examples, 
-		difficulty, 
-		attempts, 
-		solves 
-	FROM problems 
-	WHERE id = ?
-`, problemID)
-	if err != nil {
+	var problems []Problem
+	problem, err := store.GetProblem(problemID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		http.Error(w, "Error fetching problem from database", http.StatusInternalServerError)
 		log.Printf("Query error: %v\n", err)
 		return
 	}
+	if err == nil {
+		problems = append(problems, problem)
+	}
+
+	if err = deriveExamples(store, problems); err != nil {
+		http.Error(w, "Error fetching problem examples from database", http.StatusInternalServerError)
+		log.Printf("Examples query error: %v\n", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(problems)
+}
+
+// Wrapper function for Store.GetProblemExamples
+var GetProblemExamplesWrapper = func(store Store, problemID string) ([]ProblemExample, error) {
+	return store.GetProblemExamples(problemID)
+}
+
+// Fill in the examples of problems that don't define them by hand from their visible test cases
+func deriveExamples(store Store, problems []Problem) error {
+	for i := range problems {
+		if problems[i].Examples != "" {
+			continue
+		}
+		examples, err := GetVisibleExamples(store, problems[i].ID)
+		if err != nil {
+			return err
+		}
+		problems[i].Examples = examples
+	}
+	return nil
+}
+
+// Build the examples display JSON for a problem from its visible test cases
+func GetVisibleExamples(store Store, problemID string) (string, error) {
+	visible, err := store.GetVisibleProblemExamples(problemID)
+	if err != nil {
+		return "", err
+	}
+
+	displays := []ExampleDisplay{}
+	for _, example := range visible {
+		display, err := formatExampleDisplay(example)
+		if err != nil {
+			return "", err
+		}
+		displays = append(displays, display)
+	}
+
+	examples, err := json.Marshal(displays)
+	return string(examples), err
+}
+
+// Wrapper function for Store.GetProblemConfig
+var GetProblemConfigWrapper = func(store Store, problemID string) (ProblemConfig, error) {
+	return store.GetProblemConfig(problemID)
+}
+
+// Columns of a problem as listed to users
+const problemColumns = `
+		id,
+		name,
+		short_description,
+		long_description,
+		problem_seed,
+		COALESCE(REPLACE(examples, '\\"', ''''), '') AS examples,
+		difficulty,
+		attempts,
+		solves`
+
+// Scan a row of problemColumns
+func scanProblem(row interface{ Scan(...interface{}) error }) (Problem, error) {
+	var p Problem
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.ShortDescription,
+		&p.LongDescription,
+		&p.ProblemSeed,
+		&p.Examples,
+		&p.Difficulty,
+		&p.Attempts,
+		&p.Solves,
+	)
+	p.AcceptanceRate = acceptanceRate(p.Attempts, p.Solves)
+	return p, err
+}
+
+func (s *sqlStore) ListProblems() ([]Problem, error) {
+	rows, err := s.query(s.db, `SELECT`+problemColumns+` FROM problems ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var problems []Problem
+	for rows.Next() {
+		p, err := scanProblem(rows)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+func (s *sqlStore) ListProblemNames() ([]Problem, error) {
+	rows, err := s.query(s.db, `SELECT id, name FROM problems ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	var problems []Problem
 	for rows.Next() {
 		var p Problem
-		err := rows.Scan(
-			&p.ID,
-			&p.Name,
-			&p.ShortDescription,
-			&p.LongDescription,
-			&p.ProblemSeed,
-			&p.Examples,
-			&p.Difficulty,
-			&p.Attempts,
-			&p.Solves,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning problem from database", http.StatusInternalServerError)
-			log.Printf("Row scan error: %v\n", err)
-			return
+		if err := rows.Scan(&p.ID, &p.Name); err != nil {
+			return nil, err
 		}
 		problems = append(problems, p)
 	}
+	return problems, rows.Err()
+}
+
+func (s *sqlStore) ListProblemIDs() ([]int, error) {
+	rows, err := s.query(s.db, `SELECT id FROM problems ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error iterating over problems", http.StatusInternalServerError)
-		log.Printf("Row iteration error: %v\n", err)
-		return
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
 
-	json.NewEncoder(w).Encode(problems)
+// Fetch a problem as listed to users, sql.ErrNoRows if there is none
+func (s *sqlStore) GetProblem(id string) (Problem, error) {
+	return scanProblem(s.queryRow(s.db, `SELECT`+problemColumns+` FROM problems WHERE id = ?`, id))
 }
 
-// Wrapper function for GetProblemExamples
-var GetProblemExamplesWrapper func(db *sql.DB, problemID string) ([]ProblemExample, error) = GetProblemExamples
+// Fetch the grading settings for a given problem
+func (s *sqlStore) GetProblemConfig(problemID string) (ProblemConfig, error) {
+	var config ProblemConfig
+	err := s.queryRow(s.db, `
+		SELECT
+			name,
+			COALESCE(problem_seed, ''),
+			COALESCE(compare_mode, 'exact'),
+			COALESCE(compare_tolerance, 0),
+			COALESCE(checker_code, ''),
+			COALESCE(problem_type, 'function'),
+			COALESCE(test_file, '')
+		FROM problems
+		WHERE id = ?`, problemID).Scan(
+		&config.Name,
+		&config.ProblemSeed,
+		&config.CompareMode,
+		&config.Tolerance,
+		&config.Checker,
+		&config.ProblemType,
+		&config.TestFile,
+	)
+	return config, err
+}
 
-// Fetch all examples for a given problem
-func GetProblemExamples(db *sql.DB, problemID string) ([]ProblemExample, error) {
-	rows, err := db.Query(`
-		SELECT id, problem_id, input, input_order, expected_output 
-		FROM problem_examples 
-		WHERE problem_id = ?`, problemID)
+// Fetch all examples for a given problem, hidden ones last
+func (s *sqlStore) GetProblemExamples(problemID string) ([]ProblemExample, error) {
+	rows, err := s.query(s.db, `
+		SELECT id, problem_id, input, input_order, expected_output, is_hidden, COALESCE(explanation, '')
+		FROM problem_examples
+		WHERE problem_id = ?
+		ORDER BY is_hidden, id`, problemID)
 	if err != nil {
 		return nil, err
 	}
@@ -191,6 +254,8 @@ func GetProblemExamples(db *sql.DB, problemID string) ([]ProblemExample, error)
 			&example.Input,
 			&example.InputOrder,
 			&example.ExpectedOutput,
+			&example.IsHidden,
+			&example.Explanation,
 		)
 		if err != nil {
 			return nil, err
@@ -204,3 +269,26 @@ func GetProblemExamples(db *sql.DB, problemID string) ([]ProblemExample, error)
 
 	return examples, nil
 }
+
+// Fetch the examples of a problem that are shown to users
+func (s *sqlStore) GetVisibleProblemExamples(problemID string) ([]ProblemExample, error) {
+	rows, err := s.query(s.db, `
+		SELECT input, input_order, expected_output, COALESCE(explanation, '')
+		FROM problem_examples
+		WHERE problem_id = ? AND is_hidden = ?
+		ORDER BY id`, problemID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []ProblemExample
+	for rows.Next() {
+		var example ProblemExample
+		if err := rows.Scan(&example.Input, &example.InputOrder, &example.ExpectedOutput, &example.Explanation); err != nil {
+			return nil, err
+		}
+		examples = append(examples, example)
+	}
+	return examples, rows.Err()
+}
@@ -23,15 +23,28 @@ func TestGetAllProblems(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"id", "name", "short_description", "long_description", "problem_seed", "examples", "difficulty", "attempts", "solves"}).
 					AddRow("1", "Problem 1", "Short Desc 1", "Long Desc 1", "Seed 1", "Example 1", "Easy", "10", "5").
 					AddRow("2", "Problem 2", "Short Desc 2", "Long Desc 2", "Seed 2", "Example 2", "Medium", "20", "10")
-				mock.ExpectQuery("^SELECT (.+) FROM problems$").WillReturnRows(rows)
+				mock.ExpectQuery("^SELECT (.+) FROM problems ORDER BY id$").WillReturnRows(rows)
 			},
 			expectedCode: http.StatusOK,
-			expectedBody: `[{"id":"1","name":"Problem 1","short_description":"Short Desc 1","long_description":"Long Desc 1","difficulty":"Easy","problem_seed":"Seed 1","examples":"Example 1","attempts":"10","solves":"5"},{"id":"2","name":"Problem 2","short_description":"Short Desc 2","long_description":"Long Desc 2","difficulty":"Medium","problem_seed":"Seed 2","examples":"Example 2","attempts":"20","solves":"10"}]`,
+			expectedBody: `[{"id":"1","name":"Problem 1","short_description":"Short Desc 1","long_description":"Long Desc 1","difficulty":"Easy","problem_seed":"Seed 1","examples":"Example 1","attempts":"10","solves":"5","acceptance_rate":50},{"id":"2","name":"Problem 2","short_description":"Short Desc 2","long_description":"Long Desc 2","difficulty":"Medium","problem_seed":"Seed 2","examples":"Example 2","attempts":"20","solves":"10","acceptance_rate":50}]`,
+		},
+		{
+			name: "DerivedExamples",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "short_description", "long_description", "problem_seed", "examples", "difficulty", "attempts", "solves"}).
+					AddRow("2", "Sum", "Short Desc", "Long Desc", "Seed", "", "Easy", "0", "0")
+				mock.ExpectQuery("^SELECT (.+) FROM problems ORDER BY id$").WillReturnRows(rows)
+				examples := sqlmock.NewRows([]string{"input", "input_order", "expected_output", "explanation"}).
+					AddRow(`{"x": 1, "y": 2}`, `["x", "y"]`, `{"result": 3}`, "1 plus 2 equals 3.")
+				mock.ExpectQuery("^SELECT (.+) FROM problem_examples WHERE problem_id = \\? AND is_hidden = \\? ORDER BY id$").WithArgs("2", false).WillReturnRows(examples)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"id":"2","name":"Sum","short_description":"Short Desc","long_description":"Long Desc","difficulty":"Easy","problem_seed":"Seed","examples":"[{\"input\":\"x = 1, y = 2\",\"output\":\"3\",\"explanation\":\"1 plus 2 equals 3.\"}]","attempts":"0","solves":"0","acceptance_rate":0}]`,
 		},
 		{
 			name: "DatabaseQueryError",
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery("^SELECT (.+) FROM problems$").WillReturnError(errors.New("query error"))
+				mock.ExpectQuery("^SELECT (.+) FROM problems ORDER BY id$").WillReturnError(errors.New("query error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: "Error fetching problems from database",
@@ -51,7 +64,7 @@ func TestGetAllProblems(t *testing.T) {
 			rec := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/problems", nil)
 
-			GetAllProblems(db, rec, req)
+			GetAllProblems(NewSQLiteStore(db), rec, req)
 
 			equals(t, tt.expectedCode, rec.Code)
 			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
@@ -76,15 +89,15 @@ func TestGetProblemNames(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"id", "name"}).
 					AddRow("1", "Problem 1").
 					AddRow("2", "Problem 2")
-				mock.ExpectQuery("^SELECT id, name FROM problems$").WillReturnRows(rows)
+				mock.ExpectQuery("^SELECT id, name FROM problems ORDER BY id$").WillReturnRows(rows)
 			},
 			expectedCode: http.StatusOK,
-			expectedBody: `[{"id":"1","name":"Problem 1","short_description":"","long_description":"","difficulty":"","problem_seed":"","examples":"","attempts":"","solves":""},{"id":"2","name":"Problem 2","short_description":"","long_description":"","difficulty":"","problem_seed":"","examples":"","attempts":"","solves":""}]`,
+			expectedBody: `[{"id":"1","name":"Problem 1","short_description":"","long_description":"","difficulty":"","problem_seed":"","examples":"","attempts":"","solves":"","acceptance_rate":0},{"id":"2","name":"Problem 2","short_description":"","long_description":"","difficulty":"","problem_seed":"","examples":"","attempts":"","solves":"","acceptance_rate":0}]`,
 		},
 		{
 			name: "DatabaseQueryError",
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery("^SELECT id, name FROM problems$").WillReturnError(errors.New("query error"))
+				mock.ExpectQuery("^SELECT id, name FROM problems ORDER BY id$").WillReturnError(errors.New("query error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: "Error fetching problems from database",
@@ -104,7 +117,7 @@ func TestGetProblemNames(t *testing.T) {
 			rec := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/problems/names", nil)
 
-			GetProblemNames(db, rec, req)
+			GetProblemNames(NewSQLiteStore(db), rec, req)
 
 			equals(t, tt.expectedCode, rec.Code)
 			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
@@ -115,3 +128,51 @@ func TestGetProblemNames(t *testing.T) {
 		})
 	}
 }
+
+func TestGetProblemConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockSetup   func(mock sqlmock.Sqlmock)
+		expected    ProblemConfig
+		expectError bool
+	}{
+		{
+			name: "SuccessfulFetch",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"name", "problem_seed", "compare_mode", "compare_tolerance", "checker_code", "problem_type", "test_file"}).
+					AddRow("Average", "func Average(values []float64) float64 {}", "float", 0.001, "", "function", "")
+				mock.ExpectQuery("^SELECT (.+) FROM problems WHERE id = \\?$").WithArgs("3").WillReturnRows(rows)
+			},
+			expected: ProblemConfig{Name: "Average", ProblemSeed: "func Average(values []float64) float64 {}", CompareMode: "float", Tolerance: 0.001, ProblemType: "function"},
+		},
+		{
+			name: "ProblemNotFound",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"name", "problem_seed", "compare_mode", "compare_tolerance", "checker_code", "problem_type", "test_file"})
+				mock.ExpectQuery("^SELECT (.+) FROM problems WHERE id = \\?$").WithArgs("3").WillReturnRows(rows)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			config, err := NewSQLiteStore(db).GetProblemConfig("3")
+
+			equals(t, tt.expectError, err != nil)
+			equals(t, tt.expected, config)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
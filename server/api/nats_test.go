@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is enough of a NATS server to test natsQueue: it delivers each
+// published message to one subscriber, taking turns
+type fakeNATS struct {
+	listener   net.Listener
+	token      string
+	maxPayload int
+	mu         sync.Mutex
+	conns      []net.Conn
+	subs       []fakeSubscription
+	next       int
+}
+
+type fakeSubscription struct {
+	conn net.Conn
+	sid  string
+}
+
+func startFakeNATS(t *testing.T, token string, maxPayload int) *fakeNATS {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+	server := &fakeNATS{listener: listener, token: token, maxPayload: maxPayload}
+	t.Cleanup(func() {
+		listener.Close()
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		for _, conn := range server.conns {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeNATS) url(userinfo string) string {
+	return "nats://" + userinfo + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":%d}\r\n", s.maxPayload)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "CONNECT":
+			if s.token != "" && !strings.Contains(line, `"auth_token":"`+s.token+`"`) {
+				io.WriteString(conn, "-ERR 'Authorization Violation'\r\n")
+				conn.Close()
+				return
+			}
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "SUB":
+			s.mu.Lock()
+			s.subs = append(s.subs, fakeSubscription{conn: conn, sid: fields[len(fields)-1]})
+			s.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			sub := s.subs[s.next%len(s.subs)]
+			s.next++
+			s.mu.Unlock()
+			fmt.Fprintf(sub.conn, "MSG %s %s %d\r\n%s", fields[1], sub.sid, size, payload)
+		}
+	}
+}
+
+// Wait until the server has seen the given number of subscriptions
+func (s *fakeNATS) waitForSubscribers(t *testing.T, count int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		subscribed := len(s.subs)
+		s.mu.Unlock()
+		if subscribed >= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d subscribers", count)
+}
+
+// Drop every client connection and forget their subscriptions
+func (s *fakeNATS) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns, s.subs = nil, nil
+}
+
+func TestNATSQueue(t *testing.T) {
+	server := startFakeNATS(t, "", 1<<20)
+
+	first, err := OpenQueue(server.url(""), 0)
+	ok(t, err)
+	defer first.Close()
+	second, err := OpenQueue(server.url(""), 0)
+	ok(t, err)
+	defer second.Close()
+	server.waitForSubscribers(t, 2)
+
+	// Each job goes to one of the queues sharing the subject
+	jobs := []Job{
+		{SubmissionID: 1, Submission: CodeSubmission{ProblemID: "2", Code: "func Sum(x, y int) int { return x + y }"}},
+		{SubmissionID: 2, Submission: CodeSubmission{ProblemID: "3", Code: "package main"}},
+	}
+	for _, job := range jobs {
+		ok(t, first.Enqueue(job))
+	}
+	equals(t, jobs[0], receiveJob(t, first))
+	equals(t, jobs[1], receiveJob(t, second))
+
+	ok(t, first.Close())
+	ok(t, first.Close())
+	_, open := <-first.Jobs()
+	equals(t, false, open)
+}
+
+func TestNATSQueueReconnect(t *testing.T) {
+	defer func(delay time.Duration) { natsReconnectDelay = delay }(natsReconnectDelay)
+	natsReconnectDelay = 10 * time.Millisecond
+
+	server := startFakeNATS(t, "", 1<<20)
+	queue, err := OpenQueue(server.url(""), 0)
+	ok(t, err)
+	defer queue.Close()
+	server.waitForSubscribers(t, 1)
+
+	// The queue subscribes again on a new connection and keeps delivering jobs
+	server.disconnect()
+	server.waitForSubscribers(t, 1)
+	job := Job{SubmissionID: 1, Submission: CodeSubmission{ProblemID: "2", Code: "package main"}}
+	ok(t, queue.Enqueue(job))
+	equals(t, job, receiveJob(t, queue))
+
+	ok(t, queue.Close())
+	_, open := <-queue.Jobs()
+	equals(t, false, open)
+}
+
+func TestNATSQueueErrors(t *testing.T) {
+	t.Run("AuthToken", func(t *testing.T) {
+		server := startFakeNATS(t, "secret", 1<<20)
+		_, err := OpenQueue(server.url("wrong@"), 0)
+		assert(t, err != nil, "expected a wrong token to be refused")
+
+		queue, err := OpenQueue(server.url("secret@"), 0)
+		ok(t, err)
+		queue.Close()
+	})
+
+	t.Run("MaxPayload", func(t *testing.T) {
+		server := startFakeNATS(t, "", 64)
+		queue, err := OpenQueue(server.url(""), 0)
+		ok(t, err)
+		defer queue.Close()
+
+		err = queue.Enqueue(Job{SubmissionID: 1, Submission: CodeSubmission{Code: strings.Repeat("x", 64)}})
+		assert(t, err != nil && strings.Contains(err.Error(), "max_payload"), "expected a max_payload error, got %v", err)
+	})
+
+	t.Run("NotListening", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		ok(t, err)
+		address := listener.Addr().String()
+		listener.Close()
+
+		_, err = OpenQueue("nats://"+address, 0)
+		assert(t, err != nil, "expected connecting to a closed port to fail")
+	})
+}
+
+func receiveJob(t *testing.T, queue Queue) Job {
+	select {
+	case job := <-queue.Jobs():
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a job")
+		return Job{}
+	}
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Files making up a problem pack directory. Go sources are stored exactly as
+// in the database, without a package clause except for the test file.
+const (
+	packMetadataFile    = "problem.json"
+	packDescriptionFile = "description.md"
+	packSeedFile        = "seed.go"
+	packTestsFile       = "tests.json"
+	packReferenceFile   = "reference.go"
+	packTestFile        = "solution_test.go"
+	packCheckerFile     = "checker.go"
+)
+
+// packMetadata is the problem.json of a problem pack
+type packMetadata struct {
+	Name             string          `json:"name"`
+	ShortDescription string          `json:"short_description"`
+	Difficulty       string          `json:"difficulty"`
+	ProblemType      string          `json:"problem_type"`
+	CompareMode      string          `json:"compare_mode"`
+	Tolerance        float64         `json:"compare_tolerance,omitempty"`
+	Examples         json.RawMessage `json:"examples,omitempty"` // Derived from visible tests when omitted
+}
+
+// packTest is one entry of a problem pack's tests.json. Inputs and outputs are
+// plain JSON values rather than the escaped strings stored in problem_examples.
+type packTest struct {
+	Input          json.RawMessage `json:"input"`
+	InputOrder     []string        `json:"input_order"`
+	ExpectedOutput json.RawMessage `json:"expected_output"`
+	Hidden         bool            `json:"hidden,omitempty"`
+	Explanation    string          `json:"explanation,omitempty"`
+}
+
+// Import every problem pack in the directory, one per subdirectory. Packs are
+// validated, and checked against their reference solutions if verify is set,
+// before any is written. Problems are matched to stored ones by name.
+func ImportProblemPacks(store Store, dir string, verify bool) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var defs []ProblemDefinition
+	for _, entry := range entries {
+		packDir := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(packDir, packMetadataFile)); !entry.IsDir() || err != nil {
+			continue
+		}
+		def, err := readProblemPack(packDir)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if err := validateDefinition(&def); err != nil {
+			return 0, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if verify && def.ReferenceSolution != "" {
+			failure, err := checkReferenceSolutionWrapper(def, def.Tests)
+			if err != nil {
+				return 0, fmt.Errorf("%s: failed to run reference solution: %w", entry.Name(), err)
+			}
+			if failure != "" {
+				return 0, fmt.Errorf("%s: reference solution failed: %s", entry.Name(), failure)
+			}
+		}
+		defs = append(defs, def)
+	}
+
+	if err := store.SaveProblemsByName(defs); err != nil {
+		return 0, err
+	}
+	return len(defs), nil
+}
+
+// Export every problem to a pack in its own subdirectory of dir, named after the problem
+func ExportProblemPacks(store Store, dir string) (int, error) {
+	ids, err := store.ListProblemIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		def, err := store.GetProblemDefinition(id)
+		if err != nil {
+			return 0, err
+		}
+		if def.Tests, err = store.GetProblemExamples(strconv.Itoa(id)); err != nil {
+			return 0, err
+		}
+		if err := writeProblemPack(filepath.Join(dir, def.Name), def); err != nil {
+			return 0, fmt.Errorf("%s: %w", def.Name, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// Read a problem definition from a pack directory
+func readProblemPack(dir string) (ProblemDefinition, error) {
+	var metadata packMetadata
+	if err := readPackJSON(filepath.Join(dir, packMetadataFile), &metadata); err != nil {
+		return ProblemDefinition{}, err
+	}
+	var tests []packTest
+	if err := readPackJSON(filepath.Join(dir, packTestsFile), &tests); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ProblemDefinition{}, err
+	}
+
+	def := ProblemDefinition{
+		Name:             metadata.Name,
+		ShortDescription: metadata.ShortDescription,
+		Difficulty:       metadata.Difficulty,
+		ProblemType:      metadata.ProblemType,
+		CompareMode:      metadata.CompareMode,
+		Tolerance:        metadata.Tolerance,
+		Tests:            []ProblemExample{},
+	}
+
+	// Examples written as a JSON string are stored as that string, anything else as compact JSON
+	if len(metadata.Examples) > 0 {
+		if err := json.Unmarshal(metadata.Examples, &def.Examples); err != nil {
+			if def.Examples, err = compactPackJSON(metadata.Examples); err != nil {
+				return ProblemDefinition{}, fmt.Errorf("%s examples: %w", packMetadataFile, err)
+			}
+		}
+	}
+
+	files := map[string]*string{
+		packDescriptionFile: &def.LongDescription,
+		packSeedFile:        &def.ProblemSeed,
+		packReferenceFile:   &def.ReferenceSolution,
+		packTestFile:        &def.TestFile,
+		packCheckerFile:     &def.Checker,
+	}
+	for name, field := range files {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return ProblemDefinition{}, err
+		}
+		*field = string(content)
+	}
+
+	for i, test := range tests {
+		inputOrder := test.InputOrder
+		if inputOrder == nil {
+			inputOrder = []string{}
+		}
+		order, _ := json.Marshal(inputOrder)
+		input, err := compactPackJSON(test.Input)
+		if err != nil {
+			return ProblemDefinition{}, fmt.Errorf("test %d input: %w", i+1, err)
+		}
+		expected, err := compactPackJSON(test.ExpectedOutput)
+		if err != nil {
+			return ProblemDefinition{}, fmt.Errorf("test %d expected_output: %w", i+1, err)
+		}
+		def.Tests = append(def.Tests, ProblemExample{
+			Input:          input,
+			InputOrder:     string(order),
+			ExpectedOutput: expected,
+			IsHidden:       test.Hidden,
+			Explanation:    test.Explanation,
+		})
+	}
+	return def, nil
+}
+
+// Write a problem definition to a pack directory, removing optional files it doesn't use
+func writeProblemPack(dir string, def ProblemDefinition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	metadata := packMetadata{
+		Name:             def.Name,
+		ShortDescription: def.ShortDescription,
+		Difficulty:       def.Difficulty,
+		ProblemType:      def.ProblemType,
+		CompareMode:      def.CompareMode,
+		Tolerance:        def.Tolerance,
+	}
+	if def.Examples != "" {
+		if json.Valid([]byte(def.Examples)) {
+			metadata.Examples = json.RawMessage(def.Examples)
+		} else {
+			metadata.Examples, _ = json.Marshal(def.Examples)
+		}
+	}
+	if err := writePackJSON(filepath.Join(dir, packMetadataFile), metadata); err != nil {
+		return err
+	}
+
+	tests := make([]packTest, len(def.Tests))
+	for i, test := range def.Tests {
+		tests[i] = packTest{
+			Input:          json.RawMessage(test.Input),
+			ExpectedOutput: json.RawMessage(test.ExpectedOutput),
+			Hidden:         test.IsHidden,
+			Explanation:    test.Explanation,
+		}
+		if err := json.Unmarshal([]byte(test.InputOrder), &tests[i].InputOrder); err != nil {
+			return fmt.Errorf("test %d input_order: %w", test.ID, err)
+		}
+		if !json.Valid(tests[i].Input) || !json.Valid(tests[i].ExpectedOutput) {
+			return fmt.Errorf("test %d is not valid JSON", test.ID)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, packTestsFile), formatPackTests(tests), 0644); err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		packDescriptionFile: def.LongDescription,
+		packSeedFile:        def.ProblemSeed,
+		packReferenceFile:   def.ReferenceSolution,
+		packTestFile:        def.TestFile,
+		packCheckerFile:     def.Checker,
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if files[name] == "" {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return err
+		}
+	}
+	log.Printf("Exported %s to %s", def.Name, dir)
+	return nil
+}
+
+// Decode a JSON file of a problem pack
+func readPackJSON(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Encode a value as an indented JSON file of a problem pack
+func writePackJSON(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Format tests.json with one field per line, keeping each value on a single
+// line so that inputs read like the examples shown to users
+func formatPackTests(tests []packTest) []byte {
+	var out bytes.Buffer
+	out.WriteString("[")
+	for i, test := range tests {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		fields := []string{
+			packField("input", test.Input),
+			packField("input_order", test.InputOrder),
+			packField("expected_output", test.ExpectedOutput),
+		}
+		if test.Hidden {
+			fields = append(fields, packField("hidden", true))
+		}
+		if test.Explanation != "" {
+			fields = append(fields, packField("explanation", test.Explanation))
+		}
+		out.WriteString("\n  {\n    " + strings.Join(fields, ",\n    ") + "\n  }")
+	}
+	out.WriteString("\n]\n")
+	return out.Bytes()
+}
+
+// Format a single "key": value line of tests.json
+func packField(key string, value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return strconv.Quote(key) + ": " + string(encoded)
+}
+
+// Compact a JSON value of a pack for storage, rejecting missing values
+func compactPackJSON(value json.RawMessage) (string, error) {
+	if len(value) == 0 {
+		return "", fmt.Errorf("missing value")
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, value); err != nil {
+		return "", err
+	}
+	return compacted.String(), nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProblemPackRoundTrip(t *testing.T) {
+	def := ProblemDefinition{
+		Name:              "Sum",
+		ShortDescription:  "Add two numbers",
+		LongDescription:   "Return the sum of x and y.\n",
+		Difficulty:        "easy",
+		ProblemSeed:       sumSeed,
+		Examples:          `[{"input":"x = 1, y = 2","output":"3"}]`,
+		ProblemType:       ProblemTypeFunction,
+		CompareMode:       CompareExact,
+		ReferenceSolution: "func Sum(x, y int) int { return x + y }",
+		Tests: []ProblemExample{
+			{ID: 4, Input: `{"x":1,"y":2}`, InputOrder: `["x","y"]`, ExpectedOutput: `3`},
+			{ID: 9, Input: `{"x":2,"y":2}`, InputOrder: `["x","y"]`, ExpectedOutput: `4`, IsHidden: true, Explanation: "2 + 2"},
+		},
+	}
+
+	dir := filepath.Join(t.TempDir(), "Sum")
+	// A stale checker from an earlier export is removed
+	ok(t, os.MkdirAll(dir, 0755))
+	ok(t, os.WriteFile(filepath.Join(dir, packCheckerFile), []byte("func Check() {}"), 0644))
+
+	ok(t, writeProblemPack(dir, def))
+	_, err := os.Stat(filepath.Join(dir, packCheckerFile))
+	assert(t, os.IsNotExist(err), "expected %s to be removed, got %v", packCheckerFile, err)
+
+	read, err := readProblemPack(dir)
+	ok(t, err)
+	for i := range def.Tests {
+		def.Tests[i].ID = 0
+	}
+	equals(t, def, read)
+}
+
+func TestReadProblemPack(t *testing.T) {
+	dir := t.TempDir()
+	ok(t, os.WriteFile(filepath.Join(dir, packMetadataFile), []byte(`{"name": "Sum", "difficulty": "easy", "examples": "x = 1, y = 2"}`), 0644))
+	ok(t, os.WriteFile(filepath.Join(dir, packSeedFile), []byte(sumSeed), 0644))
+	ok(t, os.WriteFile(filepath.Join(dir, packTestsFile), []byte(`[
+  {"input": {"x": 1,
+             "y": 2}, "input_order": ["x", "y"], "expected_output": 3}
+]`), 0644))
+
+	def, err := readProblemPack(dir)
+	ok(t, err)
+	equals(t, "x = 1, y = 2", def.Examples)
+	equals(t, []ProblemExample{{Input: `{"x":1,"y":2}`, InputOrder: `["x","y"]`, ExpectedOutput: `3`}}, def.Tests)
+
+	ok(t, os.WriteFile(filepath.Join(dir, packTestsFile), []byte(`[{"input": {}}]`), 0644))
+	_, err = readProblemPack(dir)
+	assert(t, err != nil && err.Error() == "test 1 expected_output: missing value", "unexpected error: %v", err)
+}
+
+func TestFormatPackTests(t *testing.T) {
+	tests := []packTest{
+		{Input: []byte(`{"nums":[2,7],"target":9}`), InputOrder: []string{"nums", "target"}, ExpectedOutput: []byte(`[0,1]`)},
+		{Input: []byte(`{"s":"a"}`), InputOrder: []string{"s"}, ExpectedOutput: []byte(`true`), Hidden: true, Explanation: "single letter"},
+	}
+	expected := `[
+  {
+    "input": {"nums":[2,7],"target":9},
+    "input_order": ["nums","target"],
+    "expected_output": [0,1]
+  },
+  {
+    "input": {"s":"a"},
+    "input_order": ["s"],
+    "expected_output": true,
+    "hidden": true,
+    "explanation": "single letter"
+  }
+]
+`
+	equals(t, expected, string(formatPackTests(tests)))
+	equals(t, "[\n]\n", string(formatPackTests(nil)))
+}
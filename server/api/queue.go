@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Queue kept inside the server process, used when QUEUE_URL is not set
+const MemoryQueueURL = "memory://"
+
+var (
+	errQueueFull   = errors.New("queue is full")
+	errQueueClosed = errors.New("queue is closed")
+)
+
+// Job asks for a stored submission to be graded
+type Job struct {
+	SubmissionID int64          `json:"submission_id"`
+	Submission   CodeSubmission `json:"submission"`
+}
+
+// Queue carries jobs from the handler accepting submissions to the runners grading them
+type Queue interface {
+	// Add a job, failing if the queue can't take more
+	Enqueue(job Job) error
+	// Jobs to grade, closed along with the queue
+	Jobs() <-chan Job
+	Close() error
+}
+
+// Open the queue at a URL: memory:// for one inside the server, or
+// nats://host:4222 for a subject shared by every server connected to NATS
+func OpenQueue(url string, size int) (Queue, error) {
+	switch {
+	case url == MemoryQueueURL:
+		return NewMemoryQueue(size), nil
+	case strings.HasPrefix(url, "nats://"):
+		return DialNATSQueue(url)
+	}
+	return nil, fmt.Errorf("unsupported queue URL %q (expected %s or nats://host:port)", url, MemoryQueueURL)
+}
+
+// memoryQueue is a Queue on a buffered channel. Its jobs are lost when the
+// server stops, and requeued from the database when it starts again.
+type memoryQueue struct {
+	mu     sync.Mutex
+	jobs   chan Job
+	closed bool
+}
+
+// Create a Queue inside the server that holds up to size jobs
+func NewMemoryQueue(size int) Queue {
+	return &memoryQueue{jobs: make(chan Job, size)}
+}
+
+func (q *memoryQueue) Enqueue(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errQueueClosed
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+func (q *memoryQueue) Jobs() <-chan Job {
+	return q.jobs
+}
+
+func (q *memoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	return nil
+}
+
+// JobRunner grades the jobs of a queue, a fixed number at a time
+type JobRunner struct {
+	store Store
+	queue Queue
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// Start grading jobs from the queue with the given number of goroutines
+func StartJobRunner(store Store, queue Queue, workers int) *JobRunner {
+	runner := &JobRunner{store: store, queue: queue, stop: make(chan struct{})}
+	for i := 0; i < workers; i++ {
+		runner.wg.Add(1)
+		go runner.run()
+	}
+	return runner
+}
+
+// Grade jobs until the runner is stopped or the queue closed
+func (runner *JobRunner) run() {
+	defer runner.wg.Done()
+	for {
+		select {
+		case <-runner.stop:
+			return
+		case job, ok := <-runner.queue.Jobs():
+			if !ok {
+				return
+			}
+			gradeJob(runner.store, job)
+		}
+	}
+}
+
+// Stop taking jobs and wait for the ones being graded to finish, or for ctx to
+// be done. Jobs still in the queue are left to it.
+func (runner *JobRunner) Stop(ctx context.Context) error {
+	close(runner.stop)
+	done := make(chan struct{})
+	go func() {
+		runner.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Periodically requeue the submissions whose status hasn't changed for the
+// given time, such as jobs a NATS server dropped or a server stopped part way
+// through grading, until the runner is stopped
+func (runner *JobRunner) RequeueStale(interval, after time.Duration) {
+	runner.wg.Add(1)
+	go func() {
+		defer runner.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runner.stop:
+				return
+			case <-ticker.C:
+				requeued, err := RequeueUnfinishedSubmissions(runner.store, runner.queue, after)
+				if err != nil {
+					log.Printf("Error requeueing stale submissions: %v", err)
+				}
+				if requeued > 0 {
+					log.Printf("Requeued %d stale submissions", requeued)
+				}
+			}
+		}
+	}()
+}
+
+// Queue the unfinished submissions whose status hasn't changed for the given
+// time, all of them when it is zero. Submissions another server requeues
+// first are skipped. Returns how many were queued.
+func RequeueUnfinishedSubmissions(store Store, queue Queue, after time.Duration) (int, error) {
+	changedBefore := time.Now().Add(-after)
+	submissions, err := store.ListUnfinishedSubmissions(changedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+
+	for _, submission := range submissions {
+		job, err := newJob(store, submission)
+		if err != nil {
+			// The problem may have been deleted since
+			log.Printf("Failed to requeue submission %d: %v", submission.ID, err)
+			if err := store.CompleteSubmission(Submission{ID: submission.ID, Result: SubmissionError}); err != nil {
+				log.Printf("Failed to record submission %d as failed: %v", submission.ID, err)
+			}
+			continue
+		}
+		if err := store.RequeueSubmission(submission.ID, changedBefore); errors.Is(err, errNotFound) {
+			continue
+		} else if err != nil {
+			return requeued, err
+		}
+		if err := queue.Enqueue(job); err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+	return requeued, nil
+}
+
+// Build the job grading a stored submission, with its problem's current test cases and settings
+func newJob(store Store, submission Submission) (Job, error) {
+	codeSubmission := CodeSubmission{ProblemID: submission.ProblemID, Code: submission.Code}
+	examples, err := GetProblemExamplesWrapper(store, submission.ProblemID)
+	if err != nil {
+		return Job{}, err
+	}
+	codeSubmission.ProblemExamples = examples
+
+	config, err := GetProblemConfigWrapper(store, submission.ProblemID)
+	if err != nil {
+		return Job{}, err
+	}
+	applyProblemConfig(&codeSubmission, config)
+	return Job{SubmissionID: submission.ID, Submission: codeSubmission}, nil
+}
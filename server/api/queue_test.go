@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	queue := NewMemoryQueue(1)
+	ok(t, queue.Enqueue(Job{SubmissionID: 1}))
+	equals(t, errQueueFull, queue.Enqueue(Job{SubmissionID: 2}))
+	equals(t, Job{SubmissionID: 1}, <-queue.Jobs())
+
+	ok(t, queue.Enqueue(Job{SubmissionID: 3}))
+	ok(t, queue.Close())
+	ok(t, queue.Close())
+	equals(t, errQueueClosed, queue.Enqueue(Job{SubmissionID: 4}))
+
+	// Jobs queued before closing are still delivered
+	equals(t, Job{SubmissionID: 3}, <-queue.Jobs())
+	_, open := <-queue.Jobs()
+	equals(t, false, open)
+}
+
+func TestOpenQueue(t *testing.T) {
+	queue, err := OpenQueue(MemoryQueueURL, 1)
+	ok(t, err)
+	queue.Close()
+
+	_, err = OpenQueue("redis://localhost:6379", 1)
+	assert(t, err != nil, "expected an unsupported queue URL to fail")
+}
+
+func TestJobRunner(t *testing.T) {
+	originalCallWorkerService := callWorkerServiceWrapper
+	defer func() { callWorkerServiceWrapper = originalCallWorkerService }()
+	callWorkerServiceWrapper = mockCallWorkerService
+
+	store := newQueueTestStore(t)
+	queue := NewMemoryQueue(10)
+	runner := StartJobRunner(store, queue, 2)
+
+	var ids []int64
+	for _, code := range []string{"valid code", "fail"} {
+		id, err := store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: code, Status: SubmissionQueued})
+		ok(t, err)
+		ok(t, queue.Enqueue(Job{SubmissionID: id, Submission: CodeSubmission{ProblemID: "2", Code: code}}))
+		ids = append(ids, id)
+	}
+
+	waitForSubmissions(t, store)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ok(t, runner.Stop(ctx))
+
+	owner := submissionOwner{sessionID: "abc123"}
+	for i, result := range []string{"PASSED", SubmissionError} {
+		submission, err := store.GetSubmission(fmt.Sprint(ids[i]), owner)
+		ok(t, err)
+		equals(t, SubmissionDone, submission.Status)
+		equals(t, result, submission.Result)
+	}
+}
+
+func TestRequeueUnfinishedSubmissions(t *testing.T) {
+	store := newQueueTestStore(t)
+	queued, err := store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: "queued", Status: SubmissionQueued})
+	ok(t, err)
+	running, err := store.SaveSubmission(Submission{ProblemID: "3", SessionID: "abc123", Code: "running", Status: SubmissionQueued})
+	ok(t, err)
+	ok(t, store.SetSubmissionStatus(running, SubmissionRunning))
+	_, err = store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: "done", Status: SubmissionDone, Result: "PASSED"})
+	ok(t, err)
+
+	queue := NewMemoryQueue(10)
+	count, err := RequeueUnfinishedSubmissions(store, queue, 0)
+	ok(t, err)
+	equals(t, 2, count)
+
+	for _, id := range []int64{queued, running} {
+		job := <-queue.Jobs()
+		equals(t, id, job.SubmissionID)
+		assert(t, len(job.Submission.ProblemExamples) > 0, "expected the job for submission %d to carry its examples", id)
+	}
+	unfinished, err := store.ListUnfinishedSubmissions(time.Now())
+	ok(t, err)
+	equals(t, []string{SubmissionQueued, SubmissionQueued}, []string{unfinished[0].Status, unfinished[1].Status})
+
+	// A full queue stops the requeue, leaving the rest for the next start
+	count, err = RequeueUnfinishedSubmissions(store, NewMemoryQueue(1), 0)
+	equals(t, errQueueFull, err)
+	equals(t, 1, count)
+}
+
+func TestRequeueStale(t *testing.T) {
+	store := newQueueTestStore(t)
+	id, err := store.SaveSubmission(Submission{ProblemID: "2", SessionID: "abc123", Code: "lost", Status: SubmissionQueued})
+	ok(t, err)
+
+	// A submission that only just changed isn't stale yet
+	count, err := RequeueUnfinishedSubmissions(store, NewMemoryQueue(1), time.Hour)
+	ok(t, err)
+	equals(t, 0, count)
+
+	queue := NewMemoryQueue(10)
+	runner := StartJobRunner(store, queue, 0)
+	runner.RequeueStale(10*time.Millisecond, 0)
+	select {
+	case job := <-queue.Jobs():
+		equals(t, id, job.SubmissionID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stale submission to be requeued")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ok(t, runner.Stop(ctx))
+}
+
+// Open a migrated SQLite store with the seeded problems
+func newQueueTestStore(t *testing.T) Store {
+	store, err := OpenStore("sqlite://" + filepath.Join(t.TempDir(), "test.sqlite3"))
+	ok(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	migrations, err := LoadMigrations(os.DirFS(filepath.Join("..", MigrationsDir, store.Dialect())))
+	ok(t, err)
+	_, err = store.MigrateUp(migrations)
+	ok(t, err)
+	return store
+}
+
+// Wait for the runner to grade every queued submission
+func waitForSubmissions(t *testing.T, store Store) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		unfinished, err := store.ListUnfinishedSubmissions(time.Now())
+		ok(t, err)
+		if len(unfinished) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for submissions to be graded")
+}
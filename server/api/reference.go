@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Run a problem's reference solution against the given tests through the
+// worker. Returns a description of the first failure, empty if every test
+// passed, or an error if the worker couldn't run it.
+func checkReferenceSolution(def ProblemDefinition, tests []ProblemExample) (string, error) {
+	// Tests being authored have no IDs yet, so results are matched by position
+	examples := make([]ProblemExample, len(tests))
+	for i, test := range tests {
+		examples[i] = test
+		examples[i].ID = i + 1
+	}
+
+	codeOutput, err := callWorkerServiceWrapper(CodeSubmission{
+		Code:            def.ReferenceSolution,
+		Problem:         def.Name,
+		ProblemSeed:     def.ProblemSeed,
+		ProblemType:     def.ProblemType,
+		ProblemExamples: examples,
+		CompareMode:     def.CompareMode,
+		Tolerance:       def.Tolerance,
+		Checker:         def.Checker,
+		TestFile:        def.TestFile,
+	}, func(Progress) {})
+	if err != nil {
+		return "", err
+	}
+	if codeOutput.Result == TestPassed && codeOutput.TestPassed == codeOutput.TestCount {
+		return "", nil
+	}
+	return describeFailure(codeOutput, def.ProblemType), nil
+}
+
+// Describe why a run didn't pass, e.g. "test 2: expected [0,1], got [1,0]"
+func describeFailure(codeOutput CodeOutput, problemType string) string {
+	if len(codeOutput.Diagnostics) > 0 {
+		diagnostic := codeOutput.Diagnostics[0]
+		return fmt.Sprintf("%s at line %d: %s", codeOutput.Verdict, diagnostic.Line, diagnostic.Message)
+	}
+	for _, result := range codeOutput.Results {
+		if result.Status == TestPassed {
+			continue
+		}
+		name := fmt.Sprintf("test %d", result.ID)
+		if problemType == ProblemTypeGoTest {
+			// Results of go test problems name the test instead of an example
+			name = result.Input
+		}
+		if result.Status == TestNotRun {
+			return fmt.Sprintf("%s: not run (%s)", name, codeOutput.Verdict)
+		}
+		if result.Expected == "" {
+			return fmt.Sprintf("%s: %s", name, strings.TrimSpace(result.Actual))
+		}
+		return fmt.Sprintf("%s: expected %s, got %s", name, result.Expected, result.Actual)
+	}
+	return fmt.Sprintf("verdict %s, %d of %d tests passed", codeOutput.Verdict, codeOutput.TestPassed, codeOutput.TestCount)
+}
+
+// Wrapper function for checkReferenceSolution
+var checkReferenceSolutionWrapper func(def ProblemDefinition, tests []ProblemExample) (string, error) = checkReferenceSolution
+
+// Check the problem's reference solution, if it has one, against the tests,
+// responding with an error unless it passes all of them
+func verifyReferenceSolution(w http.ResponseWriter, def ProblemDefinition, tests []ProblemExample) bool {
+	if strings.TrimSpace(def.ReferenceSolution) == "" {
+		return true
+	}
+
+	failure, err := checkReferenceSolutionWrapper(def, tests)
+	if err != nil {
+		status, message := workerErrorResponse(err)
+		respondWithError(w, status, "Failed to run reference solution: "+message)
+		log.Printf("Worker service error: %v", err)
+		return false
+	}
+	if failure != "" {
+		respondWithError(w, http.StatusBadRequest, "Reference solution failed: "+failure)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckReferenceSolution(t *testing.T) {
+	originalCallWorkerService := callWorkerServiceWrapper
+	defer func() { callWorkerServiceWrapper = originalCallWorkerService }()
+
+	def := ProblemDefinition{Name: "Sum", ProblemSeed: sumSeed, ProblemType: ProblemTypeFunction, CompareMode: CompareExact, ReferenceSolution: "func Sum(x, y int) int { return x + y }"}
+	tests := []ProblemExample{
+		{Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"sum": 3}`},
+		{ID: 7, Input: `{"x": 2, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"sum": 5}`},
+	}
+
+	cases := []struct {
+		name            string
+		output          CodeOutput
+		err             error
+		expectedFailure string
+		expectedErr     bool
+	}{
+		{
+			name:   "Passes",
+			output: CodeOutput{Result: TestPassed, Verdict: VerdictAccepted, TestCount: 2, TestPassed: 2},
+		},
+		{
+			name: "WrongAnswer",
+			output: CodeOutput{Result: TestFailed, Verdict: VerdictWrongAnswer, TestCount: 2, TestPassed: 1, Results: []TestResult{
+				{ID: 1, Status: TestPassed},
+				{ID: 2, Status: TestFailed, Expected: `{"sum": 5}`, Actual: "4"},
+			}},
+			expectedFailure: `test 2: expected {"sum": 5}, got 4`,
+		},
+		{
+			name:            "CompileError",
+			output:          CodeOutput{Result: TestFailed, Verdict: VerdictCompileError, Diagnostics: []Diagnostic{{Line: 1, Column: 30, Message: "undefined: z"}}},
+			expectedFailure: "COMPILE_ERROR at line 1: undefined: z",
+		},
+		{
+			name:        "WorkerUnavailable",
+			err:         errors.New("connection refused"),
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			callWorkerServiceWrapper = func(codeSubmission CodeSubmission, report func(Progress)) (CodeOutput, error) {
+				equals(t, def.ReferenceSolution, codeSubmission.Code)
+				equals(t, []int{1, 2}, []int{codeSubmission.ProblemExamples[0].ID, codeSubmission.ProblemExamples[1].ID})
+				return tt.output, tt.err
+			}
+
+			failure, err := checkReferenceSolution(def, tests)
+			equals(t, tt.expectedErr, err != nil)
+			equals(t, tt.expectedFailure, failure)
+		})
+	}
+}
+
+func TestDescribeFailure(t *testing.T) {
+	output := CodeOutput{Result: TestFailed, Verdict: VerdictWrongAnswer, TestCount: 1, Results: []TestResult{
+		{ID: 1, Status: TestFailed, Input: "TestSum", Actual: "sum_test.go:9: Sum(1, 2) = 4\n"},
+	}}
+	equals(t, "TestSum: sum_test.go:9: Sum(1, 2) = 4", describeFailure(output, ProblemTypeGoTest))
+
+	output = CodeOutput{Result: "TIMEOUT", Verdict: VerdictTimeout, TestCount: 2, TestPassed: 1, Results: []TestResult{
+		{ID: 1, Status: TestPassed},
+		{ID: 2, Status: TestNotRun},
+	}}
+	equals(t, "test 2: not run (TIMEOUT)", describeFailure(output, ProblemTypeFunction))
+}
+
+func TestVerifyReferenceSolution(t *testing.T) {
+	originalCheckReferenceSolution := checkReferenceSolutionWrapper
+	defer func() { checkReferenceSolutionWrapper = originalCheckReferenceSolution }()
+
+	def := ProblemDefinition{Name: "Sum", ProblemSeed: sumSeed, ReferenceSolution: "func Sum(x, y int) int { return x + y }"}
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"InvalidProblem", &WorkerError{Code: ErrorInvalidProblem, Message: `unsupported problem type: "x"`}, http.StatusUnprocessableEntity,
+			`{"error":"Failed to run reference solution: Invalid problem data: unsupported problem type: \"x\""}`},
+		{"WorkerTimeout", &WorkerError{Code: ErrorWorkerTimeout, Message: "no result within 1m0s"}, http.StatusGatewayTimeout,
+			`{"error":"Failed to run reference solution: Worker service timed out"}`},
+		{"WorkerDown", &WorkerError{Code: ErrorWorkerUnavailable, Message: "connection refused", Retryable: true}, http.StatusBadGateway,
+			`{"error":"Failed to run reference solution: Worker service unavailable"}`},
+		{"WorkerFailed", &WorkerError{Code: ErrorInternal, Message: "failed to create workspace", Retryable: true}, http.StatusBadGateway,
+			`{"error":"Failed to run reference solution: Worker service unavailable"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkReferenceSolutionWrapper = func(def ProblemDefinition, tests []ProblemExample) (string, error) {
+				return "", tt.err
+			}
+
+			recorder := httptest.NewRecorder()
+			equals(t, false, verifyReferenceSolution(recorder, def, nil))
+			equals(t, tt.expectedCode, recorder.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(recorder.Body.String()))
+		})
+	}
+}
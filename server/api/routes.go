@@ -1,30 +1,113 @@
 package api
 
 import (
-	"database/sql"
 	"net/http"
 )
 
-func GetAllProblemsHandler(db *sql.DB) http.HandlerFunc {
+func GetAllProblemsHandler(store Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		GetAllProblems(db, w, r)
+		GetAllProblems(store, w, r)
 	}
 }
 
-func GetProblemNamesHandler(db *sql.DB) http.HandlerFunc {
+func GetProblemNamesHandler(store Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		GetProblemNames(db, w, r)
+		GetProblemNames(store, w, r)
 	}
 }
 
-func GetProblemDetailsHandler(db *sql.DB) http.HandlerFunc {
+func GetProblemDetailsHandler(store Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		GetProblemDetails(db, w, r)
+		GetProblemDetails(store, w, r)
 	}
 }
 
-func ExecuteCodeHandler(db *sql.DB) http.HandlerFunc {
+func ExecuteCodeHandler(store Store, queue Queue) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ExecuteCode(db, w, r)
+		ExecuteCode(store, queue, w, r)
 	}
 }
+
+func RegisterHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RegisterUser(store, w, r)
+	}
+}
+
+func LoginHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		LoginUser(store, w, r)
+	}
+}
+
+func LogoutHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		LogoutUser(store, w, r)
+	}
+}
+
+func GetSubmissionsHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		GetSubmissions(store, w, r)
+	}
+}
+
+func GetSubmissionHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		GetSubmission(store, w, r)
+	}
+}
+
+func GetSubmissionEventsHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		GetSubmissionEvents(store, w, r)
+	}
+}
+
+func GetProblemSubmissionsHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		GetProblemSubmissions(store, w, r)
+	}
+}
+
+func CreateProblemHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		CreateProblem(store, w, r)
+	})
+}
+
+func UpdateProblemHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		UpdateProblem(store, w, r)
+	})
+}
+
+func DeleteProblemHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		DeleteProblem(store, w, r)
+	})
+}
+
+func GetProblemTestsHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		GetProblemTests(store, w, r)
+	})
+}
+
+func CreateProblemTestHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		CreateProblemTest(store, w, r)
+	})
+}
+
+func UpdateProblemTestHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		UpdateProblemTest(store, w, r)
+	})
+}
+
+func DeleteProblemTestHandler(store Store) http.HandlerFunc {
+	return RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		DeleteProblemTest(store, w, r)
+	})
+}
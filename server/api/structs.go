@@ -1,16 +1,57 @@
 package api
 
+import (
+	"fmt"
+	"time"
+)
+
 // Problem represents a LeetCode-style problem
 type Problem struct {
-	ID               string `json:"id"`
-	Name             string `json:"name"`
-	ShortDescription string `json:"short_description"`
-	LongDescription  string `json:"long_description"`
-	Difficulty       string `json:"difficulty"`
-	ProblemSeed      string `json:"problem_seed"`
-	Examples         string `json:"examples"`
-	Attempts         string `json:"attempts"`
-	Solves           string `json:"solves"`
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	ShortDescription string  `json:"short_description"`
+	LongDescription  string  `json:"long_description"`
+	Difficulty       string  `json:"difficulty"`
+	ProblemSeed      string  `json:"problem_seed"`
+	Examples         string  `json:"examples"`
+	Attempts         string  `json:"attempts"`
+	Solves           string  `json:"solves"`
+	AcceptanceRate   float64 `json:"acceptance_rate"` // Percentage of attempts by distinct solvers
+}
+
+// User represents a registered user, without their password
+type User struct {
+	ID             int    `json:"id"`
+	Username       string `json:"username"`
+	Email          string `json:"email,omitempty"`
+	IsAdmin        bool   `json:"is_admin,omitempty"`
+	SessionVersion int    `json:"-"` // Sessions signed with an older version have been revoked
+}
+
+// Credentials is the body of a registration or login request
+type Credentials struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// ProblemDefinition is a problem as written by an admin, with its grading
+// settings and test cases
+type ProblemDefinition struct {
+	ID                int64            `json:"id"`
+	Name              string           `json:"name"`
+	ShortDescription  string           `json:"short_description"`
+	LongDescription   string           `json:"long_description"`
+	Difficulty        string           `json:"difficulty"`
+	ProblemSeed       string           `json:"problem_seed"`
+	Examples          string           `json:"examples,omitempty"` // Derived from visible tests when empty
+	ProblemType       string           `json:"problem_type"`
+	CompareMode       string           `json:"compare_mode"`
+	Tolerance         float64          `json:"compare_tolerance"`
+	Checker           string           `json:"checker_code,omitempty"`
+	TestFile          string           `json:"test_file,omitempty"`
+	ReferenceSolution string           `json:"reference_solution,omitempty"` // Never shown to users
+	Tests             []ProblemExample `json:"tests"`
 }
 
 // ProblemExample represents a single input to a user function and the expected return
@@ -20,6 +61,26 @@ type ProblemExample struct {
 	Input          string `json:"input"`
 	InputOrder     string `json:"input_order"`
 	ExpectedOutput string `json:"expected_output"`
+	IsHidden       bool   `json:"is_hidden"`
+	Explanation    string `json:"explanation,omitempty"`
+}
+
+// ExampleDisplay is one entry of the examples shown alongside a problem
+type ExampleDisplay struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Explanation string `json:"explanation"`
+}
+
+// ProblemConfig holds the per-problem settings the worker needs to grade a submission
+type ProblemConfig struct {
+	Name        string  `json:"name"`
+	ProblemSeed string  `json:"problem_seed"`
+	CompareMode string  `json:"compare_mode"`
+	Tolerance   float64 `json:"tolerance"`
+	Checker     string  `json:"checker"`
+	ProblemType string  `json:"problem_type"`
+	TestFile    string  `json:"test_file"`
 }
 
 // CodeReqeuest represents a user generated code snippet with test validation
@@ -27,15 +88,148 @@ type CodeSubmission struct {
 	Code            string           `json:"code"`
 	ProblemID       string           `json:"problem_id"`
 	Problem         string           `json:"problem"`
+	ProblemSeed     string           `json:"problem_seed,omitempty"`
+	ProblemType     string           `json:"problem_type,omitempty"`
 	ProblemExamples []ProblemExample `json:"problem_examples"`
+	CompareMode     string           `json:"compare_mode,omitempty"`
+	Tolerance       float64          `json:"tolerance,omitempty"`
+	Checker         string           `json:"checker,omitempty"`
+	TestFile        string           `json:"test_file,omitempty"`
+}
+
+// Statuses of an individual test case
+const (
+	TestPassed = "PASSED"
+	TestFailed = "FAILED"
+	TestNotRun = "NOT_RUN"
+)
+
+// TestResult represents the outcome of running a solution against a single example
+type TestResult struct {
+	ID         int     `json:"id"`
+	Status     string  `json:"status"`
+	Input      string  `json:"input"`
+	Expected   string  `json:"expected"`
+	Actual     string  `json:"actual"`
+	DurationMs float64 `json:"durationMs"`
+	Hidden     bool    `json:"hidden,omitempty"`
 }
 
 // CodeOutput respresents the results of a test execution
 type CodeOutput struct {
-	TestCount  int    `json:"testCount"`
-	TestPassed int    `json:"testPassed"`
-	Output     string `json:"output"`
-	Input      string `json:"input"`
-	Expected   string `json:"expected"`
-	Result     string `json:"result"`
+	TestCount   int          `json:"testCount"`
+	TestPassed  int          `json:"testPassed"`
+	Output      string       `json:"output"`
+	Input       string       `json:"input"`
+	Expected    string       `json:"expected"`
+	Stdout      string       `json:"stdout,omitempty"`
+	Results     []TestResult `json:"results"`
+	Result      string       `json:"result"`
+	Verdict     string       `json:"verdict"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Diagnostic represents a compiler or runtime error located in the user's code
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Progress reports how far the worker has got grading a submission: COMPILING,
+// or RUNNING with Completed of Total tests finished. Total is 0 when unknown.
+type Progress struct {
+	Stage     string `json:"stage"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+// WorkerEvent is one line of the worker's streamed response: progress while
+// the submission is graded, then its result or an error
+type WorkerEvent struct {
+	Progress *Progress    `json:"progress,omitempty"`
+	Result   *CodeOutput  `json:"result,omitempty"`
+	Error    *WorkerError `json:"error,omitempty"`
+}
+
+// Codes of the errors a submission can fail to be graded with. The worker
+// reports the first five, the last two are failures to reach it.
+const (
+	ErrorInvalidRequest    = "INVALID_REQUEST" // The worker couldn't read the submission
+	ErrorUnauthorized      = "UNAUTHORIZED"    // The worker doesn't share the server's secret, or took the request for a replay
+	ErrorInvalidProblem    = "INVALID_PROBLEM" // The problem's data can't be graded, e.g. a seed without the named function
+	ErrorShuttingDown      = "SHUTTING_DOWN"   // The worker stopped before the submission finished
+	ErrorInternal          = "INTERNAL"        // The worker itself failed, e.g. to create a workspace
+	ErrorWorkerUnavailable = "WORKER_UNAVAILABLE"
+	ErrorWorkerTimeout     = "WORKER_TIMEOUT"
+)
+
+// WorkerError says why a submission couldn't be graded. A retryable error may
+// not happen again when the submission is sent to a worker again.
+type WorkerError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *WorkerError) Error() string {
+	return fmt.Sprintf("worker service %s: %s", e.Code, e.Message)
+}
+
+// WorkerErrorResponse is the body of a worker's response to a submission it couldn't grade
+type WorkerErrorResponse struct {
+	Error *WorkerError `json:"error"`
+}
+
+// SubmissionEvent tells the streams watching a submission that its status
+// changed or the worker reported progress
+type SubmissionEvent struct {
+	Status   string    `json:"status,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+// QueuedSubmission identifies a submission waiting to be graded
+type QueuedSubmission struct {
+	SubmissionID int64  `json:"submission_id"`
+	Status       string `json:"status"`
+}
+
+// Submission is a stored attempt at a problem, as recorded in user_solutions
+type Submission struct {
+	ID          int64        `json:"id"`
+	ProblemID   string       `json:"problem_id"`
+	UserID      int          `json:"-"`
+	SessionID   string       `json:"-"`
+	Code        string       `json:"code,omitempty"`
+	Status      string       `json:"status"` // QUEUED, RUNNING or DONE
+	Result      string       `json:"result"`
+	Verdict     string       `json:"verdict"`
+	TestPassed  int          `json:"testPassed"`
+	TestCount   int          `json:"testCount"`
+	RuntimeMs   float64      `json:"runtimeMs"`
+	Results     []TestResult `json:"results,omitempty"`
+	SubmittedAt time.Time    `json:"submitted_at"`
+	Feedback
+}
+
+// Feedback is what a graded submission shows the user besides its test results:
+// the first failing case, or the compiler's diagnostics, and anything it printed.
+// A submission that couldn't be graded has the error's code and the HTTP status
+// the server answers it with: 502, 504 or 422.
+type Feedback struct {
+	Output      string       `json:"output,omitempty"`
+	Input       string       `json:"input,omitempty"`
+	Expected    string       `json:"expected,omitempty"`
+	Stdout      string       `json:"stdout,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	ErrorCode   string       `json:"error_code,omitempty"`
+	ErrorStatus int          `json:"error_status,omitempty"`
+}
+
+// SubmissionPage is one page of a submission listing
+type SubmissionPage struct {
+	Submissions []Submission `json:"submissions"`
+	Page        int          `json:"page"`
+	PerPage     int          `json:"per_page"`
+	Total       int          `json:"total"`
 }
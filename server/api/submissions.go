@@ -0,0 +1,447 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Name of the cookie identifying an anonymous user's submissions
+const anonymousCookieName = "leetgo_anon"
+
+// Pagination defaults for submission listings
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Result recorded for a submission the worker failed to execute
+const SubmissionError = "ERROR"
+
+// Statuses of a submission's grading job
+const (
+	SubmissionQueued  = "QUEUED"
+	SubmissionRunning = "RUNNING"
+	SubmissionDone    = "DONE"
+)
+
+// submissionOwner identifies whose submissions a request may see: a logged in
+// user, or an anonymous browser session
+type submissionOwner struct {
+	userID    int
+	sessionID string
+}
+
+// Wrapper function for Store.SaveSubmission
+var SaveSubmissionWrapper = func(store Store, submission Submission) (int64, error) {
+	return store.SaveSubmission(submission)
+}
+
+// Record a submission as made by the request's user or anonymous session
+func recordSubmission(store Store, w http.ResponseWriter, r *http.Request, submission Submission) (int64, error) {
+	owner := requestOwner(w, r)
+	submission.UserID, submission.SessionID = owner.userID, owner.sessionID
+	return SaveSubmissionWrapper(store, submission)
+}
+
+// Build the completed submission record for a graded solution
+func newSubmission(id int64, codeSubmission CodeSubmission, codeOutput CodeOutput) Submission {
+	var runtime float64
+	for _, result := range codeOutput.Results {
+		runtime += result.DurationMs
+	}
+	return Submission{
+		ID:         id,
+		ProblemID:  codeSubmission.ProblemID,
+		Code:       codeSubmission.Code,
+		Status:     SubmissionDone,
+		Result:     codeOutput.Result,
+		Verdict:    codeOutput.Verdict,
+		TestPassed: codeOutput.TestPassed,
+		TestCount:  codeOutput.TestCount,
+		RuntimeMs:  runtime,
+		Results:    codeOutput.Results,
+		Feedback: Feedback{
+			Output:      codeOutput.Output,
+			Input:       codeOutput.Input,
+			Expected:    codeOutput.Expected,
+			Stdout:      codeOutput.Stdout,
+			Diagnostics: codeOutput.Diagnostics,
+		},
+	}
+}
+
+// List the current user's submissions, newest first
+func GetSubmissions(store Store, w http.ResponseWriter, r *http.Request) {
+	listSubmissions(store, w, r, "")
+}
+
+// List the current user's submissions to a problem, newest first
+func GetProblemSubmissions(store Store, w http.ResponseWriter, r *http.Request) {
+	listSubmissions(store, w, r, mux.Vars(r)["id"])
+}
+
+// Fetch a single submission, including its code and results
+func GetSubmission(store Store, w http.ResponseWriter, r *http.Request) {
+	owner, ok := existingOwner(r)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+
+	submission, err := store.GetSubmission(mux.Vars(r)["id"], owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve submission")
+		log.Printf("Database error: %v", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, submission)
+}
+
+// Respond with a page of the current user's submissions, optionally limited to one problem
+func listSubmissions(store Store, w http.ResponseWriter, r *http.Request, problemID string) {
+	page, perPage, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := SubmissionPage{Submissions: []Submission{}, Page: page, PerPage: perPage}
+	owner, ok := existingOwner(r)
+	if !ok {
+		respondWithJSON(w, http.StatusOK, response)
+		return
+	}
+
+	submissions, total, err := store.ListSubmissions(owner, problemID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve submissions")
+		log.Printf("Database error: %v", err)
+		return
+	}
+	response.Submissions = append(response.Submissions, submissions...)
+	response.Total = total
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Parse the page and per_page query parameters
+func parsePagination(r *http.Request) (int, int, error) {
+	page, perPage := 1, defaultPerPage
+	if value := r.URL.Query().Get("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("Invalid page: %s", value)
+		}
+		page = n
+	}
+	if value := r.URL.Query().Get("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPerPage {
+			return 0, 0, fmt.Errorf("Invalid per_page: %s (must be between 1 and %d)", value, maxPerPage)
+		}
+		perPage = n
+	}
+	return page, perPage, nil
+}
+
+// Return the owner of the request's submissions, starting an anonymous session if needed
+func requestOwner(w http.ResponseWriter, r *http.Request) submissionOwner {
+	if owner, ok := existingOwner(r); ok {
+		return owner
+	}
+
+	sessionID := make([]byte, 16)
+	if _, err := rand.Read(sessionID); err != nil {
+		log.Printf("Failed to generate anonymous session: %v", err)
+		return submissionOwner{}
+	}
+	owner := submissionOwner{sessionID: hex.EncodeToString(sessionID)}
+	http.SetCookie(w, &http.Cookie{
+		Name:     anonymousCookieName,
+		Value:    owner.sessionID,
+		Path:     "/",
+		MaxAge:   int(365 * 24 * 60 * 60),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return owner
+}
+
+// Return the owner of the request's submissions, if it has a user or anonymous session
+func existingOwner(r *http.Request) (submissionOwner, bool) {
+	if user, ok := CurrentUser(r); ok {
+		return submissionOwner{userID: user.ID}, true
+	}
+	if cookie, err := r.Cookie(anonymousCookieName); err == nil && cookie.Value != "" {
+		return submissionOwner{sessionID: cookie.Value}, true
+	}
+	return submissionOwner{}, false
+}
+
+// Return the user_solutions column and value selecting the owner's submissions
+func (owner submissionOwner) filter() (string, interface{}) {
+	if owner.userID != 0 {
+		return "user_id", owner.userID
+	}
+	return "session_id", owner.sessionID
+}
+
+// Insert a submission into user_solutions and return its ID. The problem's
+// counters are updated in the same transaction if it has been graded.
+func (s *sqlStore) SaveSubmission(submission Submission) (int64, error) {
+	results, feedback, err := encodeSubmissionDetails(submission)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = withTx(s.db, func(tx *sql.Tx) error {
+		if countsAsAttempt(submission) {
+			if err := s.updateProblemCounters(tx, submission); err != nil {
+				return err
+			}
+		}
+
+		var userID interface{}
+		if submission.UserID != 0 {
+			userID = submission.UserID
+		}
+		id, err = s.insert(tx, `
+			INSERT INTO user_solutions
+				(problem_id, user_id, session_id, solution_code, status, status_changed_at, result, verdict, test_passed, test_count, runtime_ms, results, feedback)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			submission.ProblemID,
+			userID,
+			submission.SessionID,
+			submission.Code,
+			submission.Status,
+			time.Now().Unix(),
+			submission.Result,
+			submission.Verdict,
+			submission.TestPassed,
+			submission.TestCount,
+			submission.RuntimeMs,
+			results,
+			feedback,
+		)
+		return err
+	})
+	return id, err
+}
+
+// Move a submission that isn't done yet to another status
+func (s *sqlStore) SetSubmissionStatus(id int64, status string) error {
+	result, err := s.exec(s.db, `UPDATE user_solutions SET status = ?, status_changed_at = ? WHERE id = ? AND status != ?`,
+		status, time.Now().Unix(), id, SubmissionDone)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// Move an unfinished submission whose status last changed at or before the
+// given time back to QUEUED. Returns errNotFound if it has finished or changed
+// since, for example because another server requeued it first.
+func (s *sqlStore) RequeueSubmission(id int64, changedBefore time.Time) error {
+	result, err := s.exec(s.db, `
+		UPDATE user_solutions SET status = ?, status_changed_at = ?
+		WHERE id = ? AND status != ? AND status_changed_at <= ?`,
+		SubmissionQueued, time.Now().Unix(), id, SubmissionDone, changedBefore.Unix())
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// Record the outcome of grading a submission and mark it done, updating the
+// problem's counters in the same transaction. Returns errNotFound if there is
+// no such submission or it was already done.
+func (s *sqlStore) CompleteSubmission(submission Submission) error {
+	results, feedback, err := encodeSubmissionDetails(submission)
+	if err != nil {
+		return err
+	}
+
+	return withTx(s.db, func(tx *sql.Tx) error {
+		result, err := s.exec(tx, `
+			UPDATE user_solutions SET
+				status = ?, status_changed_at = ?, result = ?, verdict = ?, test_passed = ?, test_count = ?, runtime_ms = ?, results = ?, feedback = ?
+			WHERE id = ? AND status != ?`,
+			SubmissionDone,
+			time.Now().Unix(),
+			submission.Result,
+			submission.Verdict,
+			submission.TestPassed,
+			submission.TestCount,
+			submission.RuntimeMs,
+			results,
+			feedback,
+			submission.ID,
+			SubmissionDone,
+		)
+		if err != nil {
+			return err
+		}
+		if err := requireRow(result); err != nil {
+			return err
+		}
+
+		// The counters go by the stored problem and owner
+		var userID sql.NullInt64
+		err = s.queryRow(tx, `SELECT problem_id, user_id, COALESCE(session_id, '') FROM user_solutions WHERE id = ?`, submission.ID).
+			Scan(&submission.ProblemID, &userID, &submission.SessionID)
+		if err != nil {
+			return err
+		}
+		submission.UserID = int(userID.Int64)
+		submission.Status = SubmissionDone
+		if !countsAsAttempt(submission) {
+			return nil
+		}
+		return s.updateProblemCounters(tx, submission)
+	})
+}
+
+// List the submissions whose grading never finished and whose status last
+// changed at or before the given time, oldest first, with their code
+func (s *sqlStore) ListUnfinishedSubmissions(changedBefore time.Time) ([]Submission, error) {
+	rows, err := s.query(s.db, `
+		SELECT id, problem_id, solution_code, status
+		FROM user_solutions
+		WHERE status != ? AND status_changed_at <= ?
+		ORDER BY id`, SubmissionDone, changedBefore.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submissions []Submission
+	for rows.Next() {
+		var submission Submission
+		if err := rows.Scan(&submission.ID, &submission.ProblemID, &submission.Code, &submission.Status); err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
+
+// Report whether a submission counts towards its problem's attempts: it has
+// been graded, and the worker didn't fail to execute it
+func countsAsAttempt(submission Submission) bool {
+	return submission.Status == SubmissionDone && submission.Result != SubmissionError
+}
+
+// Encode the results and feedback of a submission for storage
+func encodeSubmissionDetails(submission Submission) (string, string, error) {
+	results, err := json.Marshal(submission.Results)
+	if err != nil {
+		return "", "", err
+	}
+	feedback, err := json.Marshal(submission.Feedback)
+	if err != nil {
+		return "", "", err
+	}
+	return string(results), string(feedback), nil
+}
+
+// Fetch one of the owner's submissions, including its code and results.
+// Returns sql.ErrNoRows if the owner has no such submission.
+func (s *sqlStore) GetSubmission(id string, owner submissionOwner) (Submission, error) {
+	ownerColumn, ownerValue := owner.filter()
+	var submission Submission
+	var results, feedback string
+	err := s.queryRow(s.db, `
+		SELECT id, problem_id, solution_code, status, COALESCE(result, ''), COALESCE(verdict, ''), test_passed, test_count, runtime_ms,
+			COALESCE(results, '[]'), COALESCE(feedback, '{}'), date_submitted
+		FROM user_solutions
+		WHERE id = ? AND `+ownerColumn+` = ?`, id, ownerValue).Scan(
+		&submission.ID,
+		&submission.ProblemID,
+		&submission.Code,
+		&submission.Status,
+		&submission.Result,
+		&submission.Verdict,
+		&submission.TestPassed,
+		&submission.TestCount,
+		&submission.RuntimeMs,
+		&results,
+		&feedback,
+		&submission.SubmittedAt,
+	)
+	if err != nil {
+		return submission, err
+	}
+	if err := json.Unmarshal([]byte(results), &submission.Results); err != nil {
+		log.Printf("Failed to decode results of submission %d: %v", submission.ID, err)
+	}
+	if err := json.Unmarshal([]byte(feedback), &submission.Feedback); err != nil {
+		log.Printf("Failed to decode feedback of submission %d: %v", submission.ID, err)
+	}
+	return submission, nil
+}
+
+// List a page of the owner's submissions, newest first and optionally limited
+// to one problem, along with how many there are in total
+func (s *sqlStore) ListSubmissions(owner submissionOwner, problemID string, limit, offset int) ([]Submission, int, error) {
+	ownerColumn, ownerValue := owner.filter()
+	where := ownerColumn + " = ?"
+	args := []interface{}{ownerValue}
+	if problemID != "" {
+		where += " AND problem_id = ?"
+		args = append(args, problemID)
+	}
+
+	var total int
+	if err := s.queryRow(s.db, `SELECT COUNT(*) FROM user_solutions WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.query(s.db, `
+		SELECT id, problem_id, status, COALESCE(result, ''), COALESCE(verdict, ''), test_passed, test_count, runtime_ms, date_submitted
+		FROM user_solutions
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var submissions []Submission
+	for rows.Next() {
+		var submission Submission
+		err := rows.Scan(
+			&submission.ID,
+			&submission.ProblemID,
+			&submission.Status,
+			&submission.Result,
+			&submission.Verdict,
+			&submission.TestPassed,
+			&submission.TestCount,
+			&submission.RuntimeMs,
+			&submission.SubmittedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, total, rows.Err()
+}
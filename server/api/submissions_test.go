@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestSaveSubmission(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE problems SET attempts = attempts \\+ 1, solves = solves \\+ \\? WHERE id = \\?$").WithArgs(0, "3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO user_solutions (.+) VALUES").
+		WithArgs("3", nil, "abc123", "code", SubmissionDone, sqlmock.AnyArg(), "FAILED", VerdictWrongAnswer, 1, 2, 1.5, `[{"id":7,"status":"FAILED","input":"","expected":"","actual":"-1","durationMs":1.5}]`, `{"output":"-1"}`).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	id, err := NewSQLiteStore(db).SaveSubmission(Submission{
+		ProblemID:  "3",
+		SessionID:  "abc123",
+		Code:       "code",
+		Status:     SubmissionDone,
+		Result:     "FAILED",
+		Verdict:    VerdictWrongAnswer,
+		TestPassed: 1,
+		TestCount:  2,
+		RuntimeMs:  1.5,
+		Results:    []TestResult{{ID: 7, Status: TestFailed, Actual: "-1", DurationMs: 1.5}},
+		Feedback:   Feedback{Output: "-1"},
+	})
+	ok(t, err)
+	equals(t, int64(9), id)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetSubmissions(t *testing.T) {
+	submitted := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		url          string
+		problemID    string
+		user         *User
+		cookie       string
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "AnonymousSession",
+			url:    "/submissions?page=2&per_page=1",
+			cookie: "abc123",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_solutions WHERE session_id = \\?$").WithArgs("abc123").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery("^SELECT (.+) FROM user_solutions WHERE session_id = \\? ORDER BY id DESC LIMIT \\? OFFSET \\?$").
+					WithArgs("abc123", 1, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "problem_id", "status", "result", "verdict", "test_passed", "test_count", "runtime_ms", "date_submitted"}).
+						AddRow(1, "3", SubmissionDone, "PASSED", VerdictAccepted, 3, 3, 0.25, submitted))
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"submissions":[{"id":1,"problem_id":"3","status":"DONE","result":"PASSED","verdict":"ACCEPTED","testPassed":3,"testCount":3,"runtimeMs":0.25,"submitted_at":"2024-05-01T12:00:00Z"}],"page":2,"per_page":1,"total":2}`,
+		},
+		{
+			name:      "LoggedInUserForProblem",
+			url:       "/problems/3/submissions",
+			problemID: "3",
+			user:      &User{ID: 2, Username: "alice"},
+			cookie:    "abc123",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_solutions WHERE user_id = \\? AND problem_id = \\?$").WithArgs(2, "3").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectQuery("^SELECT (.+) FROM user_solutions WHERE user_id = \\? AND problem_id = \\?").WithArgs(2, "3", defaultPerPage, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "problem_id", "status", "result", "verdict", "test_passed", "test_count", "runtime_ms", "date_submitted"}))
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"submissions":[],"page":1,"per_page":20,"total":0}`,
+		},
+		{
+			name:         "NoSession",
+			url:          "/submissions",
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"submissions":[],"page":1,"per_page":20,"total":0}`,
+		},
+		{
+			name:         "InvalidPage",
+			url:          "/submissions?page=0",
+			cookie:       "abc123",
+			mockSetup:    func(mock sqlmock.Sqlmock) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid page: 0"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: anonymousCookieName, Value: tt.cookie})
+			}
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey, *tt.user))
+			}
+
+			if tt.problemID != "" {
+				req = mux.SetURLVars(req, map[string]string{"id": tt.problemID})
+				GetProblemSubmissions(NewSQLiteStore(db), rec, req)
+			} else {
+				GetSubmissions(NewSQLiteStore(db), rec, req)
+			}
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetSubmission(t *testing.T) {
+	submitted := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "problem_id", "solution_code", "status", "result", "verdict", "test_passed", "test_count", "runtime_ms", "results", "feedback", "date_submitted"}
+
+	tests := []struct {
+		name         string
+		cookie       string
+		rows         *sqlmock.Rows
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "OwnSubmission",
+			cookie: "abc123",
+			rows: sqlmock.NewRows(columns).AddRow(5, "2", "func Sum(x, y int) int { return x + y }", SubmissionDone, "PASSED", VerdictAccepted, 1, 1, 0.5,
+				`[{"id":4,"status":"PASSED","input":"","expected":"","actual":"3","durationMs":0.5}]`, `{"stdout":"debug"}`, submitted),
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":5,"problem_id":"2","code":"func Sum(x, y int) int { return x + y }","status":"DONE","result":"PASSED","verdict":"ACCEPTED","testPassed":1,"testCount":1,"runtimeMs":0.5,"results":[{"id":4,"status":"PASSED","input":"","expected":"","actual":"3","durationMs":0.5}],"submitted_at":"2024-05-01T12:00:00Z","stdout":"debug"}`,
+		},
+		{
+			name:         "SomeoneElsesSubmission",
+			cookie:       "abc123",
+			rows:         sqlmock.NewRows(columns),
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Submission not found"}`,
+		},
+		{
+			name:         "NoSession",
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Submission not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			if tt.rows != nil {
+				mock.ExpectQuery("^SELECT (.+) FROM user_solutions WHERE id = \\? AND session_id = \\?$").WithArgs("5", tt.cookie).WillReturnRows(tt.rows)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/submissions/5", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: anonymousCookieName, Value: tt.cookie})
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+			GetSubmission(NewSQLiteStore(db), rec, req)
+
+			equals(t, tt.expectedCode, rec.Code)
+			equals(t, tt.expectedBody, strings.TrimSpace(rec.Body.String()))
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestOwner(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/execute", nil)
+
+	owner := requestOwner(rec, req)
+	assert(t, len(owner.sessionID) == 32, "expected a new anonymous session, got %+v", owner)
+
+	cookies := rec.Result().Cookies()
+	assert(t, len(cookies) == 1 && cookies[0].Name == anonymousCookieName && cookies[0].Value == owner.sessionID,
+		"expected the anonymous session cookie to be set, got %+v", cookies)
+
+	req = req.WithContext(context.WithValue(req.Context(), userContextKey, User{ID: 2}))
+	equals(t, submissionOwner{userID: 2}, requestOwner(httptest.NewRecorder(), req))
+}
@@ -1,52 +1,46 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"os"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
-// Retrieve worker service URL:PORT from env variables
-func GetWorkerURL() string {
-	workerHost := os.Getenv("WORKER_HOST")
-	if workerHost == "" {
-		workerHost = "http://localhost"
-	}
-
-	workerPort := os.Getenv("WORKER_PORT")
-	if workerPort == "" {
-		workerPort = "8081"
-	}
-
-	workerPath := os.Getenv("WORKER_PATH")
-	if workerPath == "" {
-		workerPath = "/process-code"
-	}
-
-	return fmt.Sprintf("%s:%s%s", workerHost, workerPort, workerPath)
-}
+// Verdicts reported by the worker service
+const (
+	VerdictAccepted     = "ACCEPTED"
+	VerdictWrongAnswer  = "WRONG_ANSWER"
+	VerdictCompileError = "COMPILE_ERROR"
+	VerdictRuntimeError = "RUNTIME_ERROR"
+	VerdictTimeout      = "TIMEOUT"
+	VerdictMemoryLimit  = "MEMORY_LIMIT_EXCEEDED"
+	VerdictOutputLimit  = "OUTPUT_LIMIT_EXCEEDED"
+)
 
 // Return input, expectedOutput, and actualOutput from CodeOutput
 func BuildResponse(codeOutput *CodeOutput, examples []ProblemExample) (input, expectedOutput, actualOutput string) {
 	if codeOutput.Result == "FAILED" {
-		lines := strings.Split(codeOutput.Output, "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "./temp_code.go") {
-				actualOutput = getFailureError(line)
+		if codeOutput.Verdict == VerdictCompileError || codeOutput.Verdict == VerdictRuntimeError {
+			if len(codeOutput.Diagnostics) > 0 {
+				actualOutput = codeOutput.Diagnostics[0].Message
+			}
+			return input, expectedOutput, actualOutput
+		}
+
+		for _, result := range codeOutput.Results {
+			if result.Status != TestPassed && result.Hidden {
+				// Hidden cases only reveal that they failed
+				actualOutput = result.Actual
 				break
 			}
-			if strings.Contains(line, "FAILED") {
-				re := regexp.MustCompile(`\d+`)
-				id := re.FindString(line)
-				testID, err := strconv.Atoi(id)
-				if err != nil {
-					fmt.Println("Error converting number: ", err)
-					return
+			if result.Status != TestPassed {
+				actualOutput = result.Actual
+				input, expectedOutput = getInputAndExpectedOutputByID(examples, result.ID)
+				if input == "" {
+					// Results of go test problems name the test instead of an example
+					input, expectedOutput = result.Input, result.Expected
 				}
-				actualOutput = getOutputValue(line)
-				input, expectedOutput = getInputAndExpectedOutputByID(examples, testID)
 				break
 			}
 		}
@@ -64,27 +58,75 @@ func getInputAndExpectedOutputByID(examples []ProblemExample, id int) (string, s
 	return "", ""
 }
 
-// Return value provided after Output
-func getOutputValue(line string) string {
-	prefix := "Output: "
-	startIndex := strings.Index(line, prefix)
-	if startIndex == -1 {
-		return ""
+// Strip the input, expected and actual values of hidden test cases from the
+// results, leaving only whether they passed
+func redactHiddenResults(results []TestResult, examples []ProblemExample) []TestResult {
+	hidden := make(map[int]int)
+	for _, example := range examples {
+		if example.IsHidden {
+			hidden[example.ID] = len(hidden) + 1
+		}
 	}
 
-	output := line[startIndex+len(prefix):]
+	if len(hidden) == 0 {
+		return results
+	}
 
-	return strings.TrimSpace(output)
+	redacted := make([]TestResult, len(results))
+	for i, result := range results {
+		redacted[i] = result
+		number, ok := hidden[result.ID]
+		if !ok {
+			continue
+		}
+		redacted[i] = TestResult{ID: result.ID, Status: result.Status, DurationMs: result.DurationMs, Hidden: true}
+		if result.Status != TestPassed {
+			redacted[i].Actual = fmt.Sprintf("hidden test %d failed", number)
+		}
+	}
+	return redacted
 }
 
-// Return error after code file & line numbers
-func getFailureError(line string) string {
-	re := regexp.MustCompile(`.*?:\d+:\d+: (.+)`)
-	match := re.FindStringSubmatch(line)
-	if len(match) > 1 {
-		errorDetail := match[1]
-		return errorDetail
+// Format a test case for display, e.g. input "nums = [2,7], target = 9" and output "[0,1]".
+// Design problems show their operations and arguments on separate lines.
+func formatExampleDisplay(example ProblemExample) (ExampleDisplay, error) {
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(example.Input), &args); err != nil {
+		return ExampleDisplay{}, fmt.Errorf("failed to parse example input: %w", err)
+	}
+	var inputOrder []string
+	if err := json.Unmarshal([]byte(example.InputOrder), &inputOrder); err != nil {
+		return ExampleDisplay{}, fmt.Errorf("failed to parse example input order: %w", err)
+	}
+
+	var input string
+	if len(inputOrder) == 0 {
+		input = compactJSON(args["operations"]) + "\n" + compactJSON(args["arguments"])
 	} else {
-		return ""
+		params := make([]string, len(inputOrder))
+		for i, key := range inputOrder {
+			params[i] = key + " = " + compactJSON(args[key])
+		}
+		input = strings.Join(params, ", ")
+	}
+
+	var expected map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(example.ExpectedOutput), &expected); err != nil {
+		return ExampleDisplay{}, fmt.Errorf("failed to parse example expected output: %w", err)
+	}
+	var output string
+	for _, value := range expected {
+		output = compactJSON(value)
+	}
+
+	return ExampleDisplay{Input: input, Output: output, Explanation: example.Explanation}, nil
+}
+
+// Remove insignificant whitespace from a JSON value
+func compactJSON(value json.RawMessage) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, value); err != nil {
+		return string(value)
 	}
+	return compacted.String()
 }
@@ -2,7 +2,6 @@ package api
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
@@ -40,48 +39,14 @@ func failTest(tb testing.TB, msg string, v ...interface{}) {
 
 // Tests
 
-func TestGetWorkerURL(t *testing.T) {
-	// Save original environment variables and restore them at the end
-	originalWorkerHost := os.Getenv("WORKER_HOST")
-	originalWorkerPort := os.Getenv("WORKER_PORT")
-	originalWorkerPath := os.Getenv("WORKER_PATH")
-	defer func() {
-		os.Setenv("WORKER_HOST", originalWorkerHost)
-		os.Setenv("WORKER_PORT", originalWorkerPort)
-		os.Setenv("WORKER_PATH", originalWorkerPath)
-	}()
-
-	tests := []struct {
-		name           string
-		envVars        map[string]string
-		expectedResult string
-	}{
-		{"NoEnvVars", map[string]string{}, "http://localhost:8081/process-code"},
-		{"OnlyWorkerHostSet", map[string]string{"WORKER_HOST": "http://example.com"}, "http://example.com:8081/process-code"},
-		{"OnlyWorkerPortSet", map[string]string{"WORKER_PORT": "9090"}, "http://localhost:9090/process-code"},
-		{"OnlyWorkerPathSet", map[string]string{"WORKER_PATH": "/custom-path"}, "http://localhost:8081/custom-path"},
-		{"AllEnvVarsSet", map[string]string{"WORKER_HOST": "http://example.com", "WORKER_PORT": "9090", "WORKER_PATH": "/custom-path"}, "http://example.com:9090/custom-path"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for key, value := range tt.envVars {
-				os.Setenv(key, value)
-			}
-
-			equals(t, tt.expectedResult, GetWorkerURL())
-
-			for key := range tt.envVars {
-				os.Unsetenv(key)
-			}
-		})
-	}
-}
-
 func TestBuildResponse(t *testing.T) {
 	codeOutput := &CodeOutput{
 		Result: "FAILED",
-		Output: "Test 1: FAILED, Output: -1",
+		Output: "Test 2: FAILED, Output: 100",
+		Results: []TestResult{
+			{ID: 1, Status: TestFailed, Actual: "-1"},
+			{ID: 2, Status: TestPassed, Actual: "4"},
+		},
 	}
 	examples := []ProblemExample{
 		{ID: 1, Input: "1", ExpectedOutput: "2"},
@@ -110,6 +75,49 @@ func TestBuildResponse(t *testing.T) {
 	}
 }
 
+func TestBuildResponseGoTest(t *testing.T) {
+	codeOutput := &CodeOutput{
+		Result: "FAILED",
+		Results: []TestResult{
+			{ID: 1, Status: TestPassed, Input: "TestSum/positive"},
+			{ID: 2, Status: TestFailed, Input: "TestSum/negative", Actual: "Sum(-1, -2) = 3, want -3"},
+		},
+	}
+
+	input, expected, actual := BuildResponse(codeOutput, nil)
+	equals(t, "TestSum/negative", input)
+	equals(t, "", expected)
+	equals(t, "Sum(-1, -2) = 3, want -3", actual)
+}
+
+func TestBuildResponseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name              string
+		verdict           string
+		diagnostics       []Diagnostic
+		expectedActualOut string
+	}{
+		{"CompileError", VerdictCompileError, []Diagnostic{{Line: 2, Column: 9, Message: "undefined: z"}}, "undefined: z"},
+		{"RuntimeError", VerdictRuntimeError, []Diagnostic{{Line: 3, Message: "panic: boom"}}, "panic: boom"},
+		{"NoDiagnostics", VerdictCompileError, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codeOutput := &CodeOutput{
+				Result:      "FAILED",
+				Verdict:     tt.verdict,
+				Diagnostics: tt.diagnostics,
+				Output:      "Test 1: FAILED, Output: -1",
+			}
+			input, expected, actual := BuildResponse(codeOutput, []ProblemExample{{ID: 1, Input: "1", ExpectedOutput: "2"}})
+			equals(t, "", input)
+			equals(t, "", expected)
+			equals(t, tt.expectedActualOut, actual)
+		})
+	}
+}
+
 func TestGetInputAndExpectedOutputByID(t *testing.T) {
 	examples := []ProblemExample{
 		{ID: 1, Input: "1", ExpectedOutput: "2"},
@@ -135,36 +143,40 @@ func TestGetInputAndExpectedOutputByID(t *testing.T) {
 	}
 }
 
-func TestGetOutputValue(t *testing.T) {
-	tests := []struct {
-		name           string
-		line           string
-		expectedOutput string
-	}{
-		{"OutputExists", "Test 4: FAILED, Output: -1", "-1"},
-		{"OutputDoesNotExist", "Test 4: FAILED, Output: ", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			equals(t, tt.expectedOutput, getOutputValue(tt.line))
-		})
-	}
-}
-
-func TestGetFailureError(t *testing.T) {
+func TestFormatExampleDisplay(t *testing.T) {
 	tests := []struct {
-		name          string
-		line          string
-		expectedError string
+		name     string
+		example  ProblemExample
+		expected ExampleDisplay
+		wantErr  bool
 	}{
-		{"ErrorExists", "./temp_code.go:4:5: invalid operation: mismatched types int and string", "invalid operation: mismatched types int and string"},
-		{"ErrorDoesNotExist", "Test 4: FAILED, Output: -1", ""},
+		{
+			name:     "Function arguments in input order",
+			example:  ProblemExample{Input: `{"target": 9, "nums": [2, 7, 11, 15]}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": [0, 1]}`, Explanation: "nums[0] + nums[1] == 9"},
+			expected: ExampleDisplay{Input: "nums = [2,7,11,15], target = 9", Output: "[0,1]", Explanation: "nums[0] + nums[1] == 9"},
+		},
+		{
+			name:     "String argument",
+			example:  ProblemExample{Input: `{"s": "radar"}`, InputOrder: `["s"]`, ExpectedOutput: `{"result": true}`},
+			expected: ExampleDisplay{Input: `s = "radar"`, Output: "true"},
+		},
+		{
+			name:     "Design operations",
+			example:  ProblemExample{Input: `{"operations": ["MinStack", "push"], "arguments": [[], [1]]}`, InputOrder: `[]`, ExpectedOutput: `{"expected": [null, null]}`},
+			expected: ExampleDisplay{Input: "[\"MinStack\",\"push\"]\n[[],[1]]", Output: "[null,null]"},
+		},
+		{
+			name:    "Invalid input",
+			example: ProblemExample{Input: `{"s": `, InputOrder: `["s"]`, ExpectedOutput: `{"result": true}`},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			equals(t, tt.expectedError, getFailureError(tt.line))
+			display, err := formatExampleDisplay(tt.example)
+			equals(t, tt.wantErr, err != nil)
+			equals(t, tt.expected, display)
 		})
 	}
 }
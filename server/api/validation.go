@@ -0,0 +1,509 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// Problem types the worker can grade
+const (
+	ProblemTypeFunction = "function"
+	ProblemTypeDesign   = "design"
+	ProblemTypeGoTest   = "gotest"
+)
+
+// Compare modes the worker supports
+const (
+	CompareExact   = "exact"
+	CompareChecker = "checker"
+)
+
+var compareModes = map[string]bool{CompareExact: true, "unordered": true, "set": true, "float": true, CompareChecker: true}
+
+var difficulties = map[string]bool{"easy": true, "medium": true, "hard": true}
+
+// Definitions of the linked structures the worker supplies to seeds that use
+// them without declaring them
+var linkedStructures = map[string]string{
+	"ListNode": "type ListNode struct {\n\tVal  int\n\tNext *ListNode\n}\n",
+	"TreeNode": "type TreeNode struct {\n\tVal   int\n\tLeft  *TreeNode\n\tRight *TreeNode\n}\n",
+}
+
+// problemSignature holds the type-checked declarations of a problem seed that
+// its test cases are validated against
+type problemSignature struct {
+	problemType string
+	function    *types.Signature            // Function problems
+	constructor *types.Signature            // Design problems
+	methods     map[string]*types.Signature // Design methods keyed by lower-cased name
+}
+
+// Validate a problem definition, type-checking its seed, test file and checker,
+// and return the signature its test cases must match. Defaults are filled in.
+func validateProblem(def *ProblemDefinition) (*problemSignature, error) {
+	def.Name = strings.TrimSpace(def.Name)
+	def.Difficulty = strings.ToLower(strings.TrimSpace(def.Difficulty))
+	if def.ProblemType == "" {
+		def.ProblemType = ProblemTypeFunction
+	}
+	if def.CompareMode == "" {
+		def.CompareMode = CompareExact
+	}
+
+	switch {
+	case !token.IsIdentifier(def.Name):
+		return nil, fmt.Errorf("name must be the Go identifier of the problem's function, got %q", def.Name)
+	case !difficulties[def.Difficulty]:
+		return nil, fmt.Errorf("difficulty must be easy, medium or hard, got %q", def.Difficulty)
+	case !compareModes[def.CompareMode]:
+		return nil, fmt.Errorf("unsupported compare mode: %s", def.CompareMode)
+	case def.Tolerance < 0:
+		return nil, fmt.Errorf("compare_tolerance must not be negative")
+	case def.CompareMode == CompareChecker && strings.TrimSpace(def.Checker) == "":
+		return nil, fmt.Errorf("compare mode %q requires checker_code", CompareChecker)
+	case strings.TrimSpace(def.ProblemSeed) == "":
+		return nil, fmt.Errorf("problem_seed is required")
+	}
+
+	extra := make(map[string]string)
+	if def.CompareMode == CompareChecker {
+		extra["checker.go"] = "package main\n\n" + def.Checker
+	}
+
+	pkgName := "main"
+	switch def.ProblemType {
+	case ProblemTypeFunction, ProblemTypeDesign:
+	case ProblemTypeGoTest:
+		if strings.TrimSpace(def.TestFile) == "" {
+			return nil, fmt.Errorf("go test problems require a test_file")
+		}
+		pkgName = "solution"
+		extra["solution_test.go"] = def.TestFile
+	default:
+		return nil, fmt.Errorf("unsupported problem type: %s", def.ProblemType)
+	}
+
+	pkg, err := typeCheckSeed(pkgName, def.ProblemSeed, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &problemSignature{problemType: def.ProblemType}
+	switch def.ProblemType {
+	case ProblemTypeFunction:
+		fn, ok := pkg.Scope().Lookup(def.Name).(*types.Func)
+		if !ok {
+			return nil, fmt.Errorf("function %s not found in problem seed", def.Name)
+		}
+		sig.function = fn.Type().(*types.Signature)
+		if sig.function.Results().Len() != 1 {
+			return nil, fmt.Errorf("function %s must return a single value", def.Name)
+		}
+	case ProblemTypeDesign:
+		fn, ok := pkg.Scope().Lookup("Constructor").(*types.Func)
+		if !ok {
+			return nil, fmt.Errorf("function Constructor not found in problem seed")
+		}
+		sig.constructor = fn.Type().(*types.Signature)
+		if sig.constructor.Results().Len() != 1 {
+			return nil, fmt.Errorf("Constructor must return the design type")
+		}
+		designType := sig.constructor.Results().At(0).Type()
+		if pointer, ok := designType.(*types.Pointer); ok {
+			designType = pointer.Elem()
+		}
+		named, ok := designType.(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("Constructor must return the design type")
+		}
+		sig.methods = make(map[string]*types.Signature)
+		methods := types.NewMethodSet(types.NewPointer(named))
+		for i := 0; i < methods.Len(); i++ {
+			method := methods.At(i).Obj()
+			sig.methods[strings.ToLower(method.Name())] = method.Type().(*types.Signature)
+		}
+	case ProblemTypeGoTest:
+		if len(def.Tests) > 0 {
+			return nil, fmt.Errorf("go test problems are graded by their test_file and take no test cases")
+		}
+	}
+	return sig, nil
+}
+
+// Type-check the seed as package pkgName, then the extra files against it. Seed
+// function bodies are stubs, so only its declarations need to compile.
+func typeCheckSeed(pkgName, seed string, extra map[string]string) (*types.Package, error) {
+	fset := token.NewFileSet()
+	// The line directive keeps error positions relative to the seed itself
+	seedFile, err := parser.ParseFile(fset, "seed.go", "package "+pkgName+"\n/*line seed.go:1:1*/"+seed, 0)
+	if err != nil {
+		return nil, fmt.Errorf("problem seed: %w", err)
+	}
+	files := []*ast.File{seedFile}
+
+	var structures string
+	for name, definition := range linkedStructures {
+		if strings.Contains(seed, name) && !declaresType(seedFile, name) {
+			structures += definition
+		}
+	}
+	if structures != "" {
+		file, err := parser.ParseFile(fset, "structures.go", "package "+pkgName+"\n"+structures, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	config := types.Config{Importer: importer.Default(), IgnoreFuncBodies: true}
+	pkg, err := config.Check(pkgName, fset, files, nil)
+	if err != nil {
+		return nil, fmt.Errorf("problem seed: %w", err)
+	}
+
+	for name, source := range extra {
+		file, err := parser.ParseFile(fset, name, source, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if file.Name.Name != pkgName {
+			return nil, fmt.Errorf("%s: must be in package %s", name, pkgName)
+		}
+
+		// Errors in the seed's stub bodies are expected, so only this file's count
+		var fileErr error
+		config := types.Config{Importer: importer.Default(), Error: func(err error) {
+			var typeErr types.Error
+			if fileErr == nil && errors.As(err, &typeErr) && typeErr.Fset.Position(typeErr.Pos).Filename == name {
+				fileErr = err
+			}
+		}}
+		config.Check(pkgName, fset, append(files[:len(files):len(files)], file), nil)
+		if fileErr != nil {
+			return nil, fileErr
+		}
+	}
+	return pkg, nil
+}
+
+// Report whether the file declares a type with the given name
+func declaresType(file *ast.File, name string) bool {
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range genDecl.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Validate a test case's input, input_order and expected_output against the problem signature
+func validateTestCase(sig *problemSignature, test ProblemExample) error {
+	switch sig.problemType {
+	case ProblemTypeFunction:
+		return validateFunctionTest(sig.function, test)
+	case ProblemTypeDesign:
+		return validateDesignTest(sig, test)
+	}
+	return fmt.Errorf("go test problems are graded by their test_file and take no test cases")
+}
+
+// Validate a function test case: input holds an argument per parameter, named
+// in call order by input_order, and expected_output holds a single value
+func validateFunctionTest(fn *types.Signature, test ProblemExample) error {
+	var inputOrder []string
+	if err := json.Unmarshal([]byte(test.InputOrder), &inputOrder); err != nil {
+		return fmt.Errorf("input_order must be a JSON array of parameter names: %w", err)
+	}
+	var input map[string]interface{}
+	if err := decodeJSON(test.Input, &input); err != nil {
+		return fmt.Errorf("input must be a JSON object of arguments: %w", err)
+	}
+	if len(inputOrder) != fn.Params().Len() {
+		return fmt.Errorf("expected %d arguments in input_order, got %d", fn.Params().Len(), len(inputOrder))
+	}
+
+	for i, key := range inputOrder {
+		param := fn.Params().At(i)
+		if name := param.Name(); name != "" && name != "_" && name != key {
+			return fmt.Errorf("input_order[%d] is %q but parameter %d is %s", i, key, i+1, name)
+		}
+		value, ok := input[key]
+		if !ok {
+			return fmt.Errorf("missing input: %s", key)
+		}
+		if err := checkValue(value, param.Type()); err != nil {
+			return fmt.Errorf("input %s: %w", key, err)
+		}
+	}
+	if len(input) != len(inputOrder) {
+		return fmt.Errorf("input has %d values but input_order names %d", len(input), len(inputOrder))
+	}
+
+	var expected map[string]interface{}
+	if err := decodeJSON(test.ExpectedOutput, &expected); err != nil {
+		return fmt.Errorf("expected_output must be a JSON object: %w", err)
+	}
+	if len(expected) != 1 {
+		return fmt.Errorf("expected_output must hold exactly one value, got %d", len(expected))
+	}
+	for _, value := range expected {
+		if err := checkValue(value, fn.Results().At(0).Type()); err != nil {
+			return fmt.Errorf("expected_output: %w", err)
+		}
+	}
+	return nil
+}
+
+// Validate a design test case: input lists the operations and their arguments,
+// starting with the Constructor, and expected_output the value each returns
+func validateDesignTest(sig *problemSignature, test ProblemExample) error {
+	var inputOrder []string
+	if err := json.Unmarshal([]byte(test.InputOrder), &inputOrder); err != nil || len(inputOrder) != 0 {
+		return fmt.Errorf("input_order of design problems must be []")
+	}
+	var input struct {
+		Operations []string        `json:"operations"`
+		Arguments  [][]interface{} `json:"arguments"`
+	}
+	if err := decodeJSON(test.Input, &input); err != nil {
+		return fmt.Errorf("input must be a JSON object of operations and arguments: %w", err)
+	}
+	var expected struct {
+		Expected []interface{} `json:"expected"`
+	}
+	if err := decodeJSON(test.ExpectedOutput, &expected); err != nil {
+		return fmt.Errorf("expected_output must be a JSON object with an expected array: %w", err)
+	}
+
+	if len(input.Operations) == 0 {
+		return fmt.Errorf("input has no operations")
+	}
+	if len(input.Arguments) != len(input.Operations) || len(expected.Expected) != len(input.Operations) {
+		return fmt.Errorf("input has %d operations, %d argument lists and %d expected values",
+			len(input.Operations), len(input.Arguments), len(expected.Expected))
+	}
+
+	if err := checkArgs(input.Arguments[0], sig.constructor); err != nil {
+		return fmt.Errorf("operation 1 (%s): %w", input.Operations[0], err)
+	}
+	for i := 1; i < len(input.Operations); i++ {
+		operation := input.Operations[i]
+		method, ok := sig.methods[strings.ToLower(operation)]
+		if !ok {
+			return fmt.Errorf("operation %d: no method matches %q", i+1, operation)
+		}
+		if err := checkArgs(input.Arguments[i], method); err != nil {
+			return fmt.Errorf("operation %d (%s): %w", i+1, operation, err)
+		}
+		switch {
+		case method.Results().Len() == 0 && expected.Expected[i] != nil:
+			return fmt.Errorf("operation %d (%s): method returns nothing but expects %v", i+1, operation, expected.Expected[i])
+		case method.Results().Len() == 1:
+			if err := checkValue(expected.Expected[i], method.Results().At(0).Type()); err != nil {
+				return fmt.Errorf("operation %d (%s) expected value: %w", i+1, operation, err)
+			}
+		case method.Results().Len() > 1:
+			return fmt.Errorf("operation %d (%s): method must return a single value", i+1, operation)
+		}
+	}
+	return nil
+}
+
+// Check positional JSON arguments against a signature's parameters
+func checkArgs(args []interface{}, sig *types.Signature) error {
+	if len(args) != sig.Params().Len() {
+		return fmt.Errorf("expected %d arguments, got %d", sig.Params().Len(), len(args))
+	}
+	for i, arg := range args {
+		if err := checkValue(arg, sig.Params().At(i).Type()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check that a JSON value can be converted to the given Go type the way the
+// worker converts arguments and expected values
+func checkValue(value interface{}, typ types.Type) error {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return checkBasicValue(value, t)
+	case *types.Slice:
+		if value == nil {
+			return nil
+		}
+		if _, ok := value.(string); ok && isByte(t.Elem()) {
+			return nil
+		}
+		return checkElements(value, t.Elem(), typ)
+	case *types.Array:
+		array, ok := value.([]interface{})
+		if ok && int64(len(array)) > t.Len() {
+			return fmt.Errorf("expected at most %d elements for %s, got %d", t.Len(), typ, len(array))
+		}
+		return checkElements(value, t.Elem(), typ)
+	case *types.Map:
+		if value == nil {
+			return nil
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object for %s, got %s", typ, jsonKind(value))
+		}
+		for key, elem := range object {
+			if basic, ok := t.Key().Underlying().(*types.Basic); ok && basic.Info()&types.IsString == 0 {
+				if err := checkBasicValue(json.Number(key), basic); err != nil {
+					return fmt.Errorf("key %q: %w", key, err)
+				}
+			}
+			if err := checkValue(elem, t.Elem()); err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+		}
+		return nil
+	case *types.Pointer:
+		if named, ok := t.Elem().(*types.Named); ok {
+			if _, linked := linkedStructures[named.Obj().Name()]; linked {
+				return checkStructureValue(value, named.Obj().Name())
+			}
+		}
+		if value == nil {
+			return nil
+		}
+		return checkValue(value, t.Elem())
+	case *types.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object for %s, got %s", typ, jsonKind(value))
+		}
+		for i := 0; i < t.NumFields(); i++ {
+			field := t.Field(i)
+			for key, fieldValue := range object {
+				if strings.EqualFold(key, field.Name()) {
+					if err := checkValue(fieldValue, field.Type()); err != nil {
+						return fmt.Errorf("field %s: %w", field.Name(), err)
+					}
+				}
+			}
+		}
+		return nil
+	case *types.Interface:
+		return nil
+	}
+	return fmt.Errorf("unsupported type: %s", typ)
+}
+
+// Check a JSON value against a boolean, numeric or string type
+func checkBasicValue(value interface{}, t *types.Basic) error {
+	info := t.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected bool, got %s", jsonKind(value))
+		}
+	case info&types.IsString != 0:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("expected string, got %s", jsonKind(value))
+		}
+	case info&types.IsInteger != 0:
+		// Characters may be written as single-character strings
+		if s, ok := value.(string); ok && (t.Kind() == types.Int32 || t.Kind() == types.Uint8) {
+			if len([]rune(s)) != 1 {
+				return fmt.Errorf("expected a single character for %s, got %q", t.Name(), s)
+			}
+			return nil
+		}
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("expected number for %s, got %s", t.Name(), jsonKind(value))
+		}
+		if _, err := strconv.ParseInt(number.String(), 10, 64); err != nil {
+			if _, err := strconv.ParseUint(number.String(), 10, 64); err != nil {
+				return fmt.Errorf("expected integer for %s, got %s", t.Name(), number)
+			}
+		}
+	case info&types.IsFloat != 0:
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("expected number for %s, got %s", t.Name(), jsonKind(value))
+		}
+	default:
+		return fmt.Errorf("unsupported type: %s", t.Name())
+	}
+	return nil
+}
+
+// Check each element of a JSON array against the element type
+func checkElements(value interface{}, elem, typ types.Type) error {
+	array, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected array for %s, got %s", typ, jsonKind(value))
+	}
+	for i, element := range array {
+		if err := checkValue(element, elem); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Check a level-order JSON array of a linked structure. Only trees may contain nulls.
+func checkStructureValue(value interface{}, name string) error {
+	if value == nil {
+		return nil
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected array for *%s, got %s", name, jsonKind(value))
+	}
+	for i, element := range array {
+		if element == nil && name == "TreeNode" {
+			continue
+		}
+		if err := checkBasicValue(element, types.Typ[types.Int]); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Report whether the type is byte
+func isByte(typ types.Type) bool {
+	basic, ok := typ.(*types.Basic)
+	return ok && basic.Kind() == types.Uint8
+}
+
+// Describe the kind of a decoded JSON value for error messages
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// Decode JSON keeping numbers as json.Number, so integers can be told apart from floats
+func decodeJSON(data string, v interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
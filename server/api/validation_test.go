@@ -0,0 +1,202 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateProblem(t *testing.T) {
+	tests := []struct {
+		name        string
+		def         ProblemDefinition
+		expectedErr string
+	}{
+		{
+			name: "Function",
+			def:  ProblemDefinition{Name: "TwoSum", Difficulty: "Easy", ProblemSeed: "func TwoSum(nums []int, target int) []int {\n}"},
+		},
+		{
+			name: "FunctionUsingListNode",
+			def:  ProblemDefinition{Name: "ReverseList", Difficulty: "easy", ProblemSeed: "func ReverseList(head *ListNode) *ListNode {\n}"},
+		},
+		{
+			name: "Design",
+			def: ProblemDefinition{Name: "MinStack", Difficulty: "medium", ProblemType: ProblemTypeDesign, ProblemSeed: `type MinStack struct{}
+
+func Constructor() MinStack {
+}
+
+func (this *MinStack) Push(val int) {
+}`},
+		},
+		{
+			name: "GoTest",
+			def: ProblemDefinition{Name: "IsPalindrome", Difficulty: "easy", ProblemType: ProblemTypeGoTest,
+				ProblemSeed: "func IsPalindrome(s string) bool {\n}",
+				TestFile:    "package solution\n\nimport \"testing\"\n\nfunc TestIsPalindrome(t *testing.T) {\n\tif !IsPalindrome(\"aba\") {\n\t\tt.Error(\"aba\")\n\t}\n}\n"},
+		},
+		{
+			name: "Checker",
+			def: ProblemDefinition{Name: "Sum", Difficulty: "easy", ProblemSeed: "func Sum(x, y int) int {\n}", CompareMode: CompareChecker,
+				Checker: "func Check(expected, actual int) bool {\n\treturn expected == actual\n}"},
+		},
+		{
+			name:        "SeedDoesNotCompile",
+			def:         ProblemDefinition{Name: "Sum", Difficulty: "easy", ProblemSeed: "func Sum(x, y Number) int {\n}"},
+			expectedErr: "problem seed: seed.go:1:15: undefined: Number",
+		},
+		{
+			name:        "FunctionMissing",
+			def:         ProblemDefinition{Name: "Sum", Difficulty: "easy", ProblemSeed: "func Add(x, y int) int {\n}"},
+			expectedErr: "function Sum not found in problem seed",
+		},
+		{
+			name:        "NoResult",
+			def:         ProblemDefinition{Name: "Sum", Difficulty: "easy", ProblemSeed: "func Sum(x, y int) {\n}"},
+			expectedErr: "function Sum must return a single value",
+		},
+		{
+			name: "TestFileDoesNotCompile",
+			def: ProblemDefinition{Name: "IsPalindrome", Difficulty: "easy", ProblemType: ProblemTypeGoTest,
+				ProblemSeed: "func IsPalindrome(s string) bool {\n}",
+				TestFile:    "package solution\n\nimport \"testing\"\n\nfunc TestIsPalindrome(t *testing.T) {\n\tIsPalindrome(1)\n}\n"},
+			expectedErr: "solution_test.go:6:15: cannot use 1",
+		},
+		{
+			name:        "CheckerRequired",
+			def:         ProblemDefinition{Name: "Sum", Difficulty: "easy", ProblemSeed: "func Sum(x, y int) int {\n}", CompareMode: CompareChecker},
+			expectedErr: `compare mode "checker" requires checker_code`,
+		},
+		{
+			name:        "InvalidDifficulty",
+			def:         ProblemDefinition{Name: "Sum", Difficulty: "trivial", ProblemSeed: "func Sum(x, y int) int {\n}"},
+			expectedErr: `difficulty must be easy, medium or hard, got "trivial"`,
+		},
+		{
+			name:        "InvalidName",
+			def:         ProblemDefinition{Name: "Two Sum", Difficulty: "easy", ProblemSeed: "func Sum(x, y int) int {\n}"},
+			expectedErr: `name must be the Go identifier of the problem's function, got "Two Sum"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateProblem(&tt.def)
+			if tt.expectedErr == "" {
+				ok(t, err)
+				return
+			}
+			assert(t, err != nil && strings.Contains(err.Error(), tt.expectedErr), "expected error containing %q, got %v", tt.expectedErr, err)
+		})
+	}
+}
+
+func TestValidateTestCase(t *testing.T) {
+	twoSum := ProblemDefinition{Name: "TwoSum", Difficulty: "easy", ProblemSeed: "func TwoSum(nums []int, target int) []int {\n}"}
+	tree := ProblemDefinition{Name: "MaxDepth", Difficulty: "easy", ProblemSeed: "func MaxDepth(root *TreeNode) int {\n}"}
+	design := ProblemDefinition{Name: "MinStack", Difficulty: "medium", ProblemType: ProblemTypeDesign, ProblemSeed: `type MinStack struct{}
+
+func Constructor() MinStack {
+}
+
+func (this *MinStack) Push(val int) {
+}
+
+func (this *MinStack) GetMin() int {
+}`}
+
+	tests := []struct {
+		name        string
+		def         ProblemDefinition
+		test        ProblemExample
+		expectedErr string
+	}{
+		{
+			name: "Function",
+			def:  twoSum,
+			test: ProblemExample{Input: `{"nums": [2, 7, 11, 15], "target": 9}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": [0, 1]}`},
+		},
+		{
+			name: "Tree",
+			def:  tree,
+			test: ProblemExample{Input: `{"root": [3, 9, 20, null, null, 15, 7]}`, InputOrder: `["root"]`, ExpectedOutput: `{"depth": 3}`},
+		},
+		{
+			name: "Design",
+			def:  design,
+			test: ProblemExample{Input: `{"operations": ["MinStack", "push", "getMin"], "arguments": [[], [-2], []]}`, InputOrder: `[]`,
+				ExpectedOutput: `{"expected": [null, null, -2]}`},
+		},
+		{
+			name:        "WrongArgumentType",
+			def:         twoSum,
+			test:        ProblemExample{Input: `{"nums": [2, "7"], "target": 9}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": [0, 1]}`},
+			expectedErr: "input nums: element 1: expected number for int, got string",
+		},
+		{
+			name:        "FractionalInteger",
+			def:         twoSum,
+			test:        ProblemExample{Input: `{"nums": [2, 7], "target": 9.5}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": [0, 1]}`},
+			expectedErr: "input target: expected integer for int, got 9.5",
+		},
+		{
+			name:        "InputOrderDoesNotMatchParameters",
+			def:         twoSum,
+			test:        ProblemExample{Input: `{"nums": [2, 7], "target": 9}`, InputOrder: `["target", "nums"]`, ExpectedOutput: `{"indices": [0, 1]}`},
+			expectedErr: `input_order[0] is "target" but parameter 1 is nums`,
+		},
+		{
+			name:        "MissingInput",
+			def:         twoSum,
+			test:        ProblemExample{Input: `{"nums": [2, 7]}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": [0, 1]}`},
+			expectedErr: "missing input: target",
+		},
+		{
+			name:        "WrongExpectedType",
+			def:         twoSum,
+			test:        ProblemExample{Input: `{"nums": [2, 7], "target": 9}`, InputOrder: `["nums", "target"]`, ExpectedOutput: `{"indices": true}`},
+			expectedErr: "expected_output: expected array for []int, got bool",
+		},
+		{
+			name:        "NullInList",
+			def:         ProblemDefinition{Name: "ReverseList", Difficulty: "easy", ProblemSeed: "func ReverseList(head *ListNode) *ListNode {\n}"},
+			test:        ProblemExample{Input: `{"head": [1, null]}`, InputOrder: `["head"]`, ExpectedOutput: `{"head": [1]}`},
+			expectedErr: "input head: element 1: expected number for int, got null",
+		},
+		{
+			name: "UnknownDesignOperation",
+			def:  design,
+			test: ProblemExample{Input: `{"operations": ["MinStack", "pop"], "arguments": [[], []]}`, InputOrder: `[]`,
+				ExpectedOutput: `{"expected": [null, null]}`},
+			expectedErr: `operation 2: no method matches "pop"`,
+		},
+		{
+			name: "VoidMethodWithExpectedValue",
+			def:  design,
+			test: ProblemExample{Input: `{"operations": ["MinStack", "push"], "arguments": [[], [1]]}`, InputOrder: `[]`,
+				ExpectedOutput: `{"expected": [null, 1]}`},
+			expectedErr: "operation 2 (push): method returns nothing but expects 1",
+		},
+		{
+			name: "DesignLengthMismatch",
+			def:  design,
+			test: ProblemExample{Input: `{"operations": ["MinStack", "push"], "arguments": [[]]}`, InputOrder: `[]`,
+				ExpectedOutput: `{"expected": [null, null]}`},
+			expectedErr: "input has 2 operations, 1 argument lists and 2 expected values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := validateProblem(&tt.def)
+			ok(t, err)
+
+			err = validateTestCase(sig, tt.test)
+			if tt.expectedErr == "" {
+				ok(t, err)
+				return
+			}
+			assert(t, err != nil && strings.Contains(err.Error(), tt.expectedErr), "expected error containing %q, got %v", tt.expectedErr, err)
+		})
+	}
+}
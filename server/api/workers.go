@@ -0,0 +1,356 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Path of the health check every worker serves
+const workerHealthPath = "/healthz"
+
+// Time allowed for a worker to answer a health check, or its DNS name to resolve
+const workerCheckTimeout = 2 * time.Second
+
+var errNoWorkers = errors.New("no worker services configured")
+
+// Wrapper function for resolving the host name workers are discovered by
+var lookupHostWrapper = net.DefaultResolver.LookupHost
+
+// workerEndpoint is a worker service submissions can be sent to
+type workerEndpoint struct {
+	url      string
+	inFlight int
+	healthy  bool
+}
+
+// WorkerPool spreads submissions across worker services, sending each to the
+// healthy worker with the fewest submissions in flight
+type WorkerPool struct {
+	mu        sync.Mutex
+	endpoints []*workerEndpoint
+	next      int    // Where the search for the least busy worker starts, so ties take turns
+	discover  string // URL whose host name resolves to the workers, if they are found by DNS
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Create a pool of the workers at the given URLs, or of those found by
+// resolving the host name of discover when it is set. Workers are taken to be
+// healthy until a health check says otherwise.
+func NewWorkerPool(urls []string, discover string) *WorkerPool {
+	pool := &WorkerPool{discover: discover}
+	if discover == "" {
+		pool.setEndpoints(urls)
+	}
+	return pool
+}
+
+// Workers submissions are sent to, replaced by SetWorkerPool at startup
+var workerPool = NewWorkerPool(DefaultConfig().WorkerURLs, "")
+
+// Send submissions to the workers of a pool
+func SetWorkerPool(pool *WorkerPool) {
+	workerPool = pool
+}
+
+// Find and check the workers now, then again every interval until Stop is called
+func (pool *WorkerPool) StartHealthChecks(interval time.Duration) {
+	pool.stop = make(chan struct{})
+	pool.done = make(chan struct{})
+	pool.Refresh()
+
+	go func() {
+		defer close(pool.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pool.stop:
+				return
+			case <-ticker.C:
+				pool.Refresh()
+			}
+		}
+	}()
+}
+
+// Stop checking the workers
+func (pool *WorkerPool) Stop() {
+	if pool.stop == nil {
+		return
+	}
+	close(pool.stop)
+	<-pool.done
+}
+
+// Rediscover the workers, when found by DNS, and check the health of each
+func (pool *WorkerPool) Refresh() {
+	if pool.discover != "" {
+		urls, err := discoverWorkers(pool.discover)
+		if err != nil {
+			log.Printf("Failed to discover workers: %v", err)
+		} else {
+			pool.setEndpoints(urls)
+		}
+	}
+
+	pool.mu.Lock()
+	endpoints := append([]*workerEndpoint(nil), pool.endpoints...)
+	pool.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint *workerEndpoint) {
+			defer wg.Done()
+			pool.setHealth(endpoint, checkWorkerHealth(endpoint.url))
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+// Replace the pool's workers, keeping the state of those still in it. Changes
+// are logged for workers found by DNS.
+func (pool *WorkerPool) setEndpoints(urls []string) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	existing := make(map[string]*workerEndpoint, len(pool.endpoints))
+	for _, endpoint := range pool.endpoints {
+		existing[endpoint.url] = endpoint
+	}
+
+	endpoints := make([]*workerEndpoint, 0, len(urls))
+	for _, workerURL := range urls {
+		endpoint, ok := existing[workerURL]
+		if ok {
+			delete(existing, workerURL)
+		} else {
+			endpoint = &workerEndpoint{url: workerURL, healthy: true}
+			if pool.discover != "" {
+				log.Printf("Discovered worker %s", workerURL)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	for workerURL := range existing {
+		log.Printf("Worker %s no longer resolved, removed it", workerURL)
+	}
+	pool.endpoints = endpoints
+}
+
+// Record the outcome of a health check or request, logging when a worker goes down or comes back
+func (pool *WorkerPool) setHealth(endpoint *workerEndpoint, err error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	switch {
+	case err != nil && endpoint.healthy:
+		log.Printf("Worker %s is down: %v", endpoint.url, err)
+	case err == nil && !endpoint.healthy:
+		log.Printf("Worker %s is back up", endpoint.url)
+	}
+	endpoint.healthy = err == nil
+}
+
+// Take the worker to send a submission to: the healthy one with the fewest
+// submissions in flight, skipping those already tried. Workers that failed
+// their last health check are only used when no other is left.
+func (pool *WorkerPool) acquire(tried map[*workerEndpoint]bool) *workerEndpoint {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var best *workerEndpoint
+	for i := range pool.endpoints {
+		endpoint := pool.endpoints[(pool.next+i)%len(pool.endpoints)]
+		if tried[endpoint] {
+			continue
+		}
+		if best == nil || (endpoint.healthy && !best.healthy) ||
+			(endpoint.healthy == best.healthy && endpoint.inFlight < best.inFlight) {
+			best = endpoint
+		}
+	}
+	if best != nil {
+		best.inFlight++
+		pool.next++
+	}
+	return best
+}
+
+// Give back a worker taken by acquire
+func (pool *WorkerPool) release(endpoint *workerEndpoint) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	endpoint.inFlight--
+}
+
+// Post a submission to a worker, asking for its progress to be streamed. A
+// worker that can't be reached never saw the submission, so the next is
+// tried. The worker counts the submission as in flight until the response
+// body is closed.
+func (pool *WorkerPool) post(ctx context.Context, body []byte) (*http.Response, error) {
+	tried := make(map[*workerEndpoint]bool)
+	lastErr := errNoWorkers
+	for {
+		endpoint := pool.acquire(tried)
+		if endpoint == nil {
+			return nil, lastErr
+		}
+		tried[endpoint] = true
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.url, bytes.NewReader(body))
+		if err != nil {
+			pool.release(endpoint)
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", workerStreamContentType)
+		if err := signWorkerRequest(req, body, serverConfig.WorkerSecret); err != nil {
+			pool.release(endpoint)
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { pool.release(endpoint) }}
+			return resp, nil
+		}
+		pool.release(endpoint)
+		if ctx.Err() != nil || !isConnectError(err) {
+			return nil, err
+		}
+
+		log.Printf("Worker %s unreachable, trying another: %v", endpoint.url, err)
+		pool.setHealth(endpoint, err)
+		lastErr = err
+	}
+}
+
+// Headers a request to a worker is signed with
+const (
+	workerTimestampHeader = "X-Leetgo-Timestamp" // Unix seconds when the request was signed
+	workerNonceHeader     = "X-Leetgo-Nonce"     // Random value never sent twice, so workers can reject replays
+	workerSignatureHeader = "X-Leetgo-Signature" // Hex HMAC-SHA256 of the request, see signWorkerRequest
+)
+
+// Sign a request to a worker with the secret they share
+func signWorkerRequest(req *http.Request, body []byte, secret string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+	// The worker sees the path of a URL without one as /
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	req.Header.Set(workerTimestampHeader, timestamp)
+	req.Header.Set(workerNonceHeader, nonceHex)
+	req.Header.Set(workerSignatureHeader, workerSignature(secret, req.Method, path, timestamp, nonceHex, body))
+	return nil
+}
+
+// Compute the signature of a request to a worker: the HMAC-SHA256, keyed by
+// the shared secret, of its method, path, timestamp, nonce and body, one per line
+func workerSignature(secret, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, method+"\n"+path+"\n"+timestamp+"\n"+nonce+"\n")
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Return whether a request failed before reaching the server
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// releasingBody gives back the worker that sent a response once its body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (body *releasingBody) Close() error {
+	body.once.Do(body.release)
+	return body.ReadCloser.Close()
+}
+
+// Check a worker's health endpoint, next to the URL submissions are sent to
+func checkWorkerHealth(workerURL string) error {
+	u, err := url.Parse(workerURL)
+	if err != nil {
+		return err
+	}
+	u.Path, u.RawQuery = workerHealthPath, ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), workerCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Resolve the host name of a worker URL, e.g. http://leetgo-worker.internal:8081/process-code,
+// into the URL of each worker it names
+func discoverWorkers(discover string) ([]string, error) {
+	u, err := url.Parse(discover)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workerCheckTimeout)
+	defer cancel()
+	addresses, err := lookupHostWrapper(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+
+	urls := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		worker := *u
+		worker.Host = net.JoinHostPort(address, port)
+		urls = append(urls, worker.String())
+	}
+	return urls, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestWorkerPoolAcquire(t *testing.T) {
+	pool := NewWorkerPool([]string{"http://a", "http://b", "http://c"}, "")
+	a, b, c := pool.endpoints[0], pool.endpoints[1], pool.endpoints[2]
+
+	// Idle workers take turns, then the least busy is chosen
+	equals(t, a, pool.acquire(nil))
+	equals(t, b, pool.acquire(nil))
+	equals(t, c, pool.acquire(nil))
+	pool.release(b)
+	equals(t, b, pool.acquire(nil))
+
+	// Healthy workers come first, however busy
+	a.inFlight, b.inFlight, c.inFlight = 3, 0, 1
+	b.healthy, c.healthy = false, false
+	equals(t, a, pool.acquire(nil))
+	equals(t, b, pool.acquire(map[*workerEndpoint]bool{a: true}))
+	var none *workerEndpoint
+	equals(t, none, pool.acquire(map[*workerEndpoint]bool{a: true, b: true, c: true}))
+	equals(t, []int{4, 1, 1}, []int{a.inFlight, b.inFlight, c.inFlight})
+}
+
+func TestWorkerPoolPost(t *testing.T) {
+	originalConfig := serverConfig
+	defer func() { serverConfig = originalConfig }()
+	serverConfig.WorkerSecret = "s3cret"
+
+	nonces := make(map[string]bool)
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, workerStreamContentType, r.Header.Get("Accept"))
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp, nonce := r.Header.Get(workerTimestampHeader), r.Header.Get(workerNonceHeader)
+		equals(t, workerSignature("s3cret", r.Method, r.URL.Path, timestamp, nonce, body), r.Header.Get(workerSignatureHeader))
+		assert(t, !nonces[nonce], "nonce %q sent twice", nonce)
+		nonces[nonce] = true
+		w.Write(body)
+	}))
+	defer worker.Close()
+	down := closedURL(t)
+
+	t.Run("RetriesUnreachableWorker", func(t *testing.T) {
+		pool := NewWorkerPool([]string{down, worker.URL}, "")
+		resp, err := pool.post(context.Background(), []byte(`{"code":"x"}`))
+		ok(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		equals(t, `{"code":"x"}`, string(body))
+
+		equals(t, []bool{false, true}, []bool{pool.endpoints[0].healthy, pool.endpoints[1].healthy})
+		equals(t, []int{0, 1}, []int{pool.endpoints[0].inFlight, pool.endpoints[1].inFlight})
+		resp.Body.Close()
+		resp.Body.Close()
+		equals(t, 0, pool.endpoints[1].inFlight)
+	})
+
+	t.Run("AllUnreachable", func(t *testing.T) {
+		pool := NewWorkerPool([]string{down}, "")
+		_, err := pool.post(context.Background(), nil)
+		assert(t, isConnectError(err), "expected a connection error, got %v", err)
+		equals(t, 0, pool.endpoints[0].inFlight)
+	})
+
+	t.Run("NoWorkers", func(t *testing.T) {
+		_, err := NewWorkerPool(nil, "").post(context.Background(), nil)
+		equals(t, errNoWorkers, err)
+	})
+}
+
+func TestWorkerSignature(t *testing.T) {
+	// Workers check requests against the same signature
+	equals(t, "a707caed4ce85e63aa8d1b9531dae518a701fe6b4c2df939a55da026d72adcad",
+		workerSignature("s3cret", http.MethodPost, "/process-code", "1700000000", "0123456789abcdef", []byte(`{"code":"x"}`)))
+}
+
+func TestWorkerPoolRefresh(t *testing.T) {
+	healthy := true
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, workerHealthPath, r.URL.Path)
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer worker.Close()
+
+	pool := NewWorkerPool([]string{worker.URL + "/process-code", closedURL(t)}, "")
+	pool.Refresh()
+	equals(t, []bool{true, false}, []bool{pool.endpoints[0].healthy, pool.endpoints[1].healthy})
+
+	healthy = false
+	pool.Refresh()
+	equals(t, false, pool.endpoints[0].healthy)
+}
+
+func TestDiscoverWorkers(t *testing.T) {
+	originalLookupHost := lookupHostWrapper
+	defer func() { lookupHostWrapper = originalLookupHost }()
+
+	addresses := []string{"127.0.0.1"}
+	lookupHostWrapper = func(ctx context.Context, host string) ([]string, error) {
+		equals(t, "workers.internal", host)
+		if addresses == nil {
+			return nil, errors.New("no such host")
+		}
+		return addresses, nil
+	}
+
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer worker.Close()
+	_, port, err := net.SplitHostPort(worker.Listener.Addr().String())
+	ok(t, err)
+
+	pool := NewWorkerPool([]string{"http://ignored"}, "http://workers.internal:"+port+"/process-code")
+	equals(t, 0, len(pool.endpoints))
+	pool.Refresh()
+	equals(t, 1, len(pool.endpoints))
+	first := pool.endpoints[0]
+	equals(t, "http://127.0.0.1:"+port+"/process-code", first.url)
+	first.inFlight = 2
+
+	// Workers still resolved keep their state, and the lookup failing keeps the last workers found
+	addresses = []string{"::1", "127.0.0.1"}
+	pool.Refresh()
+	equals(t, []string{"http://127.0.0.1:" + port + "/process-code", "http://[::1]:" + port + "/process-code"},
+		[]string{pool.endpoints[0].url, pool.endpoints[1].url})
+	equals(t, first, pool.endpoints[0])
+	addresses = nil
+	pool.Refresh()
+	equals(t, 2, len(pool.endpoints))
+
+	urls, err := discoverWorkers("https://workers.internal/process-code")
+	equals(t, errors.New("no such host"), err)
+	equals(t, 0, len(urls))
+}
+
+// Return the URL of a port nothing is listening on
+func closedURL(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+	return (&url.URL{Scheme: "http", Host: address, Path: "/process-code"}).String()
+}
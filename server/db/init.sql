@@ -161,7 +161,3 @@ VALUES
 (7, 3, '{"nums": [2, 7, 11, 15], "target": 9}', '["nums", "target"]', '{"indices": [0, 1]}'),
 (8, 3, '{"nums": [3, 2, 4], "target": 6}', '["nums", "target"]', '{"indices": [1, 2]}'),
 (9, 3, '{"nums": [3, 3], "target": 6}', '["nums", "target"]', '{"indices": [0, 1]}');
-
--- Insert sample user
-INSERT OR IGNORE INTO users (id, username, email, password)
-VALUES (1, 'Test User', 'test@nowhere.com', '123456');
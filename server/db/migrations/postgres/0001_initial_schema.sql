@@ -0,0 +1,62 @@
+-- Problems table: stores problem metadata
+CREATE TABLE IF NOT EXISTS problems (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    short_description TEXT,
+    long_description TEXT,
+    problem_seed TEXT,
+    examples TEXT,
+    difficulty TEXT,
+    attempts INTEGER DEFAULT 0,
+    solves INTEGER DEFAULT 0,
+    compare_mode TEXT DEFAULT 'exact', -- exact, unordered, set, float or checker
+    compare_tolerance DOUBLE PRECISION DEFAULT 0, -- allowed absolute difference in float mode
+    checker_code TEXT, -- Go source defining Check(expected, actual T) bool in checker mode
+    problem_type TEXT DEFAULT 'function', -- function, design for Constructor plus method call sequences, or gotest
+    test_file TEXT, -- hidden _test.go source in package solution, run against gotest submissions
+    reference_solution TEXT -- known-good solution that test cases are checked against when authored, never sent to users
+);
+
+-- Problem examples table: stores inputs and expected outputs for validation
+CREATE TABLE IF NOT EXISTS problem_examples (
+    id SERIAL PRIMARY KEY,
+    problem_id INTEGER REFERENCES problems(id) ON DELETE CASCADE,
+    input TEXT NOT NULL,
+    input_order TEXT NOT NULL,
+    expected_output TEXT NOT NULL,
+    is_hidden BOOLEAN DEFAULT FALSE, -- hidden cases count towards results but are never shown to users
+    explanation TEXT -- shown with visible cases in the problem's examples
+);
+
+-- Problem images table (optional): stores images related to the problem
+CREATE TABLE IF NOT EXISTS problem_images (
+    id SERIAL PRIMARY KEY,
+    problem_id INTEGER REFERENCES problems(id) ON DELETE CASCADE,
+    image_url TEXT,
+    description TEXT
+);
+
+-- Users table: stores users who are solving problems
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    email TEXT UNIQUE,
+    password TEXT NOT NULL, -- bcrypt hash, plaintext seeds are hashed at startup
+    is_admin BOOLEAN DEFAULT FALSE -- admins may create, edit and delete problems
+);
+
+-- User solutions table: stores the solutions submitted by users
+CREATE TABLE IF NOT EXISTS user_solutions (
+    id SERIAL PRIMARY KEY,
+    problem_id INTEGER REFERENCES problems(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    session_id TEXT, -- anonymous browser session, when no user is logged in
+    solution_code TEXT NOT NULL,
+    status TEXT,
+    verdict TEXT,
+    test_passed INTEGER DEFAULT 0,
+    test_count INTEGER DEFAULT 0,
+    runtime_ms DOUBLE PRECISION DEFAULT 0, -- total duration of the test cases
+    results TEXT, -- JSON array of per-test results, hidden cases redacted
+    date_submitted TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
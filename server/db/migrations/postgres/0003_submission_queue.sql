@@ -0,0 +1,8 @@
+-- Submissions are graded in the background. status now tracks the grading job
+-- (QUEUED, RUNNING or DONE) and the outcome it held before moves to result.
+-- Submissions made before the queue have all been graded.
+ALTER TABLE user_solutions RENAME COLUMN status TO result;
+ALTER TABLE user_solutions ADD COLUMN status TEXT NOT NULL DEFAULT 'DONE';
+ALTER TABLE user_solutions ADD COLUMN feedback TEXT; -- JSON of the failure details and output shown to the user
+
+CREATE INDEX IF NOT EXISTS idx_user_solutions_status ON user_solutions(status);
@@ -0,0 +1,3 @@
+-- Submissions whose status hasn't changed for a while were lost by their queue
+-- and are queued again. Existing unfinished submissions count as stale.
+ALTER TABLE user_solutions ADD COLUMN status_changed_at BIGINT NOT NULL DEFAULT 0; -- Unix seconds of the last status change
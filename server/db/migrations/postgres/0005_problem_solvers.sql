@@ -0,0 +1,14 @@
+-- One row per user who has solved a problem, so that only their first accepted
+-- submission counts as a solve, however many are graded at once. Anonymous
+-- sessions don't count as solvers.
+CREATE TABLE IF NOT EXISTS problem_solvers (
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (problem_id, user_id)
+);
+
+INSERT INTO problem_solvers (problem_id, user_id)
+SELECT DISTINCT problem_id, user_id FROM user_solutions
+WHERE verdict = 'ACCEPTED' AND problem_id IS NOT NULL AND user_id IS NOT NULL;
+
+UPDATE problems SET solves = (SELECT COUNT(*) FROM problem_solvers WHERE problem_solvers.problem_id = problems.id);
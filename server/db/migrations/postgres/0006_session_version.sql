@@ -0,0 +1,6 @@
+-- Sessions carry their user's session version and are only accepted while it
+-- is current. Logging out or revoking a user's sessions moves it on.
+ALTER TABLE users ADD COLUMN session_version INTEGER NOT NULL DEFAULT 0;
+
+-- The seeded test user had a well-known password, so anyone could log in as it
+DELETE FROM users WHERE id = 1 AND username = 'Test User' AND email = 'test@nowhere.com';
@@ -35,7 +35,7 @@ CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     username TEXT UNIQUE NOT NULL,
     email TEXT UNIQUE,
-    password TEXT NOT NULL
+    password TEXT NOT NULL -- bcrypt hash, plaintext seeds are hashed at startup
 );
 
 -- User solutions table: stores the solutions submitted by users
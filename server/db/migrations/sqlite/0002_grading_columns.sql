@@ -0,0 +1,20 @@
+-- Columns added for grading, hidden tests, submission history and problem authoring
+
+ALTER TABLE problems ADD COLUMN compare_mode TEXT DEFAULT 'exact'; -- exact, unordered, set, float or checker
+ALTER TABLE problems ADD COLUMN compare_tolerance REAL DEFAULT 0; -- allowed absolute difference in float mode
+ALTER TABLE problems ADD COLUMN checker_code TEXT; -- Go source defining Check(expected, actual T) bool in checker mode
+ALTER TABLE problems ADD COLUMN problem_type TEXT DEFAULT 'function'; -- function, design for Constructor plus method call sequences, or gotest
+ALTER TABLE problems ADD COLUMN test_file TEXT; -- hidden _test.go source in package solution, run against gotest submissions
+ALTER TABLE problems ADD COLUMN reference_solution TEXT; -- known-good solution that test cases are checked against when authored, never sent to users
+
+ALTER TABLE problem_examples ADD COLUMN is_hidden INTEGER DEFAULT 0; -- hidden cases count towards results but are never shown to users
+ALTER TABLE problem_examples ADD COLUMN explanation TEXT; -- shown with visible cases in the problem's examples
+
+ALTER TABLE users ADD COLUMN is_admin INTEGER DEFAULT 0; -- admins may create, edit and delete problems
+
+ALTER TABLE user_solutions ADD COLUMN session_id TEXT; -- anonymous browser session, when no user is logged in
+ALTER TABLE user_solutions ADD COLUMN verdict TEXT;
+ALTER TABLE user_solutions ADD COLUMN test_passed INTEGER DEFAULT 0;
+ALTER TABLE user_solutions ADD COLUMN test_count INTEGER DEFAULT 0;
+ALTER TABLE user_solutions ADD COLUMN runtime_ms REAL DEFAULT 0; -- total duration of the test cases
+ALTER TABLE user_solutions ADD COLUMN results TEXT; -- JSON array of per-test results, hidden cases redacted
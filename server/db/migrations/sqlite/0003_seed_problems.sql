@@ -0,0 +1,178 @@
+-- Insert "Palindrome" problem
+INSERT OR IGNORE INTO problems (id, name, short_description, long_description, problem_seed, examples, difficulty) 
+VALUES (
+    1,
+    'Palindrome',
+    'Check if a string is a palindrome',
+    'A phrase is a palindrome if, after converting all uppercase letters into lowercase letters and removing all non-alphanumeric characters, it reads the same forward and backward. Alphanumeric characters include letters and numbers.<br><br>Given a string <code>s</code>, return <code>true</code> if it is a palindrome, or <code>false</code> otherwise.',
+    'func Palindrome(s string) bool {
+    
+}',
+    NULL,
+    'easy'
+    );
+
+-- Insert test cases for the "Palindrome" problem
+INSERT OR IGNORE INTO problem_examples (id, problem_id, input, input_order, expected_output, is_hidden, explanation)
+VALUES
+(1, 1, '{"s": "radar"}', '["s"]', '{"result": true}', 0, '"radar" is a palindrome.'),
+(2, 1, '{"s": "hello"}', '["s"]', '{"result": false}', 0, '"hello" is not a palindrome.'),
+(3, 1, '{"s": "A man a plan a canal Panama"}', '["s"]', '{"result": true}', 0, '"amanaplanacanalpanama" is a palindrome.'),
+(12, 1, '{"s": "0P"}', '["s"]', '{"result": false}', 1, NULL),
+(13, 1, '{"s": " "}', '["s"]', '{"result": true}', 1, NULL);
+
+-- Insert "Sum" problem
+INSERT OR IGNORE INTO problems (id, name, short_description, long_description, problem_seed, examples, difficulty) 
+VALUES (
+    2, 
+    'Sum', 
+    'Return the sum of two integers', 
+    'Write a function that returns the sum of two integers.', 
+    'func Sum(x, y int) int {
+    
+}', 
+    NULL,
+    'easy'
+);
+
+-- Insert test cases for the "Sum" problem
+INSERT OR IGNORE INTO problem_examples (id, problem_id, input, input_order, expected_output, is_hidden, explanation)
+VALUES
+(4, 2, '{"x": 1, "y": 2}', '["x", "y"]', '{"result": 3}', 0, '1 plus 2 equals 3.'),
+(5, 2, '{"x": -1, "y": 2}', '["x", "y"]', '{"result": 1}', 0, '-1 plus 2 equals 1.'),
+(6, 2, '{"x": 0, "y": 0}', '["x", "y"]', '{"result": 0}', 0, '0 plus 0 equals 0.'),
+(14, 2, '{"x": -1000000, "y": -2000000}', '["x", "y"]', '{"result": -3000000}', 1, NULL);
+
+-- Insert "Two Sum" problem
+INSERT OR IGNORE INTO problems (id, name, short_description, long_description, problem_seed, examples, difficulty, compare_mode) 
+VALUES (
+    3, 
+    'TwoSum', 
+    'Return the indexes of the two numbers that sum to the target', 
+    'Given an array of integers <code>nums</code> and an integer <code>target</code>, return <i>indices of the two numbers such that they add up to <code>target</code></i>.<br></br>You may assume that each input would have <strong><i>exactly</i> one solution</strong>, and you may not use the <i>same</i> element twice.<br></br>You can return the answer in any order.', 
+    'func TwoSum(nums []int, target int) []int {
+    
+}', 
+    NULL,
+    'easy',
+    'unordered'
+);
+
+-- Insert test cases for the "Two Sum" problem
+INSERT OR IGNORE INTO problem_examples (id, problem_id, input, input_order, expected_output, is_hidden, explanation)
+VALUES
+(7, 3, '{"nums": [2, 7, 11, 15], "target": 9}', '["nums", "target"]', '{"indices": [0, 1]}', 0, 'Because nums[0] + nums[1] == 9, we return [0, 1].'),
+(8, 3, '{"nums": [3, 2, 4], "target": 6}', '["nums", "target"]', '{"indices": [1, 2]}', 0, 'Because nums[1] + nums[2] == 6, we return [1, 2].'),
+(9, 3, '{"nums": [3, 3], "target": 6}', '["nums", "target"]', '{"indices": [0, 1]}', 0, 'Because nums[0] + nums[1] == 6, we return [0, 1].'),
+(15, 3, '{"nums": [-3, 4, 3, 90], "target": 0}', '["nums", "target"]', '{"indices": [0, 2]}', 1, NULL);
+
+-- Insert "Min Stack" design problem
+INSERT OR IGNORE INTO problems (id, name, short_description, long_description, problem_seed, examples, difficulty, problem_type) 
+VALUES (
+    4, 
+    'MinStack', 
+    'Design a stack that retrieves its minimum element in constant time', 
+    'Design a stack that supports push, pop, top, and retrieving the minimum element in constant time.<br></br>Implement the <code>MinStack</code> type:<ul><li><code>Constructor()</code> initializes the stack object.</li><li><code>Push(val int)</code> pushes the element <code>val</code> onto the stack.</li><li><code>Pop()</code> removes the element on the top of the stack.</li><li><code>Top() int</code> gets the top element of the stack.</li><li><code>GetMin() int</code> retrieves the minimum element in the stack.</li></ul>Methods <code>Pop</code>, <code>Top</code> and <code>GetMin</code> are always called on non-empty stacks.', 
+    'type MinStack struct {
+    
+}
+
+func Constructor() MinStack {
+    
+}
+
+func (this *MinStack) Push(val int) {
+    
+}
+
+func (this *MinStack) Pop() {
+    
+}
+
+func (this *MinStack) Top() int {
+    
+}
+
+func (this *MinStack) GetMin() int {
+    
+}', 
+    NULL,
+    'medium',
+    'design'
+);
+
+-- Insert test cases for the "Min Stack" problem
+INSERT OR IGNORE INTO problem_examples (id, problem_id, input, input_order, expected_output, is_hidden, explanation)
+VALUES
+(10, 4, '{"operations": ["MinStack", "push", "push", "push", "getMin", "pop", "top", "getMin"], "arguments": [[], [-2], [0], [-3], [], [], [], []]}', '[]', '{"expected": [null, null, null, null, -3, null, 0, -2]}', 0, 'After pushing -2, 0 and -3 the minimum is -3. Popping -3 leaves 0 on top and -2 as the minimum.'),
+(11, 4, '{"operations": ["MinStack", "push", "top", "push", "getMin", "pop", "getMin"], "arguments": [[], [5], [], [1], [], [], []]}', '[]', '{"expected": [null, null, 5, null, 1, null, 5]}', 1, NULL);
+
+-- Insert "Valid Palindrome" problem, graded by a Go test file
+INSERT OR IGNORE INTO problems (id, name, short_description, long_description, problem_seed, examples, difficulty, problem_type, test_file) 
+VALUES (
+    5, 
+    'IsPalindrome', 
+    'Determine whether a phrase reads the same forward and backward', 
+    'A phrase is a <strong>palindrome</strong> if, after converting all uppercase letters into lowercase letters and removing all non-alphanumeric characters, it reads the same forward and backward.<br></br>Given a string <code>s</code>, return <code>true</code> <i>if it is a <strong>palindrome</strong>, or</i> <code>false</code> <i>otherwise</i>.', 
+    'func IsPalindrome(s string) bool {
+    
+}', 
+    '[
+    {
+        "input": "s = \"A man, a plan, a canal: Panama\"",
+        "output": "true",
+        "explanation": "\"amanaplanacanalpanama\" is a palindrome."
+    },
+    {
+        "input": "s = \"race a car\"",
+        "output": "false",
+        "explanation": "\"raceacar\" is not a palindrome."
+    }
+]',
+    'easy',
+    'gotest',
+    'package solution
+
+import (
+    "testing"
+    "unicode/utf8"
+)
+
+func TestIsPalindrome(t *testing.T) {
+    tests := []struct {
+        name string
+        s    string
+        want bool
+    }{
+        {"phrase", "A man, a plan, a canal: Panama", true},
+        {"not a palindrome", "race a car", false},
+        {"empty after filtering", " ", true},
+        {"digits", "0P", false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := IsPalindrome(tt.s)
+            if got != tt.want {
+                t.Errorf("IsPalindrome(%q) = %v, want %v", tt.s, got, tt.want)
+            }
+        })
+    }
+}
+
+func FuzzIsPalindromeMirrored(f *testing.F) {
+    f.Add("abc")
+    f.Add("Ab1")
+    f.Fuzz(func(t *testing.T, s string) {
+        if !utf8.ValidString(s) {
+            t.Skip()
+        }
+        reversed := ""
+        for _, r := range s {
+            reversed = string(r) + reversed
+        }
+        if !IsPalindrome(s + reversed) {
+            t.Errorf("IsPalindrome(%q) = false, want true", s+reversed)
+        }
+    })
+}'
+);
@@ -1,55 +1,278 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/smcgarril/leetgo/api"
 )
 
-var db *sql.DB
+var (
+	store   api.Store
+	workers *api.WorkerPool
+)
 
 func main() {
-	var err error
+	// The first argument names the command, and the server is started without one
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	config, args, err := api.LoadConfig(command, args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+	api.SetConfig(config)
+	workers = api.NewWorkerPool(config.WorkerURLs, config.WorkerDNS)
+	api.SetWorkerPool(workers)
 
-	// Connect to SQLite database
-	db, err = sql.Open("sqlite3", "./db/db.sqlite3")
+	store, err = api.OpenStore(config.DatabaseURL)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("Error connecting to database: ", err)
+	}
+	defer store.Close()
+
+	log.Printf("Successfully connected to %s database!", store.Dialect())
+	migrations, err := api.LoadMigrations(os.DirFS(filepath.Join(config.MigrationsDir, store.Dialect())))
+	if err != nil {
+		log.Fatal("Error loading migrations: ", err)
+	}
+
+	// Migrations are inspected or applied without bringing the schema up to date first
+	if command == "migrate" {
+		runMigrateCommand(args, migrations)
+		return
 	}
-	defer db.Close()
 
-	// Test connection
-	err = db.Ping()
+	applied, err := store.MigrateUp(migrations)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("Error migrating database: ", err)
 	}
+	log.Printf("Database schema is up to date, applied %d pending migrations", applied)
 
-	// Seed database and test query
-	log.Printf("Successfully connected to SQLite database!")
-	log.Printf("Seeding database file...")
-	api.SeedFiles(db)
+	if err := api.MigratePasswords(store); err != nil {
+		log.Fatal("Error hashing stored passwords: ", err)
+	}
+
+	// Maintenance commands run against the database and exit
+	if command != "serve" {
+		runCommand(command, args)
+		return
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unexpected arguments to serve: %v", args)
+	}
 
 	log.Printf("Testing database query...")
-	api.QueryProblems(db)
+	api.QueryProblems(store)
+
+	serve(config)
+}
+
+// Serve the API and front-end until the process is signaled to stop
+func serve(config api.Config) {
+	queue, err := api.OpenQueue(config.QueueURL, config.QueueSize)
+	if err != nil {
+		log.Fatal("Error opening submission queue: ", err)
+	}
+	if config.WorkerSecret == "" {
+		log.Printf("WORKER_SECRET is not set, workers will reject submissions")
+	}
+	workers.StartHealthChecks(config.WorkerHealth)
+	defer workers.Stop()
+	runner := api.StartJobRunner(store, queue, config.QueueWorkers)
+	runner.RequeueStale(config.RequeueInterval, config.RequeueAfter)
+
+	// Jobs held in memory were lost when the server last stopped
+	if config.QueueURL == api.MemoryQueueURL {
+		requeued, err := api.RequeueUnfinishedSubmissions(store, queue, 0)
+		if err != nil {
+			log.Printf("Error requeueing unfinished submissions: %v", err)
+		}
+		if requeued > 0 {
+			log.Printf("Requeued %d unfinished submissions", requeued)
+		}
+	}
 
 	// Create router
 	router := mux.NewRouter()
+	router.Use(api.AuthMiddleware(store))
 
 	// API routes
-	router.HandleFunc("/problems", api.GetAllProblemsHandler(db)).Methods("GET")
-	router.HandleFunc("/problems/names", api.GetProblemNamesHandler(db)).Methods("GET")
-	router.HandleFunc("/problems/{id}", api.GetProblemDetailsHandler(db)).Methods("GET")
-	router.HandleFunc("/execute", api.ExecuteCodeHandler(db)).Methods("POST")
-	router.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./public"))))
+	router.HandleFunc("/problems", api.GetAllProblemsHandler(store)).Methods("GET")
+	router.HandleFunc("/problems/names", api.GetProblemNamesHandler(store)).Methods("GET")
+	router.HandleFunc("/problems/{id}", api.GetProblemDetailsHandler(store)).Methods("GET")
+	router.HandleFunc("/problems/{id}/submissions", api.GetProblemSubmissionsHandler(store)).Methods("GET")
+	router.HandleFunc("/problems", api.CreateProblemHandler(store)).Methods("POST")
+	router.HandleFunc("/problems/{id}", api.UpdateProblemHandler(store)).Methods("PUT")
+	router.HandleFunc("/problems/{id}", api.DeleteProblemHandler(store)).Methods("DELETE")
+	router.HandleFunc("/problems/{id}/tests", api.GetProblemTestsHandler(store)).Methods("GET")
+	router.HandleFunc("/problems/{id}/tests", api.CreateProblemTestHandler(store)).Methods("POST")
+	router.HandleFunc("/problems/{id}/tests/{testId}", api.UpdateProblemTestHandler(store)).Methods("PUT")
+	router.HandleFunc("/problems/{id}/tests/{testId}", api.DeleteProblemTestHandler(store)).Methods("DELETE")
+	router.HandleFunc("/execute", api.ExecuteCodeHandler(store, queue)).Methods("POST")
+	router.HandleFunc("/submissions", api.GetSubmissionsHandler(store)).Methods("GET")
+	router.HandleFunc("/submissions/{id}", api.GetSubmissionHandler(store)).Methods("GET")
+	router.HandleFunc("/submissions/{id}/events", api.GetSubmissionEventsHandler(store)).Methods("GET")
+	router.HandleFunc("/auth/register", api.RegisterHandler(store)).Methods("POST")
+	router.HandleFunc("/auth/login", api.LoginHandler(store)).Methods("POST")
+	router.HandleFunc("/auth/logout", api.LogoutHandler(store)).Methods("POST")
+	router.HandleFunc("/auth/me", api.GetCurrentUser).Methods("GET")
+	router.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir(config.PublicDir))))
 
 	// Enable CORS for all origins (for development purposes)
 	corsHandler := handlers.CORS(handlers.AllowedOrigins([]string{"*"}))(router)
 
-	log.Printf("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", corsHandler))
+	server := &http.Server{
+		Addr:         config.HTTPAddr,
+		Handler:      http.MaxBytesHandler(corsHandler, config.MaxRequestBytes),
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+	server.RegisterOnShutdown(api.CloseEventStreams)
+
+	// Stop on SIGINT or SIGTERM, which fly.io sends before replacing a machine
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Server running on %s", config.HTTPAddr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+	stop()
+
+	// Stop accepting requests and let running requests and grading jobs finish,
+	// up to the deadline. The database is closed once serve returns.
+	log.Printf("Shutting down, waiting up to %s for running requests and jobs", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Requests still running after %s, closing their connections", config.ShutdownTimeout)
+		server.Close()
+	}
+	if err := runner.Stop(shutdownCtx); err != nil {
+		log.Printf("Jobs still running after %s, leaving them unfinished", config.ShutdownTimeout)
+	}
+	queue.Close()
+	log.Printf("Server stopped")
+}
+
+// Run a maintenance command
+func runCommand(command string, args []string) {
+	switch command {
+	case "backfill-counters":
+		updated, err := store.BackfillProblemCounters()
+		if err != nil {
+			log.Fatal("Error backfilling problem counters: ", err)
+		}
+		log.Printf("Recomputed attempts and solves for %d problems", updated)
+	case "grant-admin":
+		if len(args) != 1 {
+			log.Fatal("Usage: grant-admin [flags] <username>")
+		}
+		if err := store.GrantAdmin(args[0]); err != nil {
+			log.Fatal("Error granting admin access: ", err)
+		}
+		log.Printf("Granted admin access to %s", args[0])
+	case "revoke-sessions":
+		if len(args) != 1 {
+			log.Fatal("Usage: revoke-sessions [flags] <username>")
+		}
+		user, _, err := store.GetUserByUsername(args[0])
+		if err != nil {
+			log.Fatalf("Error finding user %s: %v", args[0], err)
+		}
+		if err := store.RevokeSessions(user.ID); err != nil {
+			log.Fatal("Error revoking sessions: ", err)
+		}
+		log.Printf("Revoked the sessions of %s", args[0])
+	case "problems":
+		runProblemsCommand(args)
+	default:
+		log.Fatalf("Unknown command %q (available: serve, migrate, backfill-counters, grant-admin, revoke-sessions, problems)", command)
+	}
+}
+
+// Import or export problem packs
+func runProblemsCommand(args []string) {
+	const usage = "Usage: problems import [-skip-reference-check] <dir> | problems export <dir>"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet("problems "+args[0], flag.ExitOnError)
+	skipCheck := flags.Bool("skip-reference-check", false, "import without running reference solutions through the worker")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		log.Fatal(usage)
+	}
+	dir := flags.Arg(0)
+
+	switch args[0] {
+	case "import":
+		if !*skipCheck {
+			workers.Refresh()
+		}
+		imported, err := api.ImportProblemPacks(store, dir, !*skipCheck)
+		if err != nil {
+			log.Fatal("Error importing problems: ", err)
+		}
+		log.Printf("Imported %d problems from %s", imported, dir)
+	case "export":
+		exported, err := api.ExportProblemPacks(store, dir)
+		if err != nil {
+			log.Fatal("Error exporting problems: ", err)
+		}
+		log.Printf("Exported %d problems to %s", exported, dir)
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// Show or apply the database migrations
+func runMigrateCommand(args []string, migrations []api.Migration) {
+	const usage = "Usage: migrate status | migrate up"
+	if len(args) != 1 {
+		log.Fatal(usage)
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := store.GetMigrationStatus(migrations)
+		if err != nil {
+			log.Fatal("Error reading migration status: ", err)
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != "" {
+				appliedAt = "applied " + status.AppliedAt
+			}
+			log.Printf("%04d_%s: %s", status.Version, status.Name, appliedAt)
+		}
+	case "up":
+		applied, err := store.MigrateUp(migrations)
+		if err != nil {
+			log.Fatal("Error migrating database: ", err)
+		}
+		log.Printf("Applied %d migrations", applied)
+	default:
+		log.Fatal(usage)
+	}
 }
@@ -4,6 +4,10 @@ const initialCode = `func main() {
 
 let editor;
 let currentProblem = null;
+let errorLines = [];
+
+// How often a queued submission is checked until it has been graded
+const SUBMISSION_POLL_INTERVAL_MS = 500;
 
 document.addEventListener("DOMContentLoaded", init);
 
@@ -123,7 +127,7 @@ function renderExamples(examples) {
                 const exampleHtml = `
                     <div style="margin-bottom: 1em;">
                         <p><strong>Example ${index + 1}:</strong></p>
-                        <p style="margin-left: 1em;">Input: ${example.input}</p>
+                        <p style="margin-left: 1em; white-space: pre-line;">Input: ${example.input}</p>
                         <p style="margin-left: 1em;">Output: ${example.output}</p>
                         <p style="margin-left: 1em;">Explanation: ${example.explanation}</p>
                     </div>`;
@@ -139,12 +143,14 @@ function renderExamples(examples) {
 
 // Clear previous results from the UI
 function clearResults() {
-    ['results', 'failure-details'].forEach(id => {
+    clearErrorLines();
+
+    ['results', 'progress', 'failure-details', 'stdout-details'].forEach(id => {
         const element = document.getElementById(id);
         if (element) element.style.display = 'none';
     });
 
-    ['result', 'testPassed', 'testCount', 'failure-input', 'failure-expected', 'failure-actual'].forEach(id => {
+    ['result', 'testPassed', 'testCount', 'failure-input', 'failure-expected', 'failure-actual', 'stdout'].forEach(id => {
         const element = document.getElementById(id);
         if (element) element.innerText = '';
     });
@@ -191,15 +197,74 @@ async function runCode() {
         });
 
         if (!response.ok) throw new Error('Failed to execute code');
-        
-        const data = await response.json();
-        displayResults(data);
+
+        const queued = await response.json();
+        const submission = await watchSubmission(queued.submission_id);
+        displayResults(submission);
     } catch (error) {
         logError('Error running code:', error);
         document.getElementById('result').innerText = `Error running code: ${error.message}`;
     }
 }
 
+// Follow a queued submission's progress as the server streams it, until it has been graded
+function watchSubmission(submissionId) {
+    return new Promise((resolve, reject) => {
+        const events = new EventSource(`/submissions/${submissionId}/events`);
+        const onUpdate = event => displayProgress(JSON.parse(event.data));
+        events.addEventListener('status', onUpdate);
+        events.addEventListener('progress', onUpdate);
+        events.addEventListener('done', event => {
+            events.close();
+            resolve(JSON.parse(event.data));
+        });
+
+        // The browser reconnects when a stream ends early, but gives up on a failed
+        // request, e.g. behind a proxy that doesn't pass streams on
+        events.onerror = () => {
+            if (events.readyState !== EventSource.CLOSED) return;
+            waitForSubmission(submissionId).then(resolve, reject);
+        };
+    });
+}
+
+// Poll a queued submission until it has been graded, showing its progress
+async function waitForSubmission(submissionId) {
+    while (true) {
+        const response = await fetch(`/submissions/${submissionId}`);
+        if (!response.ok) throw new Error('Failed to fetch submission');
+
+        const submission = await response.json();
+        if (submission.status === 'DONE') return submission;
+
+        displayProgress({ status: submission.status });
+        await new Promise(resolve => setTimeout(resolve, SUBMISSION_POLL_INTERVAL_MS));
+    }
+}
+
+// Show how far grading has got, from a status or progress update
+function displayProgress(update) {
+    const resultElement = document.getElementById('result');
+    const progressElement = document.getElementById('progress');
+    const labels = { QUEUED: 'Queued…', COMPILING: 'Compiling…', RUNNING: 'Running…' };
+    const stage = update.stage ?? update.status;
+    if (stage === 'DONE') return;
+
+    document.getElementById('results').style.display = 'block';
+    progressElement.style.display = 'block';
+    resultElement.classList.remove('success', 'failure');
+    resultElement.innerText = labels[stage] ?? stage;
+
+    // Tests are only counted once the worker knows how many there are
+    if (stage === 'RUNNING' && update.total) {
+        const current = Math.min(update.completed + 1, update.total);
+        resultElement.innerText = `Running test ${current} of ${update.total}…`;
+        progressElement.value = update.completed / update.total;
+    } else {
+        progressElement.removeAttribute('value');
+    }
+}
+
 // Display code execution results
 function displayResults(data) {
     const resultsElement = document.getElementById('results');
@@ -207,8 +272,10 @@ function displayResults(data) {
     const failureDetailsElement = document.getElementById('failure-details');
 
     resultsElement.style.display = 'block';
+    document.getElementById('progress').style.display = 'none';
 
-    resultElement.innerText = data.result.trim();
+    resultElement.innerText = (data.verdict || data.result).trim();
+    clearErrorLines();
 
     resultElement.classList.remove('success', 'failure');
 
@@ -219,10 +286,28 @@ function displayResults(data) {
         resultElement.classList.add('failure');
         failureDetailsElement.style.display = 'block'; 
         displayFailureDetails(data);
+    } else if (data.result === "ERROR") {
+        // The submission couldn't be graded, and the output says why
+        resultElement.classList.add('failure');
+        resultElement.innerText = data.output ? `ERROR: ${data.output}` : 'ERROR';
+        failureDetailsElement.style.display = 'none';
+    } else {
+        // Resource limit results (TIME_LIMIT_EXCEEDED, MEMORY_LIMIT_EXCEEDED, ...)
+        resultElement.classList.add('failure');
+        failureDetailsElement.style.display = 'none';
     }
 
     document.getElementById('testPassed').innerText = data.testPassed ?? 'N/A';
     document.getElementById('testCount').innerText = data.testCount ?? 'N/A';
+
+    displayStdout(data.stdout);
+}
+
+// Display anything the solution printed, kept apart from the test results
+function displayStdout(stdout) {
+    const stdoutDetailsElement = document.getElementById('stdout-details');
+    document.getElementById('stdout').innerText = stdout ?? '';
+    stdoutDetailsElement.style.display = stdout ? 'block' : 'none';
 }
 
 // Display failure details in a separate block
@@ -234,6 +319,33 @@ function displayFailureDetails(data) {
     failureInputElement.innerText = formatDataForDisplay(data.input);
     failureExpectedElement.innerText = formatDataForDisplay(data.expected);
     failureActualElement.innerText = data.output ?? '';
+
+    if (data.diagnostics && data.diagnostics.length > 0) {
+        failureActualElement.innerText = data.diagnostics.map(formatDiagnostic).join('\n');
+        highlightErrorLines(data.diagnostics);
+    }
+}
+
+// Format a compiler or runtime diagnostic for display
+function formatDiagnostic(diagnostic) {
+    if (!diagnostic.line) return diagnostic.message;
+    const column = diagnostic.column ? `:${diagnostic.column}` : '';
+    return `Line ${diagnostic.line}${column}: ${diagnostic.message}`;
+}
+
+// Highlight the editor lines referenced by diagnostics
+function highlightErrorLines(diagnostics) {
+    diagnostics.forEach(diagnostic => {
+        if (!diagnostic.line) return;
+        const handle = editor.addLineClass(diagnostic.line - 1, 'background', 'error-line');
+        if (handle) errorLines.push(handle);
+    });
+}
+
+// Remove diagnostic highlights from the editor
+function clearErrorLines() {
+    errorLines.forEach(handle => editor.removeLineClass(handle, 'background', 'error-line'));
+    errorLines = [];
 }
 
 // Format data for display
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers the server signs each request with
+const (
+	timestampHeader = "X-Leetgo-Timestamp" // Unix seconds when the request was signed
+	nonceHeader     = "X-Leetgo-Nonce"     // Random value the server never sends twice
+	signatureHeader = "X-Leetgo-Signature" // Hex HMAC-SHA256 of the request, see signRequest
+)
+
+// How far a request's timestamp may be from the worker's clock. Older requests
+// are rejected, so nonces only need remembering for this long on either side.
+const signatureMaxAge = 5 * time.Minute
+
+// Wrapper function for the current time
+var nowWrapper = time.Now
+
+// Compute the signature of a request: the HMAC-SHA256, keyed by the shared
+// secret, of its method, path, timestamp, nonce and body, one per line
+func signRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, method+"\n"+path+"\n"+timestamp+"\n"+nonce+"\n")
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Only pass on requests signed with the shared secret, answering others and
+// any request seen before with a 401
+func RequireSignature(secret string, next http.Handler) http.Handler {
+	nonces := &nonceCache{seen: make(map[string]time.Time)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, &WorkerError{Code: ErrorInvalidRequest, Message: "Invalid request body: " + err.Error()})
+			return
+		}
+
+		if err := verifySignature(secret, r, body, nonces); err != nil {
+			log.Printf("Rejected request from %s: %v", r.RemoteAddr, err)
+			respondWithError(w, &WorkerError{Code: ErrorUnauthorized, Message: "Request rejected: " + err.Error()})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check a request's signature and that its nonce is new. The nonce is only
+// remembered once the signature is valid, so unsigned requests can't fill the cache.
+func verifySignature(secret string, r *http.Request, body []byte, nonces *nonceCache) error {
+	timestamp, nonce, signature := r.Header.Get(timestampHeader), r.Header.Get(nonceHeader), r.Header.Get(signatureHeader)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return errors.New("missing signature")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	now := nowWrapper()
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return errors.New("timestamp too far from the worker's clock")
+	}
+
+	expected := signRequest(secret, r.Method, r.URL.Path, timestamp, nonce, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+
+	if !nonces.add(nonce, now) {
+		return errors.New("replayed request")
+	}
+	return nil
+}
+
+// nonceCache remembers the nonces of accepted requests for as long as their
+// timestamps could still be accepted
+type nonceCache struct {
+	mu    sync.Mutex
+	seen  map[string]time.Time // When each nonce was accepted
+	order []string             // Nonces in the order they were accepted, oldest first
+}
+
+// Remember a nonce, returning false if it was already seen
+func (cache *nonceCache) add(nonce string, now time.Time) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// A timestamp up to signatureMaxAge ahead of the clock stays valid for
+	// twice that, so nonces are kept until then
+	for len(cache.order) > 0 && now.Sub(cache.seen[cache.order[0]]) > 2*signatureMaxAge {
+		delete(cache.seen, cache.order[0])
+		cache.order = cache.order[1:]
+	}
+
+	if _, ok := cache.seen[nonce]; ok {
+		return false
+	}
+	cache.seen[nonce] = now
+	cache.order = append(cache.order, nonce)
+	return true
+}
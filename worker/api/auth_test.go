@@ -0,0 +1,89 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	// The server signs with the same function, and checks the same signature
+	equals(t, "a707caed4ce85e63aa8d1b9531dae518a701fe6b4c2df939a55da026d72adcad",
+		signRequest("s3cret", http.MethodPost, "/process-code", "1700000000", "0123456789abcdef", []byte(`{"code":"x"}`)))
+}
+
+func TestRequireSignature(t *testing.T) {
+	originalNow := nowWrapper
+	defer func() { nowWrapper = originalNow }()
+	now := time.Unix(1700000000, 0)
+	nowWrapper = func() time.Time { return now }
+
+	var received []string
+	handler := RequireSignature("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+	}))
+
+	// Sign a request as the server would, then let the test tamper with it
+	request := func(nonce string, signedAt time.Time, secret string, tamper func(r *http.Request)) *httptest.ResponseRecorder {
+		body := `{"code":"x"}`
+		r := httptest.NewRequest(http.MethodPost, "/process-code", strings.NewReader(body))
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		r.Header.Set(timestampHeader, timestamp)
+		r.Header.Set(nonceHeader, nonce)
+		r.Header.Set(signatureHeader, signRequest(secret, r.Method, r.URL.Path, timestamp, nonce, []byte(body)))
+		if tamper != nil {
+			tamper(r)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	tests := []struct {
+		name         string
+		nonce        string
+		signedAt     time.Time
+		secret       string
+		tamper       func(r *http.Request)
+		expectedCode int
+	}{
+		{"Signed", "a", now, "s3cret", nil, http.StatusOK},
+		{"Replayed", "a", now, "s3cret", nil, http.StatusUnauthorized},
+		{"Unsigned", "b", now, "s3cret", func(r *http.Request) { r.Header.Del(signatureHeader) }, http.StatusUnauthorized},
+		{"WrongSecret", "c", now, "guess", nil, http.StatusUnauthorized},
+		{"BodyChanged", "d", now, "s3cret", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"code":"y"}`)) }, http.StatusUnauthorized},
+		{"Stale", "e", now.Add(-signatureMaxAge - time.Second), "s3cret", nil, http.StatusUnauthorized},
+		{"FromTheFuture", "f", now.Add(signatureMaxAge + time.Second), "s3cret", nil, http.StatusUnauthorized},
+		{"SlowClock", "g", now.Add(-signatureMaxAge), "s3cret", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := request(tt.nonce, tt.signedAt, tt.secret, tt.tamper)
+			equals(t, tt.expectedCode, recorder.Code)
+			if tt.expectedCode == http.StatusUnauthorized {
+				assert(t, strings.Contains(recorder.Body.String(), `"code":"UNAUTHORIZED"`), "unexpected body %q", recorder.Body.String())
+			}
+		})
+	}
+	equals(t, []string{`{"code":"x"}`, `{"code":"x"}`}, received)
+}
+
+func TestNonceCache(t *testing.T) {
+	cache := &nonceCache{seen: make(map[string]time.Time)}
+	start := time.Unix(1700000000, 0)
+
+	equals(t, true, cache.add("a", start))
+	equals(t, false, cache.add("a", start.Add(time.Minute)))
+	equals(t, true, cache.add("b", start.Add(time.Minute)))
+
+	// Nonces are forgotten once no request carrying them could be accepted
+	equals(t, true, cache.add("c", start.Add(2*signatureMaxAge+time.Second)))
+	equals(t, []string{"b", "c"}, cache.order)
+	equals(t, true, cache.add("a", start.Add(2*signatureMaxAge+time.Second)))
+}
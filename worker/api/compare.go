@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Comparison modes a problem may declare for its expected outputs
+const (
+	CompareExact     = "exact"
+	CompareUnordered = "unordered"
+	CompareSet       = "set"
+	CompareFloat     = "float"
+	CompareChecker   = "checker"
+)
+
+// Tolerance used in float mode when the problem doesn't specify one
+const defaultFloatTolerance = 1e-6
+
+// Name of the workspace file holding a problem-supplied checker
+const checkerFile = "checker.go"
+
+// Return the Go expression the harness uses to compare output against expected
+func comparisonExpr(submission CodeSubmission) (string, error) {
+	switch submission.CompareMode {
+	case "", CompareExact:
+		return "leetgoEqualExact(output, expected)", nil
+	case CompareUnordered:
+		return "leetgoEqualUnordered(output, expected)", nil
+	case CompareSet:
+		return "leetgoEqualSet(output, expected)", nil
+	case CompareFloat:
+		tolerance := submission.Tolerance
+		if tolerance <= 0 {
+			tolerance = defaultFloatTolerance
+		}
+		return fmt.Sprintf("leetgoEqualFloat(output, expected, %g)", tolerance), nil
+	case CompareChecker:
+		if strings.TrimSpace(submission.Checker) == "" {
+			return "", fmt.Errorf("compare mode %q requires checker code", CompareChecker)
+		}
+		return "Check(expected, output)", nil
+	}
+	return "", fmt.Errorf("unsupported compare mode: %s", submission.CompareMode)
+}
+
+// Build the checker file for the workspace, or return empty if the problem has none
+func generateCheckerFile(submission CodeSubmission) string {
+	if submission.CompareMode != CompareChecker {
+		return ""
+	}
+	return "package main\n\n" + submission.Checker + "\n"
+}
+
+// Comparison helpers appended to every harness
+const compareHelpers = `
+		// Format a value for comparison and display, formatting linked structures
+		// and slice elements the way fmt can't
+		func leetgoFormat(value any) string {
+			if formatted, ok := leetgoFormatStructure(value); ok {
+				return formatted
+			}
+			v := leetgoReflect.ValueOf(value)
+			if v.Kind() == leetgoReflect.Slice && !v.IsNil() {
+				elements := make([]string, v.Len())
+				for i := range elements {
+					elements[i] = leetgoFormat(v.Index(i).Interface())
+				}
+				return "[" + leetgoStrings.Join(elements, " ") + "]"
+			}
+			return fmt.Sprint(value)
+		}
+
+		func leetgoEqualExact(output, expected any) bool {
+			return leetgoFormat(output) == leetgoFormat(expected)
+		}
+
+		// Format the elements of a slice or array and sort them, ignoring their order
+		func leetgoSortedElements(value any) []string {
+			v := leetgoReflect.ValueOf(value)
+			if v.Kind() != leetgoReflect.Slice && v.Kind() != leetgoReflect.Array {
+				return []string{leetgoFormat(value)}
+			}
+			elements := make([]string, v.Len())
+			for i := range elements {
+				elements[i] = leetgoFormat(v.Index(i).Interface())
+			}
+			leetgoSort.Strings(elements)
+			return elements
+		}
+
+		func leetgoEqualUnordered(output, expected any) bool {
+			return leetgoReflect.DeepEqual(leetgoSortedElements(output), leetgoSortedElements(expected))
+		}
+
+		func leetgoEqualSet(output, expected any) bool {
+			unique := func(elements []string) []string {
+				var result []string
+				for i, element := range elements {
+					if i == 0 || element != elements[i-1] {
+						result = append(result, element)
+					}
+				}
+				return result
+			}
+			return leetgoReflect.DeepEqual(unique(leetgoSortedElements(output)), unique(leetgoSortedElements(expected)))
+		}
+
+		func leetgoEqualFloat(output, expected any, tolerance float64) bool {
+			o, e := leetgoReflect.ValueOf(output), leetgoReflect.ValueOf(expected)
+			if (o.Kind() == leetgoReflect.Slice || o.Kind() == leetgoReflect.Array) &&
+				(e.Kind() == leetgoReflect.Slice || e.Kind() == leetgoReflect.Array) {
+				if o.Len() != e.Len() {
+					return false
+				}
+				for i := 0; i < o.Len(); i++ {
+					if !leetgoEqualFloat(o.Index(i).Interface(), e.Index(i).Interface(), tolerance) {
+						return false
+					}
+				}
+				return true
+			}
+			of, ok := leetgoToFloat(o)
+			ef, ok2 := leetgoToFloat(e)
+			if !ok || !ok2 {
+				return leetgoEqualExact(output, expected)
+			}
+			return leetgoMath.Abs(of-ef) <= tolerance
+		}
+
+		func leetgoToFloat(v leetgoReflect.Value) (float64, bool) {
+			switch v.Kind() {
+			case leetgoReflect.Float32, leetgoReflect.Float64:
+				return v.Float(), true
+			case leetgoReflect.Int, leetgoReflect.Int8, leetgoReflect.Int16, leetgoReflect.Int32, leetgoReflect.Int64:
+				return float64(v.Int()), true
+			case leetgoReflect.Uint, leetgoReflect.Uint8, leetgoReflect.Uint16, leetgoReflect.Uint32, leetgoReflect.Uint64:
+				return float64(v.Uint()), true
+			}
+			return 0, false
+		}
+`
@@ -0,0 +1,143 @@
+package api
+
+import "testing"
+
+func TestComparisonExpr(t *testing.T) {
+	tests := []struct {
+		name       string
+		submission CodeSubmission
+		expected   string
+		wantErr    bool
+	}{
+		{"Default", CodeSubmission{}, "leetgoEqualExact(output, expected)", false},
+		{"Unordered", CodeSubmission{CompareMode: CompareUnordered}, "leetgoEqualUnordered(output, expected)", false},
+		{"Set", CodeSubmission{CompareMode: CompareSet}, "leetgoEqualSet(output, expected)", false},
+		{"FloatDefaultTolerance", CodeSubmission{CompareMode: CompareFloat}, "leetgoEqualFloat(output, expected, 1e-06)", false},
+		{"FloatTolerance", CodeSubmission{CompareMode: CompareFloat, Tolerance: 0.01}, "leetgoEqualFloat(output, expected, 0.01)", false},
+		{"Checker", CodeSubmission{CompareMode: CompareChecker, Checker: "func Check(expected, actual int) bool { return true }"}, "Check(expected, output)", false},
+		{"CheckerMissingCode", CodeSubmission{CompareMode: CompareChecker}, "", true},
+		{"UnknownMode", CodeSubmission{CompareMode: "fuzzy"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := comparisonExpr(tt.submission)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if expr != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, expr)
+			}
+		})
+	}
+}
+
+func TestProcessCodeCompareModes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	tests := []struct {
+		name       string
+		submission CodeSubmission
+		expected   string
+	}{
+		{
+			name: "Exact rejects reordered slice",
+			submission: CodeSubmission{
+				Code:    "func Pair() []int {\n\treturn []int{1, 0}\n}",
+				Problem: "Pair",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": [0, 1]}`},
+				},
+			},
+			expected: "FAILED",
+		},
+		{
+			name: "Unordered accepts reordered slice",
+			submission: CodeSubmission{
+				Code:        "func Pair() []int {\n\treturn []int{1, 0}\n}",
+				Problem:     "Pair",
+				CompareMode: CompareUnordered,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": [0, 1]}`},
+				},
+			},
+			expected: "PASSED",
+		},
+		{
+			name: "Unordered keeps duplicates",
+			submission: CodeSubmission{
+				Code:        "func Pair() []int {\n\treturn []int{1, 1, 0}\n}",
+				Problem:     "Pair",
+				CompareMode: CompareUnordered,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": [0, 1]}`},
+				},
+			},
+			expected: "FAILED",
+		},
+		{
+			name: "Set ignores duplicates",
+			submission: CodeSubmission{
+				Code:        "func Pair() []int {\n\treturn []int{1, 1, 0}\n}",
+				Problem:     "Pair",
+				CompareMode: CompareSet,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": [0, 1]}`},
+				},
+			},
+			expected: "PASSED",
+		},
+		{
+			name: "Float within tolerance",
+			submission: CodeSubmission{
+				Code:        "func Third() float64 {\n\treturn 1.0 / 3.0\n}",
+				Problem:     "Third",
+				CompareMode: CompareFloat,
+				Tolerance:   0.001,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": 0.3333}`},
+				},
+			},
+			expected: "PASSED",
+		},
+		{
+			name: "Float outside tolerance",
+			submission: CodeSubmission{
+				Code:        "func Third() float64 {\n\treturn 1.0 / 3.0\n}",
+				Problem:     "Third",
+				CompareMode: CompareFloat,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": 0.3333}`},
+				},
+			},
+			expected: "FAILED",
+		},
+		{
+			name: "Checker function",
+			submission: CodeSubmission{
+				Code:        "func Even(n int) int {\n\treturn n * 4\n}",
+				Problem:     "Even",
+				CompareMode: CompareChecker,
+				Checker:     "func Check(expected, actual int) bool {\n\treturn actual%2 == 0\n}",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{"n": 3}`, InputOrder: `["n"]`, ExpectedOutput: `{"result": 6}`},
+				},
+			},
+			expected: "PASSED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(tt.submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Result != tt.expected {
+				t.Errorf("Expected result %s, got %s: %+v", tt.expected, output.Result, output)
+			}
+		})
+	}
+}
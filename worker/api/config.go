@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds the worker settings. Each one has a default, which the config
+// file overrides, then its environment variable, then its flag.
+type Config struct {
+	ConfigFile      string
+	HTTPAddr        string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
+	MaxRequestBytes int64
+	Secret          string // Shared with the server, which signs every submission with it
+	SecretFile      string // File holding the secret, read instead of setting it directly
+	SandboxUID      int    // First of the users submissions run as, or 0 for the worker's own
+	Limits          Limits
+}
+
+// Return the settings used when nothing overrides them
+func DefaultConfig() Config {
+	return Config{
+		HTTPAddr:        ":8081",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		ShutdownTimeout: 20 * time.Second,
+		MaxRequestBytes: 4 << 20,
+		Limits:          ExecutionLimits,
+	}
+}
+
+// Environment variable overriding each flag
+var configEnv = []struct{ flag, env string }{
+	{"config", "CONFIG_FILE"},
+	{"http", "HTTP_ADDR"},
+	{"read-timeout", "READ_TIMEOUT"},
+	{"write-timeout", "WRITE_TIMEOUT"},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT"},
+	{"max-request-bytes", "MAX_REQUEST_BYTES"},
+	{"secret", "WORKER_SECRET"},
+	{"secret-file", "WORKER_SECRET_FILE"},
+	{"sandbox-uid", "SANDBOX_UID"},
+	{"compile-timeout", "COMPILE_TIMEOUT"},
+	{"run-timeout", "RUN_TIMEOUT"},
+	{"cpu-time", "CPU_TIME_LIMIT"},
+	{"memory-limit", "MEMORY_LIMIT"},
+	{"output-limit", "OUTPUT_LIMIT"},
+	{"process-limit", "PROCESS_LIMIT"},
+}
+
+// Define a flag for each setting, defaulting to its current value
+func (c *Config) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&c.ConfigFile, "config", c.ConfigFile, "JSON config file mapping flag names to values ($CONFIG_FILE)")
+	flags.StringVar(&c.HTTPAddr, "http", c.HTTPAddr, "address to serve HTTP on ($HTTP_ADDR)")
+	flags.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "time allowed to read a request ($READ_TIMEOUT)")
+	flags.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "time allowed to grade a submission and write its response ($WRITE_TIMEOUT)")
+	flags.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed for running submissions to finish at shutdown ($SHUTDOWN_TIMEOUT)")
+	flags.Int64Var(&c.MaxRequestBytes, "max-request-bytes", c.MaxRequestBytes, "largest request body accepted ($MAX_REQUEST_BYTES)")
+	flags.StringVar(&c.SecretFile, "secret-file", c.SecretFile, "file holding the secret the server signs submissions with ($WORKER_SECRET_FILE)")
+	flags.IntVar(&c.SandboxUID, "sandbox-uid", c.SandboxUID, "first of the user IDs submissions run as, one per CPU, or 0 to run them as the worker's user ($SANDBOX_UID)")
+	flags.DurationVar(&c.Limits.CompileTime, "compile-timeout", c.Limits.CompileTime, "wall-clock time allowed to compile a submission ($COMPILE_TIMEOUT)")
+	flags.DurationVar(&c.Limits.WallTime, "run-timeout", c.Limits.WallTime, "wall-clock time allowed to run a submission ($RUN_TIMEOUT)")
+	flags.DurationVar(&c.Limits.CPUTime, "cpu-time", c.Limits.CPUTime, "CPU time allowed to run a submission ($CPU_TIME_LIMIT)")
+	flags.Int64Var(&c.Limits.MemoryBytes, "memory-limit", c.Limits.MemoryBytes, "bytes of memory a submission may use ($MEMORY_LIMIT)")
+	flags.IntVar(&c.Limits.OutputBytes, "output-limit", c.Limits.OutputBytes, "bytes of output captured before a submission is killed ($OUTPUT_LIMIT)")
+	flags.IntVar(&c.Limits.Processes, "process-limit", c.Limits.Processes, "processes and threads a submission may use when run as a sandbox user ($PROCESS_LIMIT)")
+	return flags
+}
+
+// Define the flags of flagSet along with those of the secrets, which can only
+// be set by the config file or the environment. Command lines can be read by
+// every process on the machine, including the code the workers run.
+func (c *Config) settingSet(name string) *flag.FlagSet {
+	flags := c.flagSet(name)
+	flags.StringVar(&c.Secret, "secret", c.Secret, "secret the server signs submissions with ($WORKER_SECRET)")
+	return flags
+}
+
+// Load the settings for a command from its flags, the environment and the
+// config file. Returns the arguments left after the flags.
+func LoadConfig(command string, args []string, getenv func(string) string) (Config, []string, error) {
+	// The config file is found first, since everything else overrides it
+	probe := DefaultConfig()
+	if err := probe.flagSet(command).Parse(args); err != nil {
+		return probe, nil, err
+	}
+	path := probe.ConfigFile
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+
+	config := DefaultConfig()
+	flags := config.flagSet(command)
+	settings := config.settingSet(command)
+	if path != "" {
+		if err := loadConfigFile(settings, path); err != nil {
+			return config, nil, err
+		}
+	}
+
+	for _, setting := range configEnv {
+		if value := getenv(setting.env); value != "" {
+			if err := settings.Set(setting.flag, value); err != nil {
+				return config, nil, fmt.Errorf("invalid %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+	config.ConfigFile = path
+
+	if config.SecretFile != "" {
+		if config.Secret != "" {
+			return config, nil, fmt.Errorf("set only one of WORKER_SECRET and WORKER_SECRET_FILE")
+		}
+		secret, err := os.ReadFile(config.SecretFile)
+		if err != nil {
+			return config, nil, fmt.Errorf("reading secret file: %w", err)
+		}
+		config.Secret = strings.TrimSpace(string(secret))
+	}
+	return config, flags.Args(), nil
+}
+
+// Set the flags named in a JSON config file, e.g. {"http": ":9091", "run-timeout": "5s"}
+func loadConfigFile(flags *flag.FlagSet, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	for name, raw := range values {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		// Strings are set unquoted, and numbers and booleans as written
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: invalid %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "worker.json")
+	ok(t, os.WriteFile(file, []byte(`{"http": ":9091", "run-timeout": "5s", "memory-limit": 134217728}`), 0644))
+
+	env := map[string]string{"CONFIG_FILE": file, "RUN_TIMEOUT": "4s", "OUTPUT_LIMIT": "1024", "WORKER_SECRET": "s3cret"}
+	config, rest, err := LoadConfig("serve", []string{"--http=0.0.0.0:8081", "--cpu-time", "1s"}, func(key string) string { return env[key] })
+	ok(t, err)
+
+	expected := DefaultConfig()
+	expected.ConfigFile = file
+	expected.HTTPAddr = "0.0.0.0:8081"
+	expected.Secret = "s3cret"
+	expected.Limits.WallTime = 4 * time.Second
+	expected.Limits.CPUTime = time.Second
+	expected.Limits.MemoryBytes = 128 << 20
+	expected.Limits.OutputBytes = 1024
+	equals(t, expected, config)
+	equals(t, []string{}, rest)
+
+	// The secret would be visible to submissions on the command line
+	_, _, err = LoadConfig("serve", []string{"--secret=s3cret"}, func(string) string { return "" })
+	assert(t, err != nil, "expected --secret to be rejected")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	ok(t, os.WriteFile(secretFile, []byte("s3cret\n"), 0600))
+	env = map[string]string{"WORKER_SECRET_FILE": secretFile, "SANDBOX_UID": "60000"}
+	config, _, err = LoadConfig("serve", nil, func(key string) string { return env[key] })
+	ok(t, err)
+	equals(t, "s3cret", config.Secret)
+	equals(t, 60000, config.SandboxUID)
+
+	env["WORKER_SECRET"] = "s3cret"
+	_, _, err = LoadConfig("serve", nil, func(key string) string { return env[key] })
+	assert(t, err != nil, "expected setting both WORKER_SECRET and WORKER_SECRET_FILE to be rejected")
+
+	env = map[string]string{"MEMORY_LIMIT": "lots"}
+	_, _, err = LoadConfig("serve", nil, func(key string) string { return env[key] })
+	assert(t, err != nil && err.Error() == `invalid MEMORY_LIMIT: parse error`, "unexpected error: %v", err)
+}
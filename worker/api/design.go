@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Design describes a design-class problem: a Constructor and the methods of the
+// type it returns, as declared in the problem seed
+type Design struct {
+	Constructor *Signature
+	Methods     map[string]Method // Keyed by lower-cased method name
+}
+
+// Method is a single method of a design-class type
+type Method struct {
+	Name string
+	Sig  *Signature
+}
+
+// DesignExample is the input shape of a design-class example, e.g.
+// {"operations": ["LRUCache", "put", "get"], "arguments": [[2], [1, 1], [1]]}
+type DesignExample struct {
+	Operations []string        `json:"operations"`
+	Arguments  [][]interface{} `json:"arguments"`
+}
+
+// Parse the problem seed for the Constructor function and the methods of its result type
+func ParseDesign(seed string) (*Design, error) {
+	file, declared, err := parseSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	design := &Design{Methods: make(map[string]Method)}
+	var typeName string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "Constructor" {
+			if design.Constructor, err = signatureOf(fn, declared); err != nil {
+				return nil, err
+			}
+			if design.Constructor.Result == nil {
+				return nil, fmt.Errorf("Constructor must return the design type")
+			}
+			typeName = receiverTypeName(design.Constructor.Result)
+		}
+	}
+	if design.Constructor == nil {
+		return nil, fmt.Errorf("function Constructor not found in problem seed")
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || receiverTypeName(fn.Recv.List[0].Type) != typeName {
+			continue
+		}
+		sig, err := signatureOf(fn, declared)
+		if err != nil {
+			return nil, err
+		}
+		design.Methods[strings.ToLower(fn.Name.Name)] = Method{Name: fn.Name.Name, Sig: sig}
+	}
+
+	return design, nil
+}
+
+// Prepare the harness code for a design-class example. The object is built with
+// Constructor, then each operation is called in order; the first call whose
+// result doesn't match its expected value fails the test.
+func prepareDesignTestCall(example ProblemExample, design *Design, comparison string) (string, error) {
+	var input DesignExample
+	if err := decodeJSON(example.Input, &input); err != nil {
+		return "", fmt.Errorf("failed to parse design input JSON: %w", err)
+	}
+	expected, err := designExpectedOutputs(example.ExpectedOutput)
+	if err != nil {
+		return "", err
+	}
+	if len(input.Operations) == 0 {
+		return "", fmt.Errorf("design example has no operations")
+	}
+	if len(input.Arguments) != len(input.Operations) || len(expected) != len(input.Operations) {
+		return "", fmt.Errorf("design example has %d operations, %d argument lists and %d expected values",
+			len(input.Operations), len(input.Arguments), len(expected))
+	}
+
+	constructorArgs, err := formatCallArgs(input.Arguments[0], design.Constructor)
+	if err != nil {
+		return "", fmt.Errorf("operation 1 (%s): %w", input.Operations[0], err)
+	}
+
+	var calls strings.Builder
+	for i := 1; i < len(input.Operations); i++ {
+		operation := input.Operations[i]
+		method, ok := design.Methods[strings.ToLower(operation)]
+		if !ok {
+			return "", fmt.Errorf("operation %d: no method matches %q", i+1, operation)
+		}
+		args, err := formatCallArgs(input.Arguments[i], method.Sig)
+		if err != nil {
+			return "", fmt.Errorf("operation %d (%s): %w", i+1, operation, err)
+		}
+		encodedArgs, _ := json.Marshal(input.Arguments[i])
+		call := fmt.Sprintf("%s(%s)", operation, strings.TrimSuffix(strings.TrimPrefix(string(encodedArgs), "["), "]"))
+
+		if method.Sig.Result == nil {
+			if expected[i] != nil {
+				return "", fmt.Errorf("operation %d (%s): method returns nothing but expects %v", i+1, operation, expected[i])
+			}
+			fmt.Fprintf(&calls, `
+			obj.%s(%s)
+`, method.Name, args)
+			continue
+		}
+
+		expectedLiteral, err := method.Sig.literal(expected[i], method.Sig.Result)
+		if err != nil {
+			return "", fmt.Errorf("operation %d (%s): %w", i+1, operation, err)
+		}
+		fmt.Fprintf(&calls, `
+			{
+				output := obj.%s(%s)
+				var expected %s = %s
+				if !(%s) {
+					result.Actual = fmt.Sprintf("call %d %%s: expected %%s, got %%s", %q, leetgoFormat(expected), leetgoFormat(output))
+					return
+				}
+			}
+`, method.Name, args, types.ExprString(method.Sig.Result), expectedLiteral, comparison, i+1, call)
+	}
+
+	return fmt.Sprintf(`
+		func() {
+			start := leetgoTime.Now()
+			result := leetgoResult{ID: %d, Status: "FAILED"}
+			defer func() {
+				result.DurationMs = float64(leetgoTime.Since(start).Microseconds()) / 1000
+				leetgoReport(result)
+			}()
+			obj := Constructor(%s)
+			%s
+			result.Status = "PASSED"
+		}()
+	`, example.ID, constructorArgs, calls.String()), nil
+}
+
+// Decode the list of expected values, one per operation, from the expected output JSON
+func designExpectedOutputs(output string) ([]interface{}, error) {
+	var result map[string][]interface{}
+	if err := decodeJSON(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse expected output JSON: %w", err)
+	}
+	for _, values := range result {
+		return values, nil
+	}
+	return nil, fmt.Errorf("no value found in expected output")
+}
+
+// Format positional JSON values as typed arguments for the signature
+func formatCallArgs(values []interface{}, sig *Signature) (string, error) {
+	if len(values) != len(sig.Params) {
+		return "", fmt.Errorf("expected %d arguments, got %d", len(sig.Params), len(values))
+	}
+	args := make([]string, len(values))
+	for i, value := range values {
+		formatted, err := sig.literal(value, sig.Params[i].Type)
+		if err != nil {
+			return "", err
+		}
+		if sig.Params[i].Variadic {
+			formatted += "..."
+		}
+		args[i] = formatted
+	}
+	return strings.Join(args, ", "), nil
+}
+
+// Return the name of a type, dereferencing a pointer
+func receiverTypeName(typ ast.Expr) string {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
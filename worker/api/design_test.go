@@ -0,0 +1,210 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+const minStackSeed = `type MinStack struct {
+
+}
+
+func Constructor() MinStack {
+
+}
+
+func (this *MinStack) Push(val int)  {
+
+}
+
+func (this *MinStack) Pop()  {
+
+}
+
+func (this *MinStack) Top() int {
+
+}
+
+func (this *MinStack) GetMin() int {
+
+}`
+
+func TestParseDesign(t *testing.T) {
+	design, err := ParseDesign(minStackSeed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(design.Constructor.Params) != 0 {
+		t.Errorf("Expected no constructor params, got %d", len(design.Constructor.Params))
+	}
+	for _, name := range []string{"push", "pop", "top", "getmin"} {
+		if _, ok := design.Methods[name]; !ok {
+			t.Errorf("Expected method %s in %v", name, design.Methods)
+		}
+	}
+	if design.Methods["pop"].Sig.Result != nil {
+		t.Errorf("Expected Pop to return nothing")
+	}
+
+	if _, err := ParseDesign("func (this *MinStack) Pop() {}"); err == nil {
+		t.Errorf("Expected error for seed without Constructor")
+	}
+}
+
+func TestPrepareDesignTestCall(t *testing.T) {
+	design, err := ParseDesign(minStackSeed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name:     "Valid sequence",
+			input:    `{"operations": ["MinStack", "push", "getMin"], "arguments": [[], [-2], []]}`,
+			expected: `{"expected": [null, null, -2]}`,
+			contains: []string{"obj := Constructor()", "obj.Push(-2)", "obj.GetMin()", "var expected int = -2"},
+		},
+		{
+			name:     "Unknown operation",
+			input:    `{"operations": ["MinStack", "peek"], "arguments": [[], []]}`,
+			expected: `{"expected": [null, 1]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "Mismatched lengths",
+			input:    `{"operations": ["MinStack", "push"], "arguments": [[]]}`,
+			expected: `{"expected": [null, null]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "Wrong argument count",
+			input:    `{"operations": ["MinStack", "push"], "arguments": [[], [1, 2]]}`,
+			expected: `{"expected": [null, null]}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			example := ProblemExample{ID: 1, Input: tt.input, InputOrder: "[]", ExpectedOutput: tt.expected}
+			testCall, err := prepareDesignTestCall(example, design, "leetgoEqualExact(output, expected)")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			for _, fragment := range tt.contains {
+				if !strings.Contains(testCall, fragment) {
+					t.Errorf("Expected test call to contain %q, got:\n%s", fragment, testCall)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessCodeDesign(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	seed := `type LRUCache struct {
+
+}
+
+func Constructor(capacity int) LRUCache {
+
+}
+
+func (this *LRUCache) Get(key int) int {
+
+}
+
+func (this *LRUCache) Put(key int, value int)  {
+
+}`
+	code := `type LRUCache struct {
+	capacity int
+	keys     []int
+	values   map[int]int
+}
+
+func Constructor(capacity int) LRUCache {
+	return LRUCache{capacity: capacity, values: map[int]int{}}
+}
+
+func (this *LRUCache) touch(key int) {
+	for i, k := range this.keys {
+		if k == key {
+			this.keys = append(this.keys[:i], this.keys[i+1:]...)
+			break
+		}
+	}
+	this.keys = append(this.keys, key)
+}
+
+func (this *LRUCache) Get(key int) int {
+	value, ok := this.values[key]
+	if !ok {
+		return -1
+	}
+	this.touch(key)
+	return value
+}
+
+func (this *LRUCache) Put(key int, value int) {
+	if _, ok := this.values[key]; !ok && len(this.keys) == this.capacity {
+		delete(this.values, this.keys[0])
+		this.keys = this.keys[1:]
+	}
+	this.values[key] = value
+	this.touch(key)
+}`
+	input := `{"operations": ["LRUCache", "put", "put", "get", "put", "get", "put", "get", "get", "get"],
+		"arguments": [[2], [1, 1], [2, 2], [1], [3, 3], [2], [4, 4], [1], [3], [4]]}`
+
+	tests := []struct {
+		name     string
+		expected string
+		verdict  string
+		actual   string
+	}{
+		{
+			name:     "Correct sequence",
+			expected: `{"expected": [null, null, null, 1, null, -1, null, -1, 3, 4]}`,
+			verdict:  VerdictAccepted,
+		},
+		{
+			name:     "First mismatching call reported",
+			expected: `{"expected": [null, null, null, 1, null, 2, null, -1, 3, 4]}`,
+			verdict:  VerdictWrongAnswer,
+			actual:   "call 6 get(2): expected 2, got -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(CodeSubmission{
+				Code:        code,
+				Problem:     "LRUCache",
+				ProblemSeed: seed,
+				ProblemType: ProblemTypeDesign,
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: input, InputOrder: "[]", ExpectedOutput: tt.expected},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Verdict != tt.verdict {
+				t.Fatalf("Expected verdict %s, got %s: %+v", tt.verdict, output.Verdict, output)
+			}
+			if tt.actual != "" && output.Results[0].Actual != tt.actual {
+				t.Errorf("Expected actual %q, got %q", tt.actual, output.Results[0].Actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Structured verdicts describing the outcome of a submission
+const (
+	VerdictAccepted     = "ACCEPTED"
+	VerdictWrongAnswer  = "WRONG_ANSWER"
+	VerdictCompileError = "COMPILE_ERROR"
+	VerdictRuntimeError = "RUNTIME_ERROR"
+	VerdictTimeout      = "TIMEOUT"
+	VerdictMemoryLimit  = "MEMORY_LIMIT_EXCEEDED"
+	VerdictOutputLimit  = "OUTPUT_LIMIT_EXCEEDED"
+)
+
+var (
+	compileErrorPattern = regexp.MustCompile(`^\./([^:\s]+\.go):(\d+):(\d+): (.*)$`)
+	stackFramePattern   = regexp.MustCompile(`/([^/\s]+\.go):(\d+)`)
+)
+
+// userSource locates the user's code within the workspace file that contains it
+type userSource struct {
+	File      string // Workspace file holding the user's code
+	StartLine int    // Line of File on which the user's code begins
+	Lines     int    // Number of lines in the user's code
+}
+
+// Locate the user's code within the generated harness file
+func harnessSource(userCode string) userSource {
+	return userSource{File: harnessFile, StartLine: userCodeStartLine, Lines: strings.Count(userCode, "\n") + 1}
+}
+
+// Determine the verdict of a submission from its build and run results
+func determineVerdict(build, run RunResult, result string, source userSource) (string, []Diagnostic) {
+	switch {
+	case build.ExitErr != nil:
+		return VerdictCompileError, parseCompileErrors(build.Output, source)
+	case run.Exceeded == ResultTimeLimitExceeded:
+		return VerdictTimeout, nil
+	case run.Exceeded == ResultMemoryLimitExceeded:
+		return VerdictMemoryLimit, nil
+	case run.Exceeded == ResultOutputLimitExceeded:
+		return VerdictOutputLimit, nil
+	case run.ExitErr != nil:
+		return VerdictRuntimeError, parseRuntimeError(run.Output, source)
+	case result == "PASSED":
+		return VerdictAccepted, nil
+	}
+	return VerdictWrongAnswer, nil
+}
+
+// Extract compiler errors from go build output, mapping positions to the user's code.
+// Errors in other workspace files are kept without a position.
+func parseCompileErrors(output string, source userSource) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, outputLine := range strings.Split(output, "\n") {
+		match := compileErrorPattern.FindStringSubmatch(outputLine)
+		if match == nil {
+			// Continuation lines (e.g. "have"/"want" signatures) belong to the previous error
+			if strings.HasPrefix(outputLine, "\t") && len(diagnostics) > 0 {
+				diagnostics[len(diagnostics)-1].Message += "\n" + strings.TrimSpace(outputLine)
+			}
+			continue
+		}
+
+		fileLine, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		line := source.toUserLine(match[1], fileLine)
+		if line == 0 {
+			column = 0
+		}
+		diagnostics = append(diagnostics, Diagnostic{Line: line, Column: column, Message: match[4]})
+	}
+	return diagnostics
+}
+
+// Extract the panic message and the innermost user code frame from a crashed run
+func parseRuntimeError(output string, source userSource) []Diagnostic {
+	var message string
+	line := 0
+	for _, outputLine := range strings.Split(output, "\n") {
+		switch {
+		case message == "" && (strings.HasPrefix(outputLine, "panic: ") || strings.HasPrefix(outputLine, "fatal error: ")):
+			message = strings.TrimSpace(outputLine)
+		case message != "" && line == 0:
+			if match := stackFramePattern.FindStringSubmatch(outputLine); match != nil {
+				fileLine, _ := strconv.Atoi(match[2])
+				line = source.toUserLine(match[1], fileLine)
+			}
+		}
+	}
+	if message == "" {
+		return nil
+	}
+	return []Diagnostic{{Line: line, Message: message}}
+}
+
+// Translate a line of a workspace file into a line of the user's code, or 0 if outside it
+func (source userSource) toUserLine(file string, fileLine int) int {
+	line := fileLine - source.StartLine + 1
+	if file != source.File || line < 1 || line > source.Lines {
+		return 0
+	}
+	return line
+}
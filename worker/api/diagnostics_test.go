@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseCompileErrors(t *testing.T) {
+	output := fmt.Sprintf("# leetgo/submission\n"+
+		"./main.go:%d:3: undefined: x\n"+
+		"./main.go:%d:10: not enough arguments in call to Sum\n"+
+		"\thave (number)\n"+
+		"\twant (int, int)\n"+
+		"./checker.go:4:2: undefined: y\n", userCodeStartLine+1, userCodeStartLine+10)
+
+	expected := []Diagnostic{
+		{Line: 2, Column: 3, Message: "undefined: x"},
+		{Line: 0, Column: 0, Message: "not enough arguments in call to Sum\nhave (number)\nwant (int, int)"},
+		{Line: 0, Column: 0, Message: "undefined: y"},
+	}
+
+	diagnostics := parseCompileErrors(output, userSource{File: harnessFile, StartLine: userCodeStartLine, Lines: 3})
+	if !reflect.DeepEqual(expected, diagnostics) {
+		t.Errorf("Expected %+v, got %+v", expected, diagnostics)
+	}
+}
+
+func TestParseRuntimeError(t *testing.T) {
+	output := fmt.Sprintf("panic: runtime error: index out of range [3] with length 3\n\n"+
+		"goroutine 1 [running]:\n"+
+		"main.TwoSum(...)\n"+
+		"\t/tmp/leetgo-submission-1/main.go:%d\n"+
+		"main.main()\n"+
+		"\t/tmp/leetgo-submission-1/main.go:%d +0x1d\n", userCodeStartLine+2, userCodeStartLine+20)
+
+	expected := []Diagnostic{{Line: 3, Message: "panic: runtime error: index out of range [3] with length 3"}}
+
+	diagnostics := parseRuntimeError(output, userSource{File: harnessFile, StartLine: userCodeStartLine, Lines: 5})
+	if !reflect.DeepEqual(expected, diagnostics) {
+		t.Errorf("Expected %+v, got %+v", expected, diagnostics)
+	}
+}
+
+func TestProcessCodeVerdicts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	tests := []struct {
+		name        string
+		code        string
+		verdict     string
+		diagnostics []Diagnostic
+	}{
+		{
+			name:    "Accepted",
+			code:    "func Sum(x, y int) int {\n\treturn x + y\n}",
+			verdict: VerdictAccepted,
+		},
+		{
+			name:    "Wrong answer",
+			code:    "func Sum(x, y int) int {\n\treturn x - y\n}",
+			verdict: VerdictWrongAnswer,
+		},
+		{
+			name:        "Compile error",
+			code:        "func Sum(x, y int) int {\n\treturn x + z\n}",
+			verdict:     VerdictCompileError,
+			diagnostics: []Diagnostic{{Line: 2, Column: 13, Message: "undefined: z"}},
+		},
+		{
+			name:        "Runtime panic",
+			code:        "func Sum(x, y int) int {\n\tvar values []int\n\treturn values[x+y]\n}",
+			verdict:     VerdictRuntimeError,
+			diagnostics: []Diagnostic{{Line: 3, Message: "panic: runtime error: index out of range [3] with length 0"}},
+		},
+		{
+			name:    "Timeout",
+			code:    "func Sum(x, y int) int {\n\tfor {\n\t}\n}",
+			verdict: VerdictTimeout,
+		},
+		{
+			name:    "Memory limit",
+			code:    "func Sum(x, y int) int {\n\tvar chunks [][]byte\n\tfor {\n\t\tchunk := make([]byte, 1<<20)\n\t\tfor i := range chunk {\n\t\t\tchunk[i] = 1\n\t\t}\n\t\tchunks = append(chunks, chunk)\n\t}\n}",
+			verdict: VerdictMemoryLimit,
+		},
+		{
+			name:    "Output limit",
+			code:    "func Sum(x, y int) int {\n\tfor {\n\t\tfmt.Println(\"spam\")\n\t}\n}",
+			verdict: VerdictOutputLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := CodeSubmission{
+				Code:    tt.code,
+				Problem: "Sum",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+				},
+			}
+
+			output, err := processCode(submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Verdict != tt.verdict {
+				t.Errorf("Expected verdict %s, got %s: %s", tt.verdict, output.Verdict, output.Output)
+			}
+			if !reflect.DeepEqual(tt.diagnostics, output.Diagnostics) {
+				t.Errorf("Expected diagnostics %+v, got %+v", tt.diagnostics, output.Diagnostics)
+			}
+		})
+	}
+}
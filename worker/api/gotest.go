@@ -0,0 +1,365 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Workspace files used by go test problems
+const (
+	solutionFile    = "solution.go"
+	testFile        = "solution_test.go"
+	testHarnessFile = "harness.go"
+	testMainFile    = "harness_test.go"
+)
+
+// Package clause placed before the user's code in go test problems
+const solutionHeader = "package solution\n\n"
+
+// Harness reporting the exit code of the tests, signed the same way as the
+// results of other problems. The compiler sees this file before the
+// solution's, so the key is read before any of the solution's code runs.
+const testHarnessSource = `package solution
+
+import (
+	leetgoHex "encoding/hex"
+	leetgoJSON "encoding/json"
+	leetgoHMAC "crypto/hmac"
+	leetgoSHA256 "crypto/sha256"
+	leetgoIO "io"
+	leetgoOS "os"
+)
+
+var leetgoResults = leetgoOS.NewFile(3, "results")
+var leetgoKey = func() []byte {
+	keyFile := leetgoOS.NewFile(4, "key")
+	defer keyFile.Close()
+	key, _ := leetgoIO.ReadAll(keyFile)
+	return key
+}()
+
+func leetgoReport(exitCode int) {
+	line, _ := leetgoJSON.Marshal(leetgoSummary{ExitCode: exitCode})
+	mac := leetgoHMAC.New(leetgoSHA256.New, leetgoKey)
+	mac.Write(line)
+	leetgoResults.Write([]byte(leetgoHex.EncodeToString(mac.Sum(nil)) + " " + string(line) + "\n"))
+}
+
+type leetgoSummary struct {
+	ExitCode int
+}
+`
+
+// TestMain running every test, whatever flags the solution set to skip them,
+// and reporting the outcome through the harness
+const testMainSource = `package solution
+
+import (
+	leetgoFlag "flag"
+	leetgoOS "os"
+	leetgoTesting "testing"
+)
+
+func TestMain(m *leetgoTesting.M) {
+	leetgoFlag.Parse()
+	for name, value := range map[string]string{"test.run": "", "test.skip": "", "test.list": "", "test.count": "1"} {
+		leetgoFlag.Set(name, value)
+	}
+	exitCode := m.Run()
+	leetgoReport(exitCode)
+	leetgoOS.Exit(exitCode)
+}
+`
+
+// testSummary is the outcome of a go test run, as signed by the harness
+type testSummary struct {
+	ExitCode int
+}
+
+// Status of a skipped test, which is left out of the results
+const testSkipped = "SKIPPED"
+
+// testEvent is a single event of the test2json stream
+type testEvent struct {
+	Action  string
+	Test    string
+	Elapsed float64 // Seconds
+	Output  string
+}
+
+// Process a go test problem: the user's code is placed in a package next to the
+// problem's test file and graded by the tests the standard runner reports
+func processGoTest(submission CodeSubmission, report func(Progress)) (CodeOutput, error) {
+	if strings.TrimSpace(submission.TestFile) == "" {
+		return CodeOutput{}, invalidProblem(fmt.Errorf("problem type %q requires a test file", ProblemTypeGoTest))
+	}
+
+	expected, err := declaredTests(submission.TestFile)
+	if err != nil {
+		return CodeOutput{}, invalidProblem(err)
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return CodeOutput{}, err
+	}
+	hide := harnessNameHider("_" + suffix)
+	workDir, err := createWorkspace(map[string]string{
+		solutionFile:    solutionHeader + submission.Code,
+		testFile:        submission.TestFile,
+		testHarnessFile: hide.Replace(testHarnessSource),
+		testMainFile:    hide.Replace(testMainSource),
+	})
+	if err != nil {
+		return CodeOutput{}, err
+	}
+	defer os.RemoveAll(workDir) // Ensure the workspace is removed
+
+	// The tests are only counted once they have run
+	report(Progress{Stage: StageCompiling})
+	binary, build := buildTestWorkspace(workDir, ExecutionLimits)
+	if err := buildTimeoutError(build); err != nil {
+		return CodeOutput{}, err
+	}
+	run := build
+	var results []TestResult
+	passed := false
+	if build.ExitErr == nil {
+		report(Progress{Stage: StageRunning})
+		run = runSandboxed(workDir, binary, ExecutionLimits, nil, "-test.v=test2json")
+
+		events, err := convertTestOutput(run.Output)
+		if err != nil {
+			return CodeOutput{}, err
+		}
+		run.Output = testOutput(events)
+		results = collectGoTestResults(events, expected)
+
+		// The output is the solution's to forge, so the tests only passed if
+		// the runner also says so and the harness signed a zero exit code
+		summary, reported := readTestSummary(run.Results)
+		passed = packagePassed(events) && reported && summary.ExitCode == 0
+
+		// Failing tests exit with status 1, which isn't a crash. Exiting
+		// without the harness reporting is, whatever the status.
+		var exitErr *exec.ExitError
+		switch {
+		case !reported && run.ExitErr == nil:
+			run.ExitErr = errors.New("tests exited before reporting their outcome")
+		case reported && errors.As(run.ExitErr, &exitErr) && exitErr.ExitCode() == 1 && run.Exceeded == "":
+			run.ExitErr = nil
+		}
+	}
+	if run.ExitErr != nil {
+		log.Printf("Error executing tests: %v", run.ExitErr)
+	}
+
+	testCount := len(results)
+	testPassed := CountPassingTests(results)
+	result := "FAILED"
+	if testCount > 0 && testCount == testPassed && passed && run.ExitErr == nil {
+		result = "PASSED"
+	}
+	if run.Exceeded != "" {
+		result = run.Exceeded
+	}
+	source := userSource{
+		File:      solutionFile,
+		StartLine: strings.Count(solutionHeader, "\n") + 1,
+		Lines:     strings.Count(submission.Code, "\n") + 1,
+	}
+	verdict, diagnostics := determineVerdict(build, run, result, source)
+
+	response := CodeOutput{
+		TestCount:   testCount,
+		TestPassed:  testPassed,
+		Output:      run.Output,
+		Results:     results,
+		Result:      result,
+		Verdict:     verdict,
+		Diagnostics: diagnostics,
+	}
+
+	log.Printf("Response: %+v", response)
+	return response, nil
+}
+
+// Convert the output of a test binary run with -test.v=test2json into test events
+func convertTestOutput(output string) ([]testEvent, error) {
+	ctx, cancel := context.WithTimeout(executionCtx, ExecutionLimits.CompileTime)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", "tool", "test2json", "-t")
+	cmd.Stdin = strings.NewReader(output)
+	stream, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert test output: %w", err)
+	}
+
+	var events []testEvent
+	for _, line := range strings.Split(string(stream), "\n") {
+		var event testEvent
+		if err := json.Unmarshal([]byte(line), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Join the output of every event, without the test2json framing
+func testOutput(events []testEvent) string {
+	var output strings.Builder
+	for _, event := range events {
+		output.WriteString(event.Output)
+	}
+	return output.String()
+}
+
+// Return the names of the tests and fuzz tests the test file declares, each of
+// which must run for a submission to pass
+func declaredTests(source string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), testFile, source, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse test file: %w", err)
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		if fn.Name.Name == "TestMain" {
+			return nil, errors.New("test file can't declare TestMain, as the worker provides its own")
+		}
+		if isTestName(fn.Name.Name, "Test") || isTestName(fn.Name.Name, "Fuzz") {
+			names = append(names, fn.Name.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("test file declares no tests")
+	}
+	return names, nil
+}
+
+// Report whether name is a test function name with the given prefix, by the
+// rule go test uses: the prefix may not be followed by a lowercase letter
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return len(name) == len(prefix) || !unicode.IsLower(next)
+}
+
+// Return the outcome the harness signed, if it reported exactly one
+func readTestSummary(stream string) (testSummary, bool) {
+	var summaries []testSummary
+	for _, line := range strings.Split(stream, "\n") {
+		var summary testSummary
+		if err := json.Unmarshal([]byte(line), &summary); err == nil {
+			summaries = append(summaries, summary)
+		}
+	}
+	if len(summaries) != 1 {
+		return testSummary{}, false
+	}
+	return summaries[0], true
+}
+
+// Report whether the runner reported the package as passing
+func packagePassed(events []testEvent) bool {
+	for _, event := range events {
+		if event.Test == "" && event.Action == "pass" {
+			return true
+		}
+	}
+	return false
+}
+
+// Turn test events into per-test results for the expected tests, in the order
+// the tests started. Only leaf tests are reported, since a parent's outcome is
+// that of its subtests. Skipped tests are left out, tests that never finished
+// are marked NOT_RUN, and expected tests that never started are added as NOT_RUN.
+// Events for tests the test file doesn't declare were printed by the solution
+// and are ignored.
+func collectGoTestResults(events []testEvent, expected []string) []TestResult {
+	declared := make(map[string]bool)
+	for _, name := range expected {
+		declared[name] = true
+	}
+
+	var names []string
+	results := make(map[string]*TestResult)
+	for _, event := range events {
+		top, _, _ := strings.Cut(event.Test, "/")
+		if event.Test == "" || !declared[top] {
+			continue
+		}
+		result, ok := results[event.Test]
+		if !ok {
+			names = append(names, event.Test)
+			result = &TestResult{Status: TestNotRun, Input: event.Test}
+			results[event.Test] = result
+		}
+
+		switch event.Action {
+		case "output":
+			if line := strings.TrimSpace(event.Output); line != "" && !isTestFraming(line) {
+				result.Actual = strings.TrimPrefix(result.Actual+"\n"+line, "\n")
+			}
+		case "pass":
+			result.Status, result.DurationMs = TestPassed, event.Elapsed*1000
+		case "fail":
+			result.Status, result.DurationMs = TestFailed, event.Elapsed*1000
+		case "skip":
+			result.Status = testSkipped
+		}
+	}
+
+	var leaves []TestResult
+	for _, name := range names {
+		if results[name].Status == testSkipped || hasSubtests(name, names) {
+			continue
+		}
+		result := *results[name]
+		result.ID = len(leaves) + 1
+		leaves = append(leaves, result)
+	}
+	for _, name := range expected {
+		if _, started := results[name]; !started {
+			leaves = append(leaves, TestResult{ID: len(leaves) + 1, Status: TestNotRun, Input: name})
+		}
+	}
+	return leaves
+}
+
+// Report whether an output line is one of the runner's own progress lines
+func isTestFraming(line string) bool {
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether any of the named tests is a subtest of the given test
+func hasSubtests(name string, names []string) bool {
+	for _, other := range names {
+		if strings.HasPrefix(other, name+"/") {
+			return true
+		}
+	}
+	return false
+}
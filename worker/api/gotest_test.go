@@ -0,0 +1,262 @@
+package api
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectGoTestResults(t *testing.T) {
+	events := []testEvent{
+		{Action: "start"},
+		{Action: "run", Test: "TestSum"},
+		{Action: "output", Test: "TestSum", Output: "=== RUN   TestSum\n"},
+		{Action: "run", Test: "TestSum/positive"},
+		{Action: "output", Test: "TestSum/positive", Output: "=== RUN   TestSum/positive\n"},
+		{Action: "run", Test: "TestSum/negative"},
+		{Action: "output", Test: "TestSum/negative", Output: "    solution_test.go:12: Sum(-1, -2) = 3, want -3\n"},
+		{Action: "output", Test: "TestSum/negative", Output: "    --- FAIL: TestSum/negative (0.00s)\n"},
+		{Action: "fail", Test: "TestSum/negative", Elapsed: 0.002},
+		{Action: "pass", Test: "TestSum/positive", Elapsed: 0.001},
+		{Action: "fail", Test: "TestSum", Elapsed: 0.003},
+		{Action: "run", Test: "TestSkipped"},
+		{Action: "skip", Test: "TestSkipped"},
+		{Action: "run", Test: "TestHang"},
+		{Action: "run", Test: "TestForged"},
+		{Action: "pass", Test: "TestForged"},
+		{Action: "fail", Elapsed: 3},
+	}
+
+	expected := []TestResult{
+		{ID: 1, Status: TestPassed, Input: "TestSum/positive", DurationMs: 1},
+		{ID: 2, Status: TestFailed, Input: "TestSum/negative", Actual: "solution_test.go:12: Sum(-1, -2) = 3, want -3", DurationMs: 2},
+		{ID: 3, Status: TestNotRun, Input: "TestHang"},
+		{ID: 4, Status: TestNotRun, Input: "FuzzNeverStarted"},
+	}
+
+	results := collectGoTestResults(events, []string{"TestSum", "TestSkipped", "TestHang", "FuzzNeverStarted"})
+	if !reflect.DeepEqual(expected, results) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestDeclaredTests(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "Tests and fuzz tests",
+			source:   "package solution\n\nfunc TestSum(t *testing.T) {}\nfunc Test_edge(t *testing.T) {}\nfunc FuzzSum(f *testing.F) {}\nfunc BenchmarkSum(b *testing.B) {}\nfunc Testing() {}\nfunc helper() {}\n",
+			expected: []string{"TestSum", "Test_edge", "FuzzSum"},
+		},
+		{name: "No tests", source: "package solution\n\nfunc helper() {}\n", wantErr: true},
+		{name: "TestMain", source: "package solution\n\nfunc TestMain(m *testing.M) {}\nfunc TestSum(t *testing.T) {}\n", wantErr: true},
+		{name: "Syntax error", source: "package solution\n\nfunc TestSum(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := declaredTests(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestProcessCodeGoTest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	testFile := `package solution
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		name       string
+		x, y, want int
+	}{
+		{"positive", 1, 2, 3},
+		{"negative", -1, -2, -3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sum(tt.x, tt.y); got != tt.want {
+				t.Errorf("Sum(%d, %d) = %d, want %d", tt.x, tt.y, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzSum(f *testing.F) {
+	f.Add(4, 5)
+	f.Fuzz(func(t *testing.T, x, y int) {
+		if Sum(x, y) != Sum(y, x) {
+			t.Errorf("Sum(%d, %d) is not commutative", x, y)
+		}
+	})
+}
+
+func BenchmarkSum(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Sum(i, i)
+	}
+}
+`
+
+	tests := []struct {
+		name       string
+		code       string
+		verdict    string
+		testCount  int
+		testPassed int
+		line       int
+	}{
+		{
+			name:       "Passing solution",
+			code:       "func Sum(x, y int) int {\n\treturn x + y\n}",
+			verdict:    VerdictAccepted,
+			testCount:  3,
+			testPassed: 3,
+		},
+		{
+			name:       "Failing solution",
+			code:       "func Sum(x, y int) int {\n\tif x < 0 {\n\t\treturn -x + y\n\t}\n\treturn x + y\n}",
+			verdict:    VerdictWrongAnswer,
+			testCount:  3,
+			testPassed: 2,
+		},
+		{
+			name:    "Compile error",
+			code:    "func Sum(x, y int) int {\n\treturn x + z\n}",
+			verdict: VerdictCompileError,
+			line:    2,
+		},
+		{
+			name:       "Panicking solution",
+			code:       "func Sum(x, y int) int {\n\tvar values []int\n\treturn values[x] + y\n}",
+			verdict:    VerdictRuntimeError,
+			testCount:  2,
+			testPassed: 0,
+			line:       3,
+		},
+		{
+			name:       "Exiting before the tests run",
+			code:       "import \"os\"\n\nfunc init() {\n\tos.Exit(0)\n}\n\nfunc Sum(x, y int) int {\n\treturn x + y\n}",
+			verdict:    VerdictRuntimeError,
+			testCount:  2,
+			testPassed: 0,
+		},
+		{
+			name:       "Printing passing tests and exiting",
+			code:       "import (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc init() {\n\tfmt.Print(\"=== RUN   TestSum\\n--- PASS: TestSum (0.00s)\\n=== RUN   FuzzSum\\n--- PASS: FuzzSum (0.00s)\\nPASS\\n\")\n\tos.Exit(0)\n}\n\nfunc Sum(x, y int) int {\n\treturn 0\n}",
+			verdict:    VerdictRuntimeError,
+			testCount:  2,
+			testPassed: 2,
+		},
+		{
+			name:       "Skipping the tests",
+			code:       "import \"os\"\n\nfunc init() {\n\tos.Args = append(os.Args, \"-test.run=^$\")\n}\n\nfunc Sum(x, y int) int {\n\treturn 0\n}",
+			verdict:    VerdictWrongAnswer,
+			testCount:  3,
+			testPassed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(CodeSubmission{
+				Code:        tt.code,
+				Problem:     "Sum",
+				ProblemType: ProblemTypeGoTest,
+				TestFile:    testFile,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Verdict != tt.verdict {
+				t.Fatalf("Expected verdict %s, got %s: %+v", tt.verdict, output.Verdict, output)
+			}
+			if output.TestCount != tt.testCount || output.TestPassed != tt.testPassed {
+				t.Errorf("Expected %d/%d tests passed, got %d/%d: %+v", tt.testPassed, tt.testCount, output.TestPassed, output.TestCount, output.Results)
+			}
+			if tt.line != 0 && (len(output.Diagnostics) == 0 || output.Diagnostics[0].Line != tt.line) {
+				t.Errorf("Expected diagnostic on line %d, got %+v", tt.line, output.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestProcessCodeGoTestSandbox(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	// Test code may import anything, so it is held to the same sandbox as
+	// function submissions
+	if os.Geteuid() == 0 {
+		SandboxUID = 60000
+		defer func() { SandboxUID = 0 }()
+	} else if err := ProtectProcess(); err != nil {
+		t.Fatalf("Failed to protect the worker's process: %v", err)
+	}
+	// Long enough that waiting on a child until the deadline would show
+	originalLimits := ExecutionLimits
+	ExecutionLimits.WallTime = 30 * time.Second
+	defer func() { ExecutionLimits = originalLimits }()
+
+	testFile := `package solution
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if got := Sum(1, 2); got != 3 {
+		t.Errorf("Sum(1, 2) = %d, want 3", got)
+	}
+}
+`
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{
+			name: "Leaving a child running",
+			code: "import \"os\"\n\nfunc Sum(x, y int) int {\n\tfiles := []*os.File{nil, nil, nil, os.NewFile(3, \"results\"), os.NewFile(4, \"key\")}\n\tos.StartProcess(\"/bin/sleep\", []string{\"sleep\", \"60\"}, &os.ProcAttr{Files: files})\n\treturn x + y\n}",
+		},
+		{
+			name: "Reading the worker's environment",
+			code: "import (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc Sum(x, y int) int {\n\tif _, err := os.ReadFile(fmt.Sprintf(\"/proc/%d/environ\", os.Getppid())); err == nil {\n\t\treturn 0\n\t}\n\treturn x + y\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			output, err := processCode(CodeSubmission{
+				Code:        tt.code,
+				Problem:     "Sum",
+				ProblemType: ProblemTypeGoTest,
+				TestFile:    testFile,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Verdict != VerdictAccepted {
+				t.Errorf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("Expected the submission to finish once its tests did, took %s", elapsed)
+			}
+		})
+	}
+}
@@ -2,128 +2,483 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// Module definition written alongside every submission
+const workspaceModule = "module leetgo/submission\n\ngo 1.22\n"
+
+// Content type of a response streaming the submission's progress, sent when
+// the request accepts it
+const streamContentType = "application/x-ndjson"
+
+// Name of the generated file holding the harness and the user's solution
+const harnessFile = "main.go"
+
+// Line taking the place of the output of hidden examples, when they printed
+// anything. It is withheld so a solution can't print hidden inputs back to the user.
+const hiddenOutputMarker = "leetgo: output of hidden examples withheld"
+
+// Report whether the worker can grade submissions, which needs the go command
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := exec.LookPath("go"); err != nil {
+		http.Error(w, `{"status":"go command not found"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
 // Handler for processing code submissions
 func ProcessCodeHandler(w http.ResponseWriter, r *http.Request) {
 	var submission CodeSubmission
 	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+		respondWithError(w, &WorkerError{Code: ErrorInvalidRequest, Message: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	inFlight.Add(1)
+	defer inFlight.Done()
+
+	if r.Header.Get("Accept") == streamContentType {
+		streamProcessCode(w, submission)
 		return
 	}
 
 	codeResponse, err := processCode(submission)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+	if err := gradingError(err); err != nil {
+		respondWithError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(codeResponse); err != nil {
-		http.Error(w, `{"error":"Failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Failed to send response: %v", err)
+	}
+}
+
+// Grade a submission, writing each change in its progress and then its result
+// as a line of JSON
+func streamProcessCode(w http.ResponseWriter, submission CodeSubmission) {
+	w.Header().Set("Content-Type", streamContentType)
+	encoder := json.NewEncoder(w)
+	var mu sync.Mutex
+	send := func(event StreamEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(event)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	codeResponse, err := processCodeWithProgress(submission, func(progress Progress) {
+		send(StreamEvent{Progress: &progress})
+	})
+	if err := gradingError(err); err != nil {
+		send(StreamEvent{Error: err})
+		return
+	}
+	send(StreamEvent{Result: &codeResponse})
+}
+
+// Turn the outcome of grading a submission into the error to respond with, if
+// it failed. A submission killed part way by shutdown failed even without an
+// error, as its result can't be trusted.
+func gradingError(err error) *WorkerError {
+	if executionCtx.Err() != nil {
+		return &WorkerError{Code: ErrorShuttingDown, Message: "Worker is shutting down", Retryable: true}
+	}
+	if err == nil {
+		return nil
+	}
+	var workerErr *WorkerError
+	if errors.As(err, &workerErr) {
+		return workerErr
+	}
+	return &WorkerError{Code: ErrorInternal, Message: err.Error(), Retryable: true}
+}
+
+// Mark an error as caused by the problem's data, which fails the same way however often it is sent
+func invalidProblem(err error) *WorkerError {
+	return &WorkerError{Code: ErrorInvalidProblem, Message: err.Error()}
+}
+
+// Status of the response to a submission that failed with the given error code
+func errorStatus(code string) int {
+	switch code {
+	case ErrorInvalidRequest:
+		return http.StatusBadRequest
+	case ErrorUnauthorized:
+		return http.StatusUnauthorized
+	case ErrorInvalidProblem:
+		return http.StatusUnprocessableEntity
+	case ErrorShuttingDown:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Send an error as JSON, with the status its code calls for
+func respondWithError(w http.ResponseWriter, err *WorkerError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorStatus(err.Code))
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: err}); err != nil {
+		log.Printf("Failed to send response: %v", err)
 	}
 }
 
 // Process the code submission
 func processCode(submission CodeSubmission) (CodeOutput, error) {
+	return processCodeWithProgress(submission, func(Progress) {})
+}
+
+// Process the code submission, reporting when it starts compiling, starts
+// running and finishes each test
+func processCodeWithProgress(submission CodeSubmission, report func(Progress)) (CodeOutput, error) {
 	log.Printf("Retrieved problem examples: %+v", submission.ProblemExamples)
 
-	var testCalls []string
-	for _, example := range submission.ProblemExamples {
-		formattedArgs, err := prepareTestCall(example, submission.Problem)
-		if err != nil {
-			return CodeOutput{}, fmt.Errorf("failed to prepare test call for example ID %d: %w", example.ID, err)
-		}
-		testCalls = append(testCalls, formattedArgs)
+	if submission.ProblemType == ProblemTypeGoTest {
+		return processGoTest(submission, report)
 	}
 
-	harnessCode := generateTestHarness(submission.Code, strings.Join(testCalls, "\n"))
-
-	codeFile := "temp_code.go"
-	if err := os.WriteFile(codeFile, []byte(harnessCode), 0644); err != nil {
-		return CodeOutput{}, fmt.Errorf("failed to save code to file: %w", err)
+	comparison, err := comparisonExpr(submission)
+	if err != nil {
+		return CodeOutput{}, invalidProblem(err)
 	}
-	defer os.Remove(codeFile) // Ensure the temp file is removed
 
-	testReturn, err := exec.Command("go", "run", codeFile).CombinedOutput()
+	testCalls, err := prepareTestCalls(submission, comparison)
 	if err != nil {
-		log.Printf("Error executing test harness: %v", err)
+		return CodeOutput{}, invalidProblem(err)
+	}
+	var visibleCalls, hiddenCalls []string
+	for i, example := range submission.ProblemExamples {
+		if example.Hidden {
+			hiddenCalls = append(hiddenCalls, testCalls[i])
+		} else {
+			visibleCalls = append(visibleCalls, testCalls[i])
+		}
 	}
 
-	output := string(testReturn)
 	testCount := len(submission.ProblemExamples)
-	testPassed := CountPassingTests(output)
+	completed := 0
+	onResult := func() {
+		if completed < testCount {
+			completed++
+			report(Progress{Stage: StageRunning, Completed: completed, Total: testCount})
+		}
+	}
+
+	report(Progress{Stage: StageCompiling, Total: testCount})
+	build, run, err := runHarness(submission, visibleCalls, func() {
+		report(Progress{Stage: StageRunning, Total: testCount})
+	}, onResult)
+	if err != nil {
+		return CodeOutput{}, err
+	}
+
+	// Hidden examples are built into a binary of their own, once the visible
+	// ones ran cleanly, so the solution can't read their inputs from its own
+	// binary and print them. Nothing of their output is returned, and
+	// diagnostics are only read from what is, so a crash on a hidden example
+	// can't reveal its input in a panic message either.
+	output := run.Output
+	if len(hiddenCalls) > 0 && build.ExitErr == nil && run.ExitErr == nil && run.Exceeded == "" {
+		hiddenBuild, hiddenRun, err := runHarness(submission, hiddenCalls, func() {}, onResult)
+		if err != nil {
+			return CodeOutput{}, err
+		}
+		if hiddenBuild.ExitErr != nil {
+			build = RunResult{ExitErr: hiddenBuild.ExitErr}
+		}
+		if strings.TrimSpace(hiddenBuild.Output+hiddenRun.Output) != "" {
+			output += hiddenOutputMarker + "\n"
+		}
+		hiddenRun.Results = run.Results + hiddenRun.Results
+		run = hiddenRun
+	}
+	run.Output = output
+	if run.ExitErr != nil {
+		log.Printf("Error executing test harness: %v", run.ExitErr)
+	}
+
+	results := collectTestResults(run.Results, submission.ProblemExamples)
+	testPassed := CountPassingTests(results)
 	result := "FAILED"
-	if testCount == testPassed {
+	if testCount == testPassed && run.ExitErr == nil {
 		result = "PASSED"
 	}
+	if run.Exceeded != "" {
+		result = run.Exceeded
+	}
+	verdict, diagnostics := determineVerdict(build, run, result, harnessSource(submission.Code))
 
 	response := CodeOutput{
-		TestCount:  testCount,
-		TestPassed: testPassed,
-		Output:     output,
-		Result:     result,
+		TestCount:   testCount,
+		TestPassed:  testPassed,
+		Output:      output,
+		Results:     results,
+		Result:      result,
+		Verdict:     verdict,
+		Diagnostics: diagnostics,
 	}
 
 	log.Printf("Response: %+v", response)
 	return response, nil
 }
 
-// Prepare the test call for the given example
-func prepareTestCall(example ProblemExample, problemName string) (string, error) {
-	var inputOrder []string
-	if err := json.Unmarshal([]byte(example.InputOrder), &inputOrder); err != nil {
-		return "", fmt.Errorf("failed to unmarshal input order: %w", err)
+// Prepare the test calls for every example, according to the problem type
+func prepareTestCalls(submission CodeSubmission, comparison string) ([]string, error) {
+	var testCalls []string
+	switch submission.ProblemType {
+	case "", ProblemTypeFunction:
+		// Without a seed, argument types are inferred from the JSON values
+		var sig *Signature
+		if submission.ProblemSeed != "" {
+			var err error
+			if sig, err = ParseSignature(submission.ProblemSeed, submission.Problem); err != nil {
+				return nil, err
+			}
+		}
+		for _, example := range submission.ProblemExamples {
+			testCall, err := prepareTestCall(example, submission.Problem, comparison, sig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare test call for example ID %d: %w", example.ID, err)
+			}
+			testCalls = append(testCalls, testCall)
+		}
+	case ProblemTypeDesign:
+		design, err := ParseDesign(submission.ProblemSeed)
+		if err != nil {
+			return nil, err
+		}
+		for _, example := range submission.ProblemExamples {
+			testCall, err := prepareDesignTestCall(example, design, comparison)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare test call for example ID %d: %w", example.ID, err)
+			}
+			testCalls = append(testCalls, testCall)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported problem type: %s", submission.ProblemType)
 	}
+	return testCalls, nil
+}
 
-	formattedArgs, err := FormatArgs(example.Input, inputOrder)
+// Build the solution with the given test calls in a workspace of its own, and
+// run it if it built, calling onRun as it starts. The harness's names are
+// hidden behind a suffix of the run's own. The returned error is only set when
+// the submission can't be graded.
+func runHarness(submission CodeSubmission, testCalls []string, onRun, onResult func()) (RunResult, RunResult, error) {
+	suffix, err := randomHex(8)
 	if err != nil {
-		return "", fmt.Errorf("failed to format arguments: %w", err)
+		return RunResult{}, RunResult{}, err
 	}
+	harnessCode := generateTestHarness(submission.Code, strings.Join(testCalls, "\n"), generateStructureHelpers(submission), "_"+suffix)
 
-	expectedOutput, err := FormatExpectedOutput(example.ExpectedOutput)
+	// Each run gets its own module so concurrent runs never share files
+	files := map[string]string{harnessFile: harnessCode}
+	if checker := generateCheckerFile(submission); checker != "" {
+		files[checkerFile] = checker
+	}
+	workDir, err := createWorkspace(files)
 	if err != nil {
-		return "", fmt.Errorf("failed to format expected output: %w", err)
+		return RunResult{}, RunResult{}, err
 	}
+	defer os.RemoveAll(workDir) // Ensure the workspace is removed
 
-	return fmt.Sprintf(`
-		output%d := %s(%s)
-		expected%d := %s
-		if fmt.Sprint(output%d) == fmt.Sprint(expected%d) {
-			results = append(results, Result{%d, "PASSED", ""})
-		} else {
-			results = append(results, Result{%d, "FAILED", fmt.Sprint(output%d)})
+	binary, build := buildWorkspace(workDir, ExecutionLimits)
+	if err := buildTimeoutError(build); err != nil {
+		return build, RunResult{}, err
+	}
+	if build.ExitErr != nil {
+		return build, build, nil
+	}
+	onRun()
+	return build, runSandboxed(workDir, binary, ExecutionLimits, onResult), nil
+}
+
+// Create a temporary module containing the given source files and return its path
+func createWorkspace(files map[string]string) (string, error) {
+	workDir, err := os.MkdirTemp("", "leetgo-submission-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(workDir, "go.mod"), []byte(workspaceModule), 0644); err != nil {
+		os.RemoveAll(workDir)
+		return "", fmt.Errorf("failed to save go.mod to workspace: %w", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644); err != nil {
+			os.RemoveAll(workDir)
+			return "", fmt.Errorf("failed to save %s to workspace: %w", name, err)
 		}
-	`, example.ID, problemName, formattedArgs, example.ID, expectedOutput, example.ID, example.ID, example.ID, example.ID, example.ID), nil
+	}
+
+	return workDir, nil
 }
 
-// Generate the test harness code
-func generateTestHarness(userCode, testCalls string) string {
+// Prepare the test call for the given example. Arguments and the expected value
+// are typed from the problem signature when one is available.
+func prepareTestCall(example ProblemExample, problemName, comparison string, sig *Signature) (string, error) {
+	var inputOrder []string
+	if err := json.Unmarshal([]byte(example.InputOrder), &inputOrder); err != nil {
+		return "", fmt.Errorf("failed to unmarshal input order: %w", err)
+	}
+
+	var formattedArgs, expectedDecl string
+	if sig != nil {
+		var err error
+		if formattedArgs, err = FormatTypedArgs(example.Input, inputOrder, sig); err != nil {
+			return "", fmt.Errorf("failed to format arguments: %w", err)
+		}
+		if expectedDecl, err = FormatTypedExpectedOutput(example.ExpectedOutput, sig); err != nil {
+			return "", fmt.Errorf("failed to format expected output: %w", err)
+		}
+	} else {
+		var err error
+		if formattedArgs, err = FormatArgs(example.Input, inputOrder); err != nil {
+			return "", fmt.Errorf("failed to format arguments: %w", err)
+		}
+		expectedOutput, err := FormatExpectedOutput(example.ExpectedOutput)
+		if err != nil {
+			return "", fmt.Errorf("failed to format expected output: %w", err)
+		}
+		expectedDecl = "expected := " + expectedOutput
+	}
+
 	return fmt.Sprintf(`
+		func() {
+			start := leetgoTime.Now()
+			output := %s(%s)
+			duration := leetgoTime.Since(start)
+			%s
+			result := leetgoResult{ID: %d, Status: "FAILED", Actual: leetgoFormat(output), DurationMs: float64(duration.Microseconds()) / 1000}
+			if %s {
+				result.Status = "PASSED"
+			}
+			leetgoReport(result)
+		}()
+	`, problemName, formattedArgs, expectedDecl, example.ID, comparison), nil
+}
+
+// Harness code placed before the user's solution. Results are written as JSON
+// lines to file descriptor 3 so that anything the solution prints to stdout
+// can't be mistaken for a test result. Each line is signed with a key read from
+// file descriptor 4 before any of the solution's code runs, so the solution
+// can't report results of its own.
+const harnessHeader = `
 		package main
 		import (
 			"fmt"
+			leetgoHex "encoding/hex"
+			leetgoJSON "encoding/json"
+			leetgoHMAC "crypto/hmac"
+			leetgoSHA256 "crypto/sha256"
+			leetgoIO "io"
+			leetgoMath "math"
+			leetgoOS "os"
+			leetgoReflect "reflect"
+			leetgoSort "sort"
+			leetgoStrings "strings"
+			leetgoTime "time"
 		)
-		type Result struct {
-			Test   int
-			Result string
-			Output string
+		type leetgoResult struct {
+			ID         int
+			Status     string
+			Actual     string
+			DurationMs float64
 		}
+		var leetgoResults = leetgoOS.NewFile(3, "results")
+		var leetgoKey = func() []byte {
+			keyFile := leetgoOS.NewFile(4, "key")
+			defer keyFile.Close()
+			key, _ := leetgoIO.ReadAll(keyFile)
+			return key
+		}()
+		func leetgoReport(result leetgoResult) {
+			line, _ := leetgoJSON.Marshal(result)
+			mac := leetgoHMAC.New(leetgoSHA256.New, leetgoKey)
+			mac.Write(line)
+			leetgoResults.Write([]byte(leetgoHex.EncodeToString(mac.Sum(nil)) + " " + string(line) + "\n"))
+		}
+
+`
 
-		%s
+// Harness identifiers given a suffix unique to each run, so the solution can't
+// name them to report results, read the key, change how outputs are compared
+// or declare methods changing how results are encoded. The imports are among
+// them, leaving the solution only fmt, so it can't read files such as its own
+// binary. A name comes before any other it is a prefix of.
+var hiddenHarnessNames = []string{
+	"leetgoResults", "leetgoResult", "leetgoKey", "leetgoReport", "leetgoSummary",
+	"leetgoFormatStructure", "leetgoFormatList", "leetgoFormatTree", "leetgoFormat",
+	"leetgoBuildList", "leetgoBuildTree", "leetgoSortedElements", "leetgoToFloat",
+	"leetgoEqualExact", "leetgoEqualUnordered", "leetgoEqualSet", "leetgoEqualFloat",
+	"leetgoHex", "leetgoJSON", "leetgoHMAC", "leetgoSHA256", "leetgoIO", "leetgoMath",
+	"leetgoOS", "leetgoReflect", "leetgoSort", "leetgoStrings", "leetgoTime",
+}
+
+// Line of the generated file on which the user's solution begins
+var userCodeStartLine = strings.Count(harnessHeader, "\n") + 1
+
+// Return a replacer giving every hidden harness identifier the suffix
+func harnessNameHider(suffix string) *strings.Replacer {
+	var replacements []string
+	for _, name := range hiddenHarnessNames {
+		replacements = append(replacements, name, name+suffix)
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// Generate the test harness code, with the given helpers for the problem's
+// structures, hiding the harness's own identifiers behind names ending in suffix
+func generateTestHarness(userCode, testCalls, helpers, suffix string) string {
+	hide := harnessNameHider(suffix)
+	return hide.Replace(harnessHeader) + userCode + fmt.Sprintf(`
 
 		func main() {
-			var results []Result
 			%s
-			for _, result := range results {
-				fmt.Printf("Test %%d: %%s, Output: %%s\n", result.Test, result.Result, result.Output)
-			}
 		}
-	`, userCode, testCalls)
+	`, hide.Replace(testCalls)) + hide.Replace(compareHelpers+helpers)
+}
+
+// Combine the results reported by the harness with the submitted examples.
+// Examples the harness never reported (e.g. after a crash) are marked NOT_RUN.
+// The harness reports each example once, so one reported again was tampered
+// with and fails.
+func collectTestResults(stream string, examples []ProblemExample) []TestResult {
+	reported := make(map[int]TestResult)
+	for _, line := range strings.Split(stream, "\n") {
+		var result TestResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			continue
+		}
+		if _, seen := reported[result.ID]; seen {
+			result = TestResult{ID: result.ID, Status: TestFailed, Actual: "result reported more than once"}
+		}
+		reported[result.ID] = result
+	}
+
+	results := make([]TestResult, 0, len(examples))
+	for _, example := range examples {
+		result, ok := reported[example.ID]
+		if !ok {
+			result = TestResult{ID: example.ID, Status: TestNotRun}
+		}
+		result.Input = example.Input
+		result.Expected = example.ExpectedOutput
+		results = append(results, result)
+	}
+	return results
 }
@@ -1,7 +1,15 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -31,7 +39,7 @@ func TestGenerateTestHarness(t *testing.T) {
 			})`,
 			expected: []string{
 				"package main",
-				"type Result struct {",
+				"type leetgoResult_test struct {",
 				"func add(a, b int) int {",
 				"results = append(results, Result{",
 				"fmt.Sprint(add(2, 3))",
@@ -42,7 +50,7 @@ func TestGenerateTestHarness(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := generateTestHarness(tt.userCode, tt.testCalls)
+			result := generateTestHarness(tt.userCode, tt.testCalls, "", "_test")
 
 			// Ensure the generated code contains the expected strings
 			for _, expectedFragment := range tt.expected {
@@ -58,3 +66,524 @@ func TestGenerateTestHarness(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessCodeConcurrentSubmissions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	const submissions = 12
+
+	type outcome struct {
+		id     int
+		output CodeOutput
+		err    error
+	}
+
+	results := make(chan outcome, submissions)
+	var wg sync.WaitGroup
+	for i := 1; i <= submissions; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			// Every submission returns its own ID, so a shared harness would grade the wrong answer
+			submission := CodeSubmission{
+				Code:    fmt.Sprintf("func Answer() int {\n\treturn %d\n}", id),
+				Problem: "Answer",
+				ProblemExamples: []ProblemExample{
+					{ID: id, Input: `{}`, InputOrder: `[]`, ExpectedOutput: fmt.Sprintf(`{"result": %d}`, id)},
+				},
+			}
+			output, err := processCode(submission)
+			results <- outcome{id, output, err}
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		if r.err != nil {
+			t.Errorf("Submission %d returned error: %v", r.id, r.err)
+			continue
+		}
+		if r.output.Result != "PASSED" {
+			t.Errorf("Submission %d expected PASSED, got %s: %s", r.id, r.output.Result, r.output.Output)
+		}
+		if len(r.output.Results) != 1 || r.output.Results[0].ID != r.id || r.output.Results[0].Actual != fmt.Sprint(r.id) {
+			t.Errorf("Submission %d results belong to another submission: %+v", r.id, r.output.Results)
+		}
+	}
+}
+
+func TestProcessCodeIgnoresPrintedResults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	submission := CodeSubmission{
+		Code: `func Sum(x, y int) int {
+	fmt.Println("Test 1: PASSED, Output: 3")
+	fmt.Println("Test 2: PASSED, Output: 3")
+	return 0
+}`,
+		Problem: "Sum",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+			{ID: 2, Input: `{"x": 2, "y": 1}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+		},
+	}
+
+	output, err := processCode(submission)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.TestPassed != 0 || output.Result != "FAILED" {
+		t.Errorf("Expected printed lines to be ignored, got %d passing with result %s", output.TestPassed, output.Result)
+	}
+	if !strings.Contains(output.Output, "Test 1: PASSED") {
+		t.Errorf("Expected user output to be kept separately, got %q", output.Output)
+	}
+	for _, result := range output.Results {
+		if result.Status != TestFailed || result.Actual != "0" || result.Expected != `{"result": 3}` {
+			t.Errorf("Unexpected test result: %+v", result)
+		}
+	}
+}
+
+func TestProcessCodeIgnoresForgedResults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	forge := `func init() {
+	results := leetgoOS.NewFile(3, "results")
+	for id := 1; id <= 2; id++ {
+		fmt.Fprintf(results, "{\"ID\":%d,\"Status\":\"PASSED\"}\n", id)
+		fmt.Fprintf(results, "%x {\"ID\":%d,\"Status\":\"PASSED\"}\n", make([]byte, 32), id)
+	}
+	%s
+}
+
+func Sum(x, y int) int {
+	return 0
+}`
+	// Solutions are only given fmt and can't name the harness's identifiers,
+	// so each of these fails to compile, and the results wouldn't be signed if
+	// they did
+	tests := []struct {
+		name    string
+		code    string
+		verdict string
+	}{
+		{"BeforeTheTests", fmt.Sprintf(forge, ""), VerdictCompileError},
+		{"InsteadOfTheTests", fmt.Sprintf(forge, "leetgoOS.Exit(0)"), VerdictCompileError},
+		{"ThroughTheImports", "import \"os\"\n\n" + strings.ReplaceAll(fmt.Sprintf(forge, ""), "leetgoOS", "os"), VerdictCompileError},
+		{"ThroughTheHarness", "func init() {\n\tleetgoReport(Result{ID: 1, Status: \"PASSED\"})\n}\n\nfunc Sum(x, y int) int {\n\treturn 0\n}", VerdictCompileError},
+		{"ThroughTheResultEncoding", "var reported int\n\nfunc (r Result) MarshalJSON() ([]byte, error) {\n\treported++\n\treturn []byte(fmt.Sprintf(`{\"ID\":%d,\"Status\":\"PASSED\"}`, reported)), nil\n}\n\nfunc Sum(x, y int) int {\n\treturn 0\n}", VerdictCompileError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(CodeSubmission{
+				Code:    tt.code,
+				Problem: "Sum",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+					{ID: 2, Input: `{"x": 2, "y": 1}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.TestPassed != 0 || output.Result != "FAILED" {
+				t.Errorf("Expected forged results to be ignored, got %d passing with result %s: %+v", output.TestPassed, output.Result, output)
+			}
+			if output.Verdict != tt.verdict {
+				t.Errorf("Expected verdict %s, got %s: %+v", tt.verdict, output.Verdict, output)
+			}
+		})
+	}
+}
+
+func TestProcessCodeIgnoresReplacedComparisons(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	// Each declaration would take the place of the harness's own if its names
+	// weren't hidden, making every output match
+	output, err := processCode(CodeSubmission{
+		Code: `var leetgoFormatters []func(value any) (string, bool)
+
+func init() {
+	leetgoFormatters = append(leetgoFormatters, func(any) (string, bool) { return "", true })
+}
+
+func leetgoFormat(value any) string {
+	return ""
+}
+
+func leetgoFormatStructure(value any) (string, bool) {
+	return "", true
+}
+
+func leetgoEqualExact(output, expected any) bool {
+	return true
+}
+
+func Sum(x, y int) int {
+	return 0
+}`,
+		Problem: "Sum",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+			{ID: 2, Input: `{"x": 2, "y": 1}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`, Hidden: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictWrongAnswer || output.TestPassed != 0 {
+		t.Errorf("Expected verdict %s with no tests passing, got %s with %d: %+v", VerdictWrongAnswer, output.Verdict, output.TestPassed, output)
+	}
+}
+
+func TestResultVerifier(t *testing.T) {
+	key := []byte("key")
+	line := `{"ID":1,"Status":"PASSED"}`
+	var out strings.Builder
+	verifier := &resultVerifier{key: key, out: &out}
+
+	// Lines may arrive in pieces
+	signed := signResult(key, []byte(line)) + " " + line + "\n"
+	verifier.Write([]byte(signed[:10]))
+	verifier.Write([]byte(signed[10:] + line + "\n" + signResult([]byte("guess"), []byte(line)) + " " + line + "\n"))
+
+	if out.String() != line+"\n" || verifier.rejected != 2 {
+		t.Errorf("Expected only the signed line to pass, got %q with %d rejected", out.String(), verifier.rejected)
+	}
+}
+
+func TestProcessCodeWithholdsHiddenOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	submission := CodeSubmission{
+		Code: `func Sum(x, y int) int {
+	fmt.Println("called with", x, y)
+	return x + y
+}`,
+		Problem: "Sum",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+			{ID: 2, Input: `{"x": 40, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 42}`, Hidden: true},
+		},
+	}
+
+	output, err := processCode(submission)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictAccepted {
+		t.Fatalf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+	}
+	if !strings.Contains(output.Output, "called with 1 2") {
+		t.Errorf("Expected output of visible examples, got %q", output.Output)
+	}
+	if strings.Contains(output.Output, "called with 40 2") {
+		t.Errorf("Expected output of hidden examples to be withheld, got %q", output.Output)
+	}
+}
+
+func TestProcessCodeCantReadSources(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	examples := []ProblemExample{
+		{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+		{ID: 2, Input: `{"x": 40, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 42}`, Hidden: true},
+	}
+	tests := []struct {
+		name       string
+		submission CodeSubmission
+	}{
+		{"Function", CodeSubmission{
+			Code:            "func Sum(x, y int) int {\n\treturn x + y\n}",
+			Problem:         "Sum",
+			ProblemExamples: examples,
+			CompareMode:     CompareChecker,
+			// Solutions can't import os, but the checker can
+			Checker: `import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	entries, _ := os.ReadDir(".")
+	fmt.Println("files:", len(entries))
+	source, err := os.ReadFile("main.go")
+	fmt.Println(strings.ReplaceAll(string(source), "withheld", ""), err)
+}
+
+func Check(expected, actual int) bool {
+	return expected == actual
+}`,
+		}},
+		{"GoTest", CodeSubmission{
+			Code: `import (
+	"fmt"
+	"os"
+)
+
+func Sum(x, y int) int {
+	source, err := os.ReadFile("solution_test.go")
+	fmt.Println(string(source), err)
+	return x + y
+}`,
+			Problem:     "Sum",
+			ProblemType: ProblemTypeGoTest,
+			TestFile:    "package solution\n\nimport \"testing\"\n\n// secret: 40 + 2\nfunc TestSum(t *testing.T) {\n\tif Sum(40, 2) != 42 {\n\t\tt.Fail()\n\t}\n}\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(tt.submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if strings.Contains(output.Output, "40") {
+				t.Errorf("Expected the sources to be unreadable, got %q", output.Output)
+			}
+			if !strings.Contains(output.Output, "no such file or directory") {
+				t.Errorf("Expected reading the sources to fail, got %q", output.Output)
+			}
+		})
+	}
+}
+
+func TestProcessCodeWithholdsHiddenCrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	output, err := processCode(CodeSubmission{
+		Code: `func Sum(x, y int) int {
+	if x == 40 {
+		panic(fmt.Sprint("input ", x, " ", y))
+	}
+	return x + y
+}`,
+		Problem: "Sum",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"x": 1, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 3}`},
+			{ID: 2, Input: `{"x": 40, "y": 2}`, InputOrder: `["x", "y"]`, ExpectedOutput: `{"result": 42}`, Hidden: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictRuntimeError {
+		t.Errorf("Expected verdict %s, got %s", VerdictRuntimeError, output.Verdict)
+	}
+	if details := fmt.Sprintf("%+v", output); strings.Contains(details, "input 40") {
+		t.Errorf("Expected the hidden input to be withheld, got %s", details)
+	}
+}
+
+func TestProcessCodeKeepsHiddenExamplesOutOfTheBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	// Solutions can't import os, so the checker, which can, searches the
+	// binary for the hidden input. Only the visible run's output is returned.
+	output, err := processCode(CodeSubmission{
+		Code:        "func Echo(s string) string {\n\treturn s\n}",
+		Problem:     "Echo",
+		CompareMode: CompareChecker,
+		Checker: `import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	binary, err := os.ReadFile(os.Args[0])
+	fmt.Println("read binary:", err == nil, "holds hidden input:", bytes.Contains(binary, []byte(strings.ToLower("HIDDEN-NEEDLE"))))
+}
+
+func Check(expected, actual string) bool {
+	return expected == actual
+}`,
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"s": "visible"}`, InputOrder: `["s"]`, ExpectedOutput: `{"result": "visible"}`},
+			{ID: 2, Input: `{"s": "hidden-needle"}`, InputOrder: `["s"]`, ExpectedOutput: `{"result": "hidden-needle"}`, Hidden: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictAccepted {
+		t.Fatalf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+	}
+	if !strings.Contains(output.Output, "read binary: true holds hidden input: false") {
+		t.Errorf("Expected the visible run's binary to leave out the hidden input, got %q", output.Output)
+	}
+	if !strings.Contains(output.Output, hiddenOutputMarker) || strings.Count(output.Output, "read binary") != 1 {
+		t.Errorf("Expected the hidden run's output to be withheld, got %q", output.Output)
+	}
+}
+
+func TestCollectTestResults(t *testing.T) {
+	examples := []ProblemExample{
+		{ID: 4, Input: `{"x": 1}`, ExpectedOutput: `{"result": 1}`},
+		{ID: 5, Input: `{"x": 2}`, ExpectedOutput: `{"result": 2}`},
+	}
+	stream := `{"ID":4,"Status":"FAILED","Actual":"7","DurationMs":0.5}
+not json
+{"ID":4,"Status":"PASSED","Actual":"1","DurationMs":0.1}
+`
+
+	results := collectTestResults(stream, examples)
+
+	expected := []TestResult{
+		{ID: 4, Status: TestFailed, Input: `{"x": 1}`, Expected: `{"result": 1}`, Actual: "result reported more than once"},
+		{ID: 5, Status: TestNotRun, Input: `{"x": 2}`, Expected: `{"result": 2}`},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], results[i])
+		}
+	}
+}
+
+func TestProcessCodeHandlerStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	body := `{"code": "func Sum(x, y int) int {\n\treturn x + y\n}", "problem": "Sum", "problem_examples": [` +
+		`{"id": 1, "input": "{\"x\": 1, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"result\": 3}"},` +
+		`{"id": 2, "input": "{\"x\": 2, \"y\": 2}", "input_order": "[\"x\", \"y\"]", "expected_output": "{\"result\": 4}"}]}`
+	request := httptest.NewRequest(http.MethodPost, "/process-code", strings.NewReader(body))
+	request.Header.Set("Accept", streamContentType)
+	recorder := httptest.NewRecorder()
+	ProcessCodeHandler(recorder, request)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != streamContentType {
+		t.Fatalf("Expected content type %q, got %q", streamContentType, contentType)
+	}
+	var progress []Progress
+	var result *CodeOutput
+	decoder := json.NewDecoder(recorder.Body)
+	for decoder.More() {
+		var event StreamEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("Invalid stream event: %v", err)
+		}
+		if event.Progress != nil {
+			progress = append(progress, *event.Progress)
+		}
+		if event.Result != nil {
+			result = event.Result
+		}
+	}
+
+	expected := []Progress{
+		{Stage: StageCompiling, Total: 2},
+		{Stage: StageRunning, Total: 2},
+		{Stage: StageRunning, Completed: 1, Total: 2},
+		{Stage: StageRunning, Completed: 2, Total: 2},
+	}
+	if !reflect.DeepEqual(progress, expected) {
+		t.Errorf("Expected progress %+v, got %+v", expected, progress)
+	}
+	if result == nil || result.Result != "PASSED" || result.TestPassed != 2 {
+		t.Errorf("Expected the submission to pass, got %+v", result)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Healthy", os.Getenv("PATH"), http.StatusOK, `{"status":"ok"}`},
+		{"NoGoCommand", t.TempDir(), http.StatusServiceUnavailable, `{"status":"go command not found"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", tt.path)
+			recorder := httptest.NewRecorder()
+			HealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if recorder.Code != tt.expectedCode || recorder.Body.String() != tt.expectedBody {
+				t.Errorf("Expected %d %q, got %d %q", tt.expectedCode, tt.expectedBody, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestProcessCodeHandlerErrors(t *testing.T) {
+	badProblem := `{"code": "func Sum() int { return 0 }", "problem": "Sum", "problem_type": "say \"hi\""}`
+	tests := []struct {
+		name         string
+		body         string
+		stream       bool
+		shutdown     bool
+		expectedCode int
+		expected     WorkerError
+	}{
+		{"InvalidRequest", `{"code": `, false, false, http.StatusBadRequest,
+			WorkerError{Code: ErrorInvalidRequest, Message: "Invalid request body: unexpected EOF"}},
+		{"InvalidProblem", badProblem, false, false, http.StatusUnprocessableEntity,
+			WorkerError{Code: ErrorInvalidProblem, Message: `unsupported problem type: say "hi"`}},
+		{"InvalidProblemStreamed", badProblem, true, false, http.StatusOK,
+			WorkerError{Code: ErrorInvalidProblem, Message: `unsupported problem type: say "hi"`}},
+		{"ShuttingDown", badProblem, false, true, http.StatusServiceUnavailable,
+			WorkerError{Code: ErrorShuttingDown, Message: "Worker is shutting down", Retryable: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.shutdown {
+				cancelExecutions()
+				defer func() { executionCtx, cancelExecutions = context.WithCancel(context.Background()) }()
+			}
+			request := httptest.NewRequest(http.MethodPost, "/process-code", strings.NewReader(tt.body))
+			if tt.stream {
+				request.Header.Set("Accept", streamContentType)
+			}
+			recorder := httptest.NewRecorder()
+			ProcessCodeHandler(recorder, request)
+
+			if recorder.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, recorder.Code)
+			}
+			var response ErrorResponse
+			if tt.stream {
+				var event StreamEvent
+				if err := json.NewDecoder(recorder.Body).Decode(&event); err != nil {
+					t.Fatalf("Invalid stream event: %v", err)
+				}
+				response.Error = event.Error
+			} else if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Invalid error response %q: %v", recorder.Body.String(), err)
+			}
+			if response.Error == nil || *response.Error != tt.expected {
+				t.Errorf("Expected error %+v, got %+v", tt.expected, response.Error)
+			}
+		})
+	}
+}
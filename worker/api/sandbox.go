@@ -0,0 +1,449 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Results reported when a submission exceeds one of its limits
+const (
+	ResultTimeLimitExceeded   = "TIME_LIMIT_EXCEEDED"
+	ResultMemoryLimitExceeded = "MEMORY_LIMIT_EXCEEDED"
+	ResultOutputLimitExceeded = "OUTPUT_LIMIT_EXCEEDED"
+)
+
+// Limits bounds the resources a single submission may use
+type Limits struct {
+	CompileTime time.Duration // Wall-clock time allowed for go build
+	WallTime    time.Duration // Wall-clock time allowed for the program run
+	CPUTime     time.Duration // CPU time allowed for the program run (RLIMIT_CPU)
+	MemoryBytes int64         // Peak resident memory allowed for the program run, with its data segment capped at twice this (RLIMIT_DATA)
+	OutputBytes int           // Combined stdout/stderr captured before the run is killed
+	Processes   int           // Processes and threads allowed to the run's sandbox user, when it has one (RLIMIT_NPROC)
+}
+
+// Limits applied to every submission
+var ExecutionLimits = Limits{
+	CompileTime: 6 * time.Second,
+	WallTime:    3 * time.Second,
+	CPUTime:     2 * time.Second,
+	MemoryBytes: 256 << 20,
+	OutputBytes: 64 << 10,
+	Processes:   64,
+}
+
+// Context of every compile and run, canceled by KillExecutions to stop them
+var executionCtx, cancelExecutions = context.WithCancel(context.Background())
+
+// Submissions being processed by ProcessCodeHandler
+var inFlight sync.WaitGroup
+
+// Kill the processes of every submission still running at shutdown, then wait
+// for their handlers to respond and remove their workspaces
+func KillExecutions() {
+	cancelExecutions()
+	inFlight.Wait()
+}
+
+// First of the user IDs runs go under, one for each execution slot, or 0 to
+// run them as the worker's own user. Replaced by main from the config.
+var SandboxUID = 0
+
+// Compiles and runs using the machine at once, numbered from 0. Each keeps a
+// CPU busy, so more would only slow every one of them until they ran out of time.
+var executionSlots = func() chan int {
+	slots := make(chan int, runtime.NumCPU())
+	for i := 0; i < cap(slots); i++ {
+		slots <- i
+	}
+	return slots
+}()
+
+// Wait for a free execution slot, returning its number and the function that
+// frees it, or false if the worker is shutting down
+func acquireSlot() (int, func(), bool) {
+	select {
+	case slot := <-executionSlots:
+		return slot, func() { executionSlots <- slot }, true
+	case <-executionCtx.Done():
+		return 0, nil, false
+	}
+}
+
+// Size of the test result stream accepted from a single run
+const maxResultBytes = 1 << 20
+
+// RunResult describes how a sandboxed process finished
+type RunResult struct {
+	Output     string
+	Results    string // JSON lines reported by the harness
+	ExitErr    error
+	Exceeded   string        // One of the Result*LimitExceeded values, or empty
+	CPUTime    time.Duration // User and system time the process used
+	PeakMemory int64         // Peak resident memory of the process in bytes, where the platform reports it
+}
+
+// Build the workspace into a binary, bounded by the compile time limit
+func buildWorkspace(workDir string, limits Limits) (string, RunResult) {
+	return compileWorkspace(workDir, limits, "build", "-o", "submission", ".")
+}
+
+// Build the workspace's tests into a test binary, bounded by the compile time limit
+func buildTestWorkspace(workDir string, limits Limits) (string, RunResult) {
+	return compileWorkspace(workDir, limits, "test", "-c", "-vet=off", "-o", "submission", ".")
+}
+
+// Run the go command to produce ./submission in the workspace
+func compileWorkspace(workDir string, limits Limits, args ...string) (string, RunResult) {
+	_, release, ok := acquireSlot()
+	if !ok {
+		return "./submission", RunResult{ExitErr: executionCtx.Err()}
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(executionCtx, limits.CompileTime)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = workDir
+	result := runCommand(ctx, cancel, cmd, limits.OutputBytes)
+	if result.Exceeded == ResultTimeLimitExceeded {
+		result.Output = "compilation timed out"
+	}
+	return "./submission", result
+}
+
+// Turn a build that ran out of time into an error. Builds are slow when the
+// worker is overloaded rather than because of the submission, which may be
+// sent again.
+func buildTimeoutError(build RunResult) error {
+	if build.Exceeded != ResultTimeLimitExceeded {
+		return nil
+	}
+	return &WorkerError{Code: ErrorInternal, Message: "compilation timed out", Retryable: true}
+}
+
+// Run a compiled binary under the wall-clock, CPU, memory and output limits.
+// onResult, if not nil, is called as each test result is reported.
+func runSandboxed(workDir, binary string, limits Limits, onResult func(), args ...string) RunResult {
+	// The sources hold every example, hidden ones included, so they are
+	// removed before the program can read them
+	if err := removeSources(workDir, binary); err != nil {
+		return RunResult{ExitErr: err}
+	}
+
+	slot, release, ok := acquireSlot()
+	if !ok {
+		return RunResult{ExitErr: executionCtx.Err()}
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(executionCtx, limits.WallTime)
+	defer cancel()
+
+	// Apply rlimits in a shell that then replaces itself with the program. The
+	// data segment may grow past the memory limit so that going over it can be
+	// measured, rather than failing an allocation the way a crash would.
+	script := fmt.Sprintf(`ulimit -t %d; ulimit -d %d; `, cpuSeconds(limits.CPUTime), 2*limits.MemoryBytes/1024)
+	// RLIMIT_NPROC counts every process and thread of the user, so it only
+	// bounds the run when the user is the run's alone
+	uid := 0
+	if SandboxUID != 0 {
+		uid = SandboxUID + slot
+		// dash, the /bin/sh of Debian, names the limit -p
+		if limits.Processes > 0 {
+			script += fmt.Sprintf(`ulimit -u %[1]d 2>/dev/null || ulimit -p %[1]d; `, limits.Processes)
+		}
+	}
+	script += `exec "$0" "$@"`
+	cmd := exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, binary}, args...)...)
+	cmd.Dir = workDir
+
+	// Each slot's runs go under a user of their own, who can't read the
+	// worker's files or /proc entries, nor signal the other slots' runs
+	if uid != 0 {
+		for _, path := range []string{workDir, filepath.Join(workDir, binary)} {
+			if err := os.Chown(path, uid, uid); err != nil {
+				return RunResult{ExitErr: fmt.Errorf("failed to hand the workspace to the sandbox user: %w", err)}
+			}
+		}
+		runAs(cmd, uid)
+	}
+
+	// The harness reports test results on file descriptor 3, signed with a key
+	// it reads from file descriptor 4
+	key, err := randomHex(32)
+	if err != nil {
+		return RunResult{ExitErr: fmt.Errorf("failed to create results key: %w", err)}
+	}
+	resultsReader, resultsWriter, err := os.Pipe()
+	if err != nil {
+		return RunResult{ExitErr: fmt.Errorf("failed to create results pipe: %w", err)}
+	}
+	defer resultsReader.Close()
+	keyReader, keyWriter, err := os.Pipe()
+	if err != nil {
+		resultsWriter.Close()
+		return RunResult{ExitErr: fmt.Errorf("failed to create key pipe: %w", err)}
+	}
+	defer keyReader.Close()
+	keyWriter.WriteString(key)
+	keyWriter.Close()
+	cmd.ExtraFiles = []*os.File{resultsWriter, keyReader}
+
+	// The size limit counts every byte written, signed or not
+	received := &limitedBuffer{max: maxResultBytes, onExceed: cancel}
+	results := &bytes.Buffer{}
+	verifier := &resultVerifier{key: []byte(key), out: results}
+	if onResult != nil {
+		verifier.out = io.MultiWriter(results, lineCounter(onResult))
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(received, verifier), resultsReader)
+		close(done)
+	}()
+
+	result := runCommand(ctx, cancel, cmd, limits.OutputBytes)
+	resultsWriter.Close()
+
+	// A process that escaped being killed may still hold the results pipe
+	// open, so reading stops a second after the run's deadline
+	deadline, _ := ctx.Deadline()
+	timer := time.NewTimer(time.Until(deadline) + time.Second)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		resultsReader.Close()
+		<-done
+	}
+
+	result.Results = results.String()
+	if verifier.rejected > 0 {
+		log.Printf("Dropped %d test results the harness didn't sign", verifier.rejected)
+	}
+	if received.Exceeded() && result.Exceeded == "" {
+		result.Exceeded = ResultOutputLimitExceeded
+	}
+
+	result.Exceeded = classifyRun(result, limits)
+	return result
+}
+
+// Remove everything in the workspace but the compiled binary
+func removeSources(workDir, binary string) error {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return fmt.Errorf("failed to read workspace: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == filepath.Base(binary) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(workDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s from workspace: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Variables of the worker's environment that compiles and runs see. Nothing
+// else is passed on, since the environment holds the secret the server signs
+// submissions with.
+var sandboxEnvNames = []string{"PATH", "HOME", "GOCACHE", "GOPATH", "GOFLAGS"}
+
+// Return the environment compiles and runs are given
+func sandboxEnv() []string {
+	var env []string
+	for _, name := range sandboxEnvNames {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// Run a command in its own process group, killing the whole group on timeout
+// or excess output, and once the command exits
+func runCommand(ctx context.Context, cancel context.CancelFunc, cmd *exec.Cmd, maxOutput int) RunResult {
+	cmd.Env = sandboxEnv()
+	setProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	// Output goes through a pipe of the caller's rather than one exec.Cmd
+	// waits on, so that the process group can be killed as soon as the
+	// command exits, rather than once its children close their copies
+	output := &limitedBuffer{max: maxOutput, onExceed: cancel}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		return RunResult{ExitErr: fmt.Errorf("failed to create output pipe: %w", err)}
+	}
+	defer outputReader.Close()
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(output, outputReader)
+		close(copied)
+	}()
+
+	err = cmd.Run()
+	outputWriter.Close()
+	// Children left running would hold on to the slot's CPU and pipes
+	killChildren(cmd)
+
+	// A process that escaped being killed may still hold the pipe open
+	timer := time.NewTimer(cmd.WaitDelay)
+	defer timer.Stop()
+	select {
+	case <-copied:
+	case <-timer.C:
+		outputReader.Close()
+		<-copied
+	}
+
+	result := RunResult{Output: output.String(), ExitErr: err}
+	if cmd.ProcessState != nil {
+		result.CPUTime = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		result.PeakMemory = peakMemory(cmd.ProcessState)
+	}
+	switch {
+	case output.Exceeded():
+		result.Exceeded = ResultOutputLimitExceeded
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Exceeded = ResultTimeLimitExceeded
+	}
+	return result
+}
+
+// CPU time a run may fall short of its limit by and still have exceeded it, as
+// the usage the kernel reports can be a few milliseconds under what RLIMIT_CPU counted
+const cpuTimeSlack = 50 * time.Millisecond
+
+// Map a run to the limit it exceeded, judged by the resources the kernel
+// reports it used rather than anything it printed, which the submission controls
+func classifyRun(result RunResult, limits Limits) string {
+	switch {
+	case result.PeakMemory > limits.MemoryBytes:
+		// The runtime's crash dump may also trip the output limit, so memory takes precedence
+		return ResultMemoryLimitExceeded
+	case result.Exceeded != "":
+		return result.Exceeded
+	case result.CPUTime >= limits.CPUTime-cpuTimeSlack:
+		return ResultTimeLimitExceeded
+	}
+	return ""
+}
+
+// Round a CPU limit up to whole seconds, as required by RLIMIT_CPU
+func cpuSeconds(d time.Duration) int64 {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// limitedBuffer captures output up to max bytes and calls onExceed once when more arrives
+type limitedBuffer struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	max      int
+	exceeded bool
+	onExceed func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if remaining := b.max - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		if !b.exceeded {
+			b.exceeded = true
+			b.onExceed()
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *limitedBuffer) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+// Return n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign a test result line with the key of the run reporting it
+func signResult(key, line []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(line)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resultVerifier passes on the JSON of every result line the harness signed,
+// given as "<signature> <json>", and drops lines written by anything else
+type resultVerifier struct {
+	key      []byte
+	out      io.Writer
+	partial  []byte // Start of a line whose end hasn't been written yet
+	rejected int
+}
+
+func (v *resultVerifier) Write(p []byte) (int, error) {
+	v.partial = append(v.partial, p...)
+	for {
+		end := bytes.IndexByte(v.partial, '\n')
+		if end < 0 {
+			break
+		}
+		signature, line, _ := bytes.Cut(v.partial[:end], []byte(" "))
+		if hmac.Equal(signature, []byte(signResult(v.key, line))) {
+			v.out.Write(append(line, '\n'))
+		} else {
+			v.rejected++
+		}
+		v.partial = v.partial[end+1:]
+	}
+	return len(p), nil
+}
+
+// lineCounter is called once for every line written to it
+type lineCounter func()
+
+func (onLine lineCounter) Write(p []byte) (int, error) {
+	for i := bytes.Count(p, []byte("\n")); i > 0; i-- {
+		onLine()
+	}
+	return len(p), nil
+}
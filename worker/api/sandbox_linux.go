@@ -0,0 +1,12 @@
+package api
+
+import "syscall"
+
+// Make the worker's /proc entries, its environment among them, readable only
+// by root. Runs sharing the worker's user could otherwise read the secret there.
+func ProtectProcess() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package api
+
+// Other platforms have no /proc entries exposing the worker's environment
+func ProtectProcess() error {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package api
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process groups are unavailable, so only the direct child is killed on cancel
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// Only the direct child was started, and it has exited
+func killChildren(cmd *exec.Cmd) {}
+
+// Users can't be switched on this platform, so the command runs as the worker's
+func runAs(cmd *exec.Cmd, uid int) {}
+
+// Memory use isn't reported on this platform
+func peakMemory(state *os.ProcessState) int64 {
+	return 0
+}
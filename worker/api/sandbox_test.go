@@ -0,0 +1,374 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessCodeLimits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	originalLimits := ExecutionLimits
+	ExecutionLimits = Limits{
+		CompileTime: 30 * time.Second,
+		WallTime:    2 * time.Second,
+		CPUTime:     time.Second,
+		MemoryBytes: 256 << 20,
+		OutputBytes: 4 << 10,
+	}
+	defer func() { ExecutionLimits = originalLimits }()
+
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name: "Passing solution",
+			code: `func Answer() int {
+				return 1
+			}`,
+			expected: "PASSED",
+		},
+		{
+			name: "Infinite loop",
+			code: `func Answer() int {
+				for {
+				}
+			}`,
+			expected: ResultTimeLimitExceeded,
+		},
+		{
+			name: "Memory exhaustion",
+			code: `func Answer() int {
+				var chunks [][]byte
+				for {
+					chunk := make([]byte, 1<<20)
+					for i := range chunk {
+						chunk[i] = 1
+					}
+					chunks = append(chunks, chunk)
+				}
+			}`,
+			expected: ResultMemoryLimitExceeded,
+		},
+		{
+			name: "Printed out of memory message",
+			code: `func Answer() int {
+				fmt.Println("fatal error: runtime: out of memory")
+				panic("cannot allocate memory")
+			}`,
+			expected: "FAILED",
+		},
+		{
+			name: "Excessive output",
+			code: `func Answer() int {
+				for {
+					fmt.Println("spam")
+				}
+			}`,
+			expected: ResultOutputLimitExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := CodeSubmission{
+				Code:    tt.code,
+				Problem: "Answer",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": 1}`},
+				},
+			}
+
+			start := time.Now()
+			output, err := processCode(submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Result != tt.expected {
+				t.Errorf("Expected result %s, got %s: %s", tt.expected, output.Result, output.Output)
+			}
+			if len(output.Output) > ExecutionLimits.OutputBytes {
+				t.Errorf("Captured output exceeds limit: %d bytes", len(output.Output))
+			}
+			if elapsed := time.Since(start); elapsed > 20*time.Second {
+				t.Errorf("Submission took %v, limits were not enforced", elapsed)
+			}
+		})
+	}
+}
+
+func TestProcessCodeBuildTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	originalLimits := ExecutionLimits
+	ExecutionLimits.CompileTime = time.Millisecond
+	defer func() { ExecutionLimits = originalLimits }()
+
+	_, err := processCode(CodeSubmission{
+		Code:    "func Answer() int {\n\treturn 1\n}",
+		Problem: "Answer",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{}`, InputOrder: `[]`, ExpectedOutput: `{"result": 1}`},
+		},
+	})
+	var workerErr *WorkerError
+	if !errors.As(err, &workerErr) || workerErr.Code != ErrorInternal || !workerErr.Retryable {
+		t.Errorf("Expected a retryable %s error, got %v", ErrorInternal, err)
+	}
+}
+
+func TestProcessCodeHidesEnvironment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+	t.Setenv("WORKER_SECRET", "s3cret")
+
+	// Only go-test solutions may import os
+	output, err := processCode(CodeSubmission{
+		Code:        "import (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc Sum(x, y int) int {\n\tfmt.Println(\"secret:\", os.Getenv(\"WORKER_SECRET\"))\n\treturn x + y\n}",
+		Problem:     "Sum",
+		ProblemType: ProblemTypeGoTest,
+		TestFile:    "package solution\n\nimport \"testing\"\n\nfunc TestSum(t *testing.T) {\n\tif Sum(1, 2) != 3 {\n\t\tt.Fail()\n\t}\n}\n",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictAccepted {
+		t.Fatalf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+	}
+	if !strings.Contains(output.Output, "secret:") || strings.Contains(output.Output, "s3cret") {
+		t.Errorf("Expected the secret to be hidden from the submission, got output %q", output.Output)
+	}
+}
+
+func TestRunSandboxedProtectsWorker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	// Root reads every /proc entry, so runs are only kept out of the worker's
+	// by a user of their own. Other users rely on the worker being non-dumpable.
+	if os.Geteuid() == 0 {
+		SandboxUID = 60000
+		defer func() { SandboxUID = 0 }()
+	} else if err := ProtectProcess(); err != nil {
+		t.Fatalf("Failed to protect the worker's process: %v", err)
+	}
+
+	secretDir := t.TempDir()
+	os.Chmod(secretDir, 0755)
+	secretFile := filepath.Join(secretDir, "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	workDir, err := createWorkspace(map[string]string{"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	_, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", os.Getppid()))
+	fmt.Println("environ:", err)
+	_, err = os.ReadFile(os.Args[1])
+	fmt.Println("secret file:", err)
+}
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workDir)
+	binary, build := buildWorkspace(workDir, ExecutionLimits)
+	if build.ExitErr != nil {
+		t.Fatalf("Failed to build: %v: %s", build.ExitErr, build.Output)
+	}
+
+	result := runSandboxed(workDir, binary, ExecutionLimits, nil, secretFile)
+	if result.ExitErr != nil {
+		t.Fatalf("Unexpected error: %v: %s", result.ExitErr, result.Output)
+	}
+	if strings.Contains(result.Output, "environ: <nil>") {
+		t.Errorf("Expected the worker's environment to be unreadable, got %q", result.Output)
+	}
+	if SandboxUID != 0 && strings.Contains(result.Output, "secret file: <nil>") {
+		t.Errorf("Expected the secret file to be unreadable, got %q", result.Output)
+	}
+}
+
+func TestRunSandboxedKillsChildren(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	// The child holds the results pipe open, which the run must not wait on.
+	// One leaving the process group is only killed with the sandbox user.
+	workDir, err := createWorkspace(map[string]string{"main.go": `package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func main() {
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr, os.NewFile(3, "results")}
+	attr := &syscall.SysProcAttr{Setsid: len(os.Args) > 1}
+	if _, err := os.StartProcess("/bin/sleep", []string{"sleep", "60"}, &os.ProcAttr{Files: files, Sys: attr}); err != nil {
+		panic(err)
+	}
+}
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workDir)
+	binary, build := buildWorkspace(workDir, ExecutionLimits)
+	if build.ExitErr != nil {
+		t.Fatalf("Failed to build: %v: %s", build.ExitErr, build.Output)
+	}
+
+	tests := []struct {
+		name       string
+		sandboxUID int
+		args       []string
+	}{
+		{"ProcessGroup", 0, nil},
+		{"SandboxUser", 60000, []string{"setsid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sandboxUID != 0 && os.Geteuid() != 0 {
+				t.Skip("switching users requires root")
+			}
+			SandboxUID = tt.sandboxUID
+			defer func() { SandboxUID = 0 }()
+
+			start := time.Now()
+			result := runSandboxed(workDir, binary, ExecutionLimits, nil, tt.args...)
+			if result.ExitErr != nil {
+				t.Fatalf("Unexpected error: %v: %s", result.ExitErr, result.Output)
+			}
+			if elapsed := time.Since(start); elapsed > ExecutionLimits.WallTime {
+				t.Errorf("Expected the run to end once the program exited, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestKillExecutions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	originalLimits := ExecutionLimits
+	ExecutionLimits.WallTime = time.Minute
+	ExecutionLimits.CPUTime = time.Minute
+	defer func() {
+		ExecutionLimits = originalLimits
+		executionCtx, cancelExecutions = context.WithCancel(context.Background())
+	}()
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	body := `{"code": "func Answer() int {\n\tfor {\n\t}\n}", "problem": "Answer", "problem_examples": [{"id": 1, "input": "{}", "input_order": "[]", "expected_output": "{\"result\": 1}"}]}`
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		ProcessCodeHandler(recorder, httptest.NewRequest(http.MethodPost, "/process-code", strings.NewReader(body)))
+		close(done)
+	}()
+
+	// Wait for the submission to be compiled and running
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		binaries, _ := filepath.Glob(filepath.Join(tempDir, "leetgo-submission-*", "submission"))
+		if len(binaries) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Submission was not compiled in time")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	start := time.Now()
+	KillExecutions()
+	<-done
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the submission to be killed promptly, took %s", elapsed)
+	}
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusServiceUnavailable, recorder.Code, recorder.Body.String())
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Expected the workspace to be removed, found %v", entries)
+	}
+}
+
+func TestClassifyRun(t *testing.T) {
+	limits := Limits{CPUTime: time.Second, MemoryBytes: 64 << 20}
+	tests := []struct {
+		name     string
+		result   RunResult
+		expected string
+	}{
+		{"WithinLimits", RunResult{CPUTime: 900 * time.Millisecond, PeakMemory: 64 << 20}, ""},
+		{"CPUTime", RunResult{CPUTime: 990 * time.Millisecond, ExitErr: errors.New("signal: killed")}, ResultTimeLimitExceeded},
+		{"Memory", RunResult{PeakMemory: 65 << 20, Exceeded: ResultOutputLimitExceeded}, ResultMemoryLimitExceeded},
+		{"Output", RunResult{Exceeded: ResultOutputLimitExceeded, CPUTime: 2 * time.Second}, ResultOutputLimitExceeded},
+		{"PrintedMessage", RunResult{Output: "fatal error: runtime: out of memory\n", ExitErr: errors.New("exit status 2")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if exceeded := classifyRun(tt.result, limits); exceeded != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, exceeded)
+			}
+		})
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	calls := 0
+	buf := &limitedBuffer{max: 5, onExceed: func() { calls++ }}
+
+	buf.Write([]byte("abc"))
+	buf.Write([]byte("defgh"))
+	buf.Write([]byte("ijk"))
+
+	if got := buf.String(); got != "abcde" {
+		t.Errorf("Expected buffer to hold %q, got %q", "abcde", got)
+	}
+	if !buf.Exceeded() || calls != 1 {
+		t.Errorf("Expected a single exceed callback, got exceeded=%v calls=%d", buf.Exceeded(), calls)
+	}
+	if !strings.HasPrefix(buf.String(), "abc") {
+		t.Errorf("Expected buffer to keep earliest output")
+	}
+}
+
+func TestLineCounter(t *testing.T) {
+	lines := 0
+	counter := lineCounter(func() { lines++ })
+
+	counter.Write([]byte("{\"ID\":1}\n{\"ID\""))
+	counter.Write([]byte(":2}\n"))
+
+	if lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", lines)
+	}
+}
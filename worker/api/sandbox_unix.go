@@ -0,0 +1,61 @@
+//go:build unix
+
+package api
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// Start the command in a new process group and kill the entire group on cancel
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// Kill whatever is left of the command's process group after it has exited,
+// and every process of the user it ran as, if it was switched to one
+func killChildren(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
+		killUser(int(cmd.SysProcAttr.Credential.Uid))
+	}
+}
+
+// Kill every process of a sandbox user, including any that left the run's
+// process group. The user's processes may only be signaled as that user.
+func killUser(uid int) {
+	cmd := exec.Command("/bin/sh", "-c", "kill -s KILL -- -1")
+	runAs(cmd, uid)
+	cmd.Run()
+}
+
+// Start the command as the given user and group, without supplementary groups
+func runAs(cmd *exec.Cmd, uid int) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(uid)}
+}
+
+// Return the peak resident memory of a finished process in bytes
+func peakMemory(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOS reports bytes, and other systems kilobytes
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
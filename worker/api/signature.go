@@ -0,0 +1,387 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Signature describes the parameters and result of a problem function, as
+// declared in the problem seed
+type Signature struct {
+	Params []Param
+	Result ast.Expr // nil when the function returns nothing
+	types  map[string]ast.Expr
+}
+
+// Param is a single named function parameter
+type Param struct {
+	Name     string
+	Type     ast.Expr
+	Variadic bool
+}
+
+// Parse the problem seed and return the signature of the named function
+func ParseSignature(seed, funcName string) (*Signature, error) {
+	file, declared, err := parseSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == funcName {
+			return signatureOf(fn, declared)
+		}
+	}
+	return nil, fmt.Errorf("function %s not found in problem seed", funcName)
+}
+
+// Parse a problem seed, returning the file and the types it declares
+func parseSeed(seed string) (*ast.File, map[string]ast.Expr, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "seed.go", "package main\n"+seed, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse problem seed: %w", err)
+	}
+
+	declared := make(map[string]ast.Expr)
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range genDecl.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+					declared[typeSpec.Name.Name] = typeSpec.Type
+				}
+			}
+		}
+	}
+	return file, declared, nil
+}
+
+// Build the signature of a function declaration
+func signatureOf(fn *ast.FuncDecl, declared map[string]ast.Expr) (*Signature, error) {
+	sig := &Signature{types: declared}
+	for _, field := range fn.Type.Params.List {
+		typ, variadic := field.Type, false
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			typ, variadic = &ast.ArrayType{Elt: ellipsis.Elt}, true
+		}
+		if len(field.Names) == 0 {
+			sig.Params = append(sig.Params, Param{Type: typ, Variadic: variadic})
+		}
+		for _, name := range field.Names {
+			sig.Params = append(sig.Params, Param{Name: name.Name, Type: typ, Variadic: variadic})
+		}
+	}
+
+	if results := fn.Type.Results; results != nil {
+		if len(results.List) != 1 || len(results.List[0].Names) > 1 {
+			return nil, fmt.Errorf("function %s must return a single value", fn.Name.Name)
+		}
+		sig.Result = results.List[0].Type
+	}
+
+	return sig, nil
+}
+
+// Transform the input JSON into typed Go arguments for the signature's parameters.
+// Keys are matched to parameters by name, falling back to their position.
+func FormatTypedArgs(input string, keyOrder []string, sig *Signature) (string, error) {
+	var args map[string]interface{}
+	if err := decodeJSON(input, &args); err != nil {
+		return "", fmt.Errorf("failed to parse input JSON: %w", err)
+	}
+	if len(keyOrder) != len(sig.Params) {
+		return "", fmt.Errorf("expected %d arguments, got %d", len(sig.Params), len(keyOrder))
+	}
+
+	params := make(map[string]Param)
+	for _, param := range sig.Params {
+		params[param.Name] = param
+	}
+
+	var formattedArgs []string
+	for i, key := range keyOrder {
+		value, exists := args[key]
+		if !exists {
+			return "", fmt.Errorf("missing key: %s", key)
+		}
+
+		param, ok := params[key]
+		if !ok {
+			param = sig.Params[i]
+		}
+
+		formattedValue, err := sig.literal(value, param.Type)
+		if err != nil {
+			return "", fmt.Errorf("error formatting key %s: %v", key, err)
+		}
+		if param.Variadic {
+			formattedValue += "..."
+		}
+		formattedArgs = append(formattedArgs, formattedValue)
+	}
+
+	return strings.Join(formattedArgs, ", "), nil
+}
+
+// Transform the expected output JSON into a typed declaration of the expected variable
+func FormatTypedExpectedOutput(output string, sig *Signature) (string, error) {
+	if sig.Result == nil {
+		return "", fmt.Errorf("function has no return value to compare")
+	}
+
+	var result map[string]interface{}
+	if err := decodeJSON(output, &result); err != nil {
+		return "", fmt.Errorf("failed to parse expected output JSON: %w", err)
+	}
+
+	for _, value := range result {
+		literal, err := sig.literal(value, sig.Result)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("var expected %s = %s", types.ExprString(sig.Result), literal), nil
+	}
+	return "", fmt.Errorf("no value found in expected output")
+}
+
+// Format a JSON value as a Go literal of the given type
+func (sig *Signature) literal(value interface{}, typ ast.Expr) (string, error) {
+	typeName := types.ExprString(typ)
+
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return sig.identLiteral(value, t.Name)
+	case *ast.ArrayType:
+		if value == nil && t.Len == nil {
+			return "nil", nil
+		}
+		if s, ok := value.(string); ok && isByteType(t.Elt) {
+			return fmt.Sprintf("%s(%q)", typeName, s), nil
+		}
+		array, ok := value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected array for %s, got %T", typeName, value)
+		}
+		elements := make([]string, len(array))
+		for i, elem := range array {
+			formatted, err := sig.literal(elem, t.Elt)
+			if err != nil {
+				return "", err
+			}
+			elements[i] = formatted
+		}
+		return fmt.Sprintf("%s{%s}", typeName, strings.Join(elements, ", ")), nil
+	case *ast.MapType:
+		if value == nil {
+			return "nil", nil
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected object for %s, got %T", typeName, value)
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		entries := make([]string, len(keys))
+		for i, key := range keys {
+			formattedKey, err := sig.mapKeyLiteral(key, t.Key)
+			if err != nil {
+				return "", err
+			}
+			formattedValue, err := sig.literal(object[key], t.Value)
+			if err != nil {
+				return "", err
+			}
+			entries[i] = formattedKey + ": " + formattedValue
+		}
+		return fmt.Sprintf("%s{%s}", typeName, strings.Join(entries, ", ")), nil
+	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok && isLinkedStructure(ident.Name) {
+			return structureLiteral(value, ident.Name)
+		}
+		if value == nil {
+			return "nil", nil
+		}
+		elem, err := sig.literal(value, t.X)
+		if err != nil {
+			return "", err
+		}
+		if _, isStruct := sig.underlying(t.X).(*ast.StructType); isStruct {
+			return "&" + elem, nil
+		}
+		return fmt.Sprintf("func() %s { var v %s = %s; return &v }()", typeName, types.ExprString(t.X), elem), nil
+	case *ast.StructType:
+		return sig.structLiteral(value, typeName, t)
+	case *ast.InterfaceType:
+		return formatValue(plainValue(value))
+	}
+	return "", fmt.Errorf("unsupported parameter type: %s", typeName)
+}
+
+// Format a JSON value as a literal of a predeclared or seed-declared named type
+func (sig *Signature) identLiteral(value interface{}, name string) (string, error) {
+	switch name {
+	case "int", "int8", "int16", "int64", "uint", "uint16", "uint32", "uint64", "uintptr":
+		return integerLiteral(value, name)
+	case "int32", "rune", "uint8", "byte":
+		// Characters may be written as single-character strings
+		if s, ok := value.(string); ok {
+			runes := []rune(s)
+			if len(runes) != 1 {
+				return "", fmt.Errorf("expected a single character for %s, got %q", name, s)
+			}
+			return strconv.QuoteRune(runes[0]), nil
+		}
+		return integerLiteral(value, name)
+	case "float32", "float64":
+		number, ok := value.(json.Number)
+		if !ok {
+			return "", fmt.Errorf("expected number for %s, got %T", name, value)
+		}
+		return number.String(), nil
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("expected string, got %T", value)
+		}
+		return strconv.Quote(s), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("expected bool, got %T", value)
+		}
+		return strconv.FormatBool(b), nil
+	case "any":
+		return formatValue(plainValue(value))
+	}
+
+	declared, ok := sig.types[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported parameter type: %s", name)
+	}
+	if structType, isStruct := declared.(*ast.StructType); isStruct {
+		return sig.structLiteral(value, name, structType)
+	}
+	literal, err := sig.literal(value, declared)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s(%s)", name, literal), nil
+}
+
+// Format a JSON object as a struct literal, matching keys to field names case-insensitively
+func (sig *Signature) structLiteral(value interface{}, typeName string, structType *ast.StructType) (string, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected object for %s, got %T", typeName, value)
+	}
+
+	var fields []string
+	for _, field := range structType.Fields.List {
+		for _, name := range field.Names {
+			fieldValue, found := lookupField(object, name.Name)
+			if !found {
+				continue
+			}
+			formatted, err := sig.literal(fieldValue, field.Type)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %w", name.Name, err)
+			}
+			fields = append(fields, name.Name+": "+formatted)
+		}
+	}
+	return fmt.Sprintf("%s{%s}", typeName, strings.Join(fields, ", ")), nil
+}
+
+// Format a JSON object key as a literal of the map's key type
+func (sig *Signature) mapKeyLiteral(key string, typ ast.Expr) (string, error) {
+	if ident, ok := typ.(*ast.Ident); ok && ident.Name != "string" {
+		var value interface{} = key
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			value = json.Number(key)
+		}
+		return sig.identLiteral(value, ident.Name)
+	}
+	return sig.literal(key, typ)
+}
+
+// Resolve a seed-declared type name to its definition
+func (sig *Signature) underlying(typ ast.Expr) ast.Expr {
+	if ident, ok := typ.(*ast.Ident); ok {
+		if declared, ok := sig.types[ident.Name]; ok {
+			return declared
+		}
+	}
+	return typ
+}
+
+// Find a JSON object value by field name, ignoring case
+func lookupField(object map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := object[name]; ok {
+		return value, true
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Format a JSON number as an integer literal, rejecting fractional values
+func integerLiteral(value interface{}, typeName string) (string, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return "", fmt.Errorf("expected number for %s, got %T", typeName, value)
+	}
+	if _, err := strconv.ParseInt(number.String(), 10, 64); err != nil {
+		if _, err := strconv.ParseUint(number.String(), 10, 64); err != nil {
+			return "", fmt.Errorf("expected integer for %s, got %s", typeName, number)
+		}
+	}
+	return number.String(), nil
+}
+
+// Decode JSON keeping numbers as json.Number, so large integers keep their precision
+func decodeJSON(data string, v interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Convert json.Number values back to float64 for the untyped formatter
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			values[i] = plainValue(elem)
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			values[key] = plainValue(elem)
+		}
+		return values
+	}
+	return value
+}
+
+// Report whether the type expression is byte or uint8
+func isByteType(typ ast.Expr) bool {
+	ident, ok := typ.(*ast.Ident)
+	return ok && (ident.Name == "byte" || ident.Name == "uint8")
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"regexp"
+	"testing"
+)
+
+var funcNamePattern = regexp.MustCompile(`func (\w+)\(`)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		seed     string
+		funcName string
+		params   []string
+		result   string
+		wantErr  bool
+	}{
+		{
+			name:     "Simple function",
+			seed:     "func TwoSum(nums []int, target int) []int {\n\n}",
+			funcName: "TwoSum",
+			params:   []string{"nums", "target"},
+			result:   "[]int",
+		},
+		{
+			name:     "Grouped parameters",
+			seed:     "func Sum(x, y int) int {\n\n}",
+			funcName: "Sum",
+			params:   []string{"x", "y"},
+			result:   "int",
+		},
+		{
+			name:     "Function missing from seed",
+			seed:     "func Sum(x, y int) int {\n\n}",
+			funcName: "Product",
+			wantErr:  true,
+		},
+		{
+			name:     "Multiple results",
+			seed:     "func Divide(x, y int) (int, error) {\n\n}",
+			funcName: "Divide",
+			wantErr:  true,
+		},
+		{
+			name:     "Invalid seed",
+			seed:     "func Sum(x, y int int {",
+			funcName: "Sum",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := ParseSignature(tt.seed, tt.funcName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(sig.Params) != len(tt.params) {
+				t.Fatalf("Expected %d params, got %d", len(tt.params), len(sig.Params))
+			}
+			for i, name := range tt.params {
+				if sig.Params[i].Name != name {
+					t.Errorf("Expected param %d to be %s, got %s", i, name, sig.Params[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatTypedArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		seed     string
+		input    string
+		keyOrder []string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "Empty float slice",
+			seed:     "func Average(values []float64) float64 {}",
+			input:    `{"values": []}`,
+			keyOrder: []string{"values"},
+			expected: "[]float64{}",
+		},
+		{
+			name:     "Whole floats stay float",
+			seed:     "func Average(values []float64) float64 {}",
+			input:    `{"values": [1.0, 2.5]}`,
+			keyOrder: []string{"values"},
+			expected: "[]float64{1.0, 2.5}",
+		},
+		{
+			name:     "Nested slices",
+			seed:     "func Rotate(matrix [][]int) [][]int {}",
+			input:    `{"matrix": [[1, 2], [3, 4]]}`,
+			keyOrder: []string{"matrix"},
+			expected: "[][]int{[]int{1, 2}, []int{3, 4}}",
+		},
+		{
+			name:     "String slice",
+			seed:     "func Join(words []string, sep string) string {}",
+			input:    `{"words": ["a", "b\"c"], "sep": ","}`,
+			keyOrder: []string{"words", "sep"},
+			expected: `[]string{"a", "b\"c"}, ","`,
+		},
+		{
+			name:     "Map",
+			seed:     "func Total(counts map[string]int) int {}",
+			input:    `{"counts": {"b": 2, "a": 1}}`,
+			keyOrder: []string{"counts"},
+			expected: `map[string]int{"a": 1, "b": 2}`,
+		},
+		{
+			name:     "Integer keyed map",
+			seed:     "func Total(counts map[int]bool) int {}",
+			input:    `{"counts": {"1": true}}`,
+			keyOrder: []string{"counts"},
+			expected: `map[int]bool{1: true}`,
+		},
+		{
+			name:     "Bytes, runes and int64",
+			seed:     "func Count(data []byte, r rune, limit int64) int {}",
+			input:    `{"data": "hello", "r": "l", "limit": 9007199254740993}`,
+			keyOrder: []string{"data", "r", "limit"},
+			expected: `[]byte("hello"), 'l', 9007199254740993`,
+		},
+		{
+			name:     "Seed-declared struct pointer",
+			seed:     "type Point struct {\n\tX, Y int\n}\n\nfunc Dist(p *Point) int {}",
+			input:    `{"p": {"x": 3, "y": 4}}`,
+			keyOrder: []string{"p"},
+			expected: "&Point{X: 3, Y: 4}",
+		},
+		{
+			name:     "Null slice",
+			seed:     "func Len(values []int) int {}",
+			input:    `{"values": null}`,
+			keyOrder: []string{"values"},
+			expected: "nil",
+		},
+		{
+			name:     "Positional fallback for renamed parameters",
+			seed:     "func Sum(a, b int) int {}",
+			input:    `{"x": 1, "y": 2}`,
+			keyOrder: []string{"x", "y"},
+			expected: "1, 2",
+		},
+		{
+			name:     "Variadic parameter",
+			seed:     "func Max(values ...int) int {}",
+			input:    `{"values": [1, 2]}`,
+			keyOrder: []string{"values"},
+			expected: "[]int{1, 2}...",
+		},
+		{
+			name:     "Fractional value for int",
+			seed:     "func Sum(x, y int) int {}",
+			input:    `{"x": 1.5, "y": 2}`,
+			keyOrder: []string{"x", "y"},
+			wantErr:  true,
+		},
+		{
+			name:     "Wrong argument count",
+			seed:     "func Sum(x, y int) int {}",
+			input:    `{"x": 1}`,
+			keyOrder: []string{"x"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := ParseSignature(tt.seed, funcNamePattern.FindStringSubmatch(tt.seed)[1])
+			if err != nil {
+				t.Fatalf("Failed to parse seed: %v", err)
+			}
+
+			result, err := FormatTypedArgs(tt.input, tt.keyOrder, sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatTypedExpectedOutput(t *testing.T) {
+	sig, err := ParseSignature("func Split(s string) []string {}", "Split")
+	if err != nil {
+		t.Fatalf("Failed to parse seed: %v", err)
+	}
+
+	result, err := FormatTypedExpectedOutput(`{"result": []}`, sig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "var expected []string = []string{}"; result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestProcessCodeTypedArguments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	submission := CodeSubmission{
+		Code: `func Average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}`,
+		Problem:     "Average",
+		ProblemSeed: "func Average(values []float64) float64 {\n\n}",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"values": []}`, InputOrder: `["values"]`, ExpectedOutput: `{"result": 0}`},
+			{ID: 2, Input: `{"values": [1.0, 2.0]}`, InputOrder: `["values"]`, ExpectedOutput: `{"result": 1.5}`},
+		},
+	}
+
+	output, err := processCode(submission)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.Verdict != VerdictAccepted {
+		t.Errorf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+	}
+}
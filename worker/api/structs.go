@@ -1,9 +1,22 @@
 package api
 
+// Problem types understood by the worker
+const (
+	ProblemTypeFunction = "function" // A single function called once per example
+	ProblemTypeDesign   = "design"   // A Constructor followed by a sequence of method calls
+	ProblemTypeGoTest   = "gotest"   // A problem-supplied _test.go file run with go test
+)
+
 type CodeSubmission struct {
 	Code            string           `json:"code"`
 	Problem         string           `json:"problem"`
+	ProblemSeed     string           `json:"problem_seed"`
+	ProblemType     string           `json:"problem_type"`
 	ProblemExamples []ProblemExample `json:"problem_examples"`
+	CompareMode     string           `json:"compare_mode"`
+	Tolerance       float64          `json:"tolerance"`
+	Checker         string           `json:"checker"`
+	TestFile        string           `json:"test_file"`
 }
 
 type ProblemExample struct {
@@ -11,14 +24,93 @@ type ProblemExample struct {
 	Input          string `json:"input"`
 	InputOrder     string `json:"input_order"`
 	ExpectedOutput string `json:"expected_output"`
+	Hidden         bool   `json:"is_hidden"`
 }
 
-// CodeOutput respresents the results of a test execution
+// Statuses of an individual test case
+const (
+	TestPassed = "PASSED"
+	TestFailed = "FAILED"
+	TestNotRun = "NOT_RUN"
+)
+
+// TestResult represents the outcome of running the solution against a single example
+type TestResult struct {
+	ID         int     `json:"id"`
+	Status     string  `json:"status"`
+	Input      string  `json:"input"`
+	Expected   string  `json:"expected"`
+	Actual     string  `json:"actual"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// CodeOutput respresents the results of a test execution.
+// Output holds the compiler output, or whatever the solution itself wrote to stdout/stderr.
 type CodeOutput struct {
-	TestCount  int    `json:"testCount"`
-	TestPassed int    `json:"testPassed"`
-	Output     string `json:"output"`
-	Input      string `json:"input"`
-	Expected   string `json:"expected"`
-	Result     string `json:"result"`
+	TestCount   int          `json:"testCount"`
+	TestPassed  int          `json:"testPassed"`
+	Output      string       `json:"output"`
+	Input       string       `json:"input"`
+	Expected    string       `json:"expected"`
+	Results     []TestResult `json:"results"`
+	Result      string       `json:"result"`
+	Verdict     string       `json:"verdict"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Stages a submission passes through while it is graded
+const (
+	StageCompiling = "COMPILING"
+	StageRunning   = "RUNNING"
+)
+
+// Progress reports how far grading a submission has got. Total is 0 when the
+// number of tests isn't known until they have run.
+type Progress struct {
+	Stage     string `json:"stage"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+// StreamEvent is one line of a streamed response: the submission's progress
+// while it is graded, then its result or an error
+type StreamEvent struct {
+	Progress *Progress    `json:"progress,omitempty"`
+	Result   *CodeOutput  `json:"result,omitempty"`
+	Error    *WorkerError `json:"error,omitempty"`
+}
+
+// Codes of the errors a submission can fail to be graded with
+const (
+	ErrorInvalidRequest = "INVALID_REQUEST" // The request body isn't a submission
+	ErrorUnauthorized   = "UNAUTHORIZED"    // The request isn't signed with the shared secret, or was sent before
+	ErrorInvalidProblem = "INVALID_PROBLEM" // The problem's data can't be graded, e.g. a seed without the named function
+	ErrorShuttingDown   = "SHUTTING_DOWN"   // The worker stopped before the submission finished
+	ErrorInternal       = "INTERNAL"        // The worker itself failed, e.g. to create a workspace
+)
+
+// WorkerError says why a submission couldn't be graded. A retryable error may
+// not happen again when the submission is sent to this or another worker.
+type WorkerError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *WorkerError) Error() string {
+	return e.Message
+}
+
+// ErrorResponse is the body of a response to a submission that couldn't be graded
+type ErrorResponse struct {
+	Error *WorkerError `json:"error"`
+}
+
+// Diagnostic represents a compiler or runtime error located in the user's code.
+// Line and Column are 1-based within the submitted code, or 0 when the error
+// falls outside it (e.g. a call from the harness that doesn't match the signature).
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
 }
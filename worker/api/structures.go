@@ -0,0 +1,205 @@
+package api
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// Linked structures built from LeetCode's level-order JSON notation
+var linkedStructures = []string{"ListNode", "TreeNode"}
+
+// Standard LeetCode definitions, injected unless the solution declares its own
+var structureDefinitions = map[string]string{
+	"ListNode": `
+		type ListNode struct {
+			Val  int
+			Next *ListNode
+		}
+`,
+	"TreeNode": `
+		type TreeNode struct {
+			Val   int
+			Left  *TreeNode
+			Right *TreeNode
+		}
+`,
+}
+
+// Builders and formatters for each linked structure, appended to harnesses that use them
+var structureHelpers = map[string]string{
+	"ListNode": `
+		// Build a linked list from its values
+		func leetgoBuildList(values []int) *ListNode {
+			dummy := &ListNode{}
+			tail := dummy
+			for _, value := range values {
+				tail.Next = &ListNode{Val: value}
+				tail = tail.Next
+			}
+			return dummy.Next
+		}
+
+		// Serialize a linked list into JSON array notation
+		func leetgoFormatList(value any) (string, bool) {
+			head, ok := value.(*ListNode)
+			if !ok {
+				return "", false
+			}
+			var values []string
+			for node, count := head, 0; node != nil; node, count = node.Next, count+1 {
+				if count == 10000 {
+					values = append(values, "...")
+					break
+				}
+				values = append(values, fmt.Sprint(node.Val))
+			}
+			return "[" + leetgoStrings.Join(values, ",") + "]", true
+		}
+`,
+	"TreeNode": `
+		// Build a binary tree from level-order values, where nil marks a missing node
+		func leetgoBuildTree(values []any) *TreeNode {
+			if len(values) == 0 || values[0] == nil {
+				return nil
+			}
+			root := &TreeNode{Val: values[0].(int)}
+			queue := []*TreeNode{root}
+			for i := 1; i < len(values) && len(queue) > 0; {
+				node := queue[0]
+				queue = queue[1:]
+				if values[i] != nil {
+					node.Left = &TreeNode{Val: values[i].(int)}
+					queue = append(queue, node.Left)
+				}
+				i++
+				if i < len(values) && values[i] != nil {
+					node.Right = &TreeNode{Val: values[i].(int)}
+					queue = append(queue, node.Right)
+				}
+				i++
+			}
+			return root
+		}
+
+		// Serialize a binary tree into level-order notation, trimming trailing nulls
+		func leetgoFormatTree(value any) (string, bool) {
+			root, ok := value.(*TreeNode)
+			if !ok {
+				return "", false
+			}
+			var values []string
+			queue := []*TreeNode{root}
+			for len(queue) > 0 && len(values) < 10000 {
+				node := queue[0]
+				queue = queue[1:]
+				if node == nil {
+					values = append(values, "null")
+					continue
+				}
+				values = append(values, fmt.Sprint(node.Val))
+				queue = append(queue, node.Left, node.Right)
+			}
+			for len(values) > 0 && values[len(values)-1] == "null" {
+				values = values[:len(values)-1]
+			}
+			return "[" + leetgoStrings.Join(values, ",") + "]", true
+		}
+`,
+}
+
+// Formatter each linked structure's helpers define
+var structureFormatters = map[string]string{
+	"ListNode": "leetgoFormatList",
+	"TreeNode": "leetgoFormatTree",
+}
+
+// Return the structure definitions and helpers a submission needs, if its
+// problem uses linked structures. Types the solution declares itself are not redefined.
+// The harness formats values through leetgoFormatStructure, which calls the
+// formatters directly so the solution has nothing it could replace them in.
+func generateStructureHelpers(submission CodeSubmission) string {
+	var code, formatters strings.Builder
+	for _, name := range linkedStructures {
+		if !strings.Contains(submission.ProblemSeed, name) {
+			continue
+		}
+		if !declaresType(submission.Code, name) {
+			code.WriteString(structureDefinitions[name])
+		}
+		code.WriteString(structureHelpers[name])
+		fmt.Fprintf(&formatters, `
+			if formatted, ok := %s(value); ok {
+				return formatted, true
+			}`, structureFormatters[name])
+	}
+	fmt.Fprintf(&code, `
+		// Format a value if it is one of the linked structures the harness defines
+		func leetgoFormatStructure(value any) (string, bool) {%s
+			return "", false
+		}
+`, formatters.String())
+	return code.String()
+}
+
+// Report whether the type name is one of the linked structures
+func isLinkedStructure(name string) bool {
+	for _, structure := range linkedStructures {
+		if structure == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether the code declares a type with the given name. Commented-out
+// definitions, as found in LeetCode seeds, don't count.
+func declaresType(code, name string) bool {
+	file, err := parser.ParseFile(token.NewFileSet(), "solution.go", "package main\n"+code, 0)
+	if err != nil {
+		// The solution won't compile anyway, so fall back to a textual check
+		return regexp.MustCompile(`(?m)^\s*type\s+` + name + `\b`).MatchString(code)
+	}
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range genDecl.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Format a level-order JSON array as a call building the named linked structure
+func structureLiteral(value interface{}, name string) (string, error) {
+	if value == nil {
+		return "nil", nil
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected array for *%s, got %T", name, value)
+	}
+
+	elements := make([]string, len(array))
+	for i, elem := range array {
+		if elem == nil && name == "TreeNode" {
+			elements[i] = "nil"
+			continue
+		}
+		formatted, err := integerLiteral(elem, name+" value")
+		if err != nil {
+			return "", err
+		}
+		elements[i] = formatted
+	}
+
+	if name == "ListNode" {
+		return fmt.Sprintf("leetgoBuildList([]int{%s})", strings.Join(elements, ", ")), nil
+	}
+	return fmt.Sprintf("leetgoBuildTree([]any{%s})", strings.Join(elements, ", ")), nil
+}
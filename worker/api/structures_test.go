@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStructureLiteral(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		structure string
+		expected  string
+		wantErr   bool
+	}{
+		{"List", []interface{}{json.Number("1"), json.Number("2")}, "ListNode", "leetgoBuildList([]int{1, 2})", false},
+		{"EmptyList", []interface{}{}, "ListNode", "leetgoBuildList([]int{})", false},
+		{"NilList", nil, "ListNode", "nil", false},
+		{"TreeWithGaps", []interface{}{json.Number("1"), nil, json.Number("2"), json.Number("3")}, "TreeNode", "leetgoBuildTree([]any{1, nil, 2, 3})", false},
+		{"NullInList", []interface{}{json.Number("1"), nil}, "ListNode", "", true},
+		{"NotAnArray", "1,2", "ListNode", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := structureLiteral(tt.value, tt.structure)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDeclaresType(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected bool
+	}{
+		{"Declared", "type ListNode struct {\n\tVal  int\n\tNext *ListNode\n}", true},
+		{"GroupedDeclaration", "type (\n\tListNode struct{ Val int; Next *ListNode }\n)", true},
+		{"CommentedOut", "/**\n * type ListNode struct {\n *     Val int\n * }\n */\nfunc Reverse(head *ListNode) *ListNode {\n\treturn head\n}", false},
+		{"NotDeclared", "func Reverse(head *ListNode) *ListNode {\n\treturn head\n}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := declaresType(tt.code, "ListNode"); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestProcessCodeLinkedStructures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	tests := []struct {
+		name       string
+		submission CodeSubmission
+	}{
+		{
+			name: "Reverse linked list",
+			submission: CodeSubmission{
+				Code: `/**
+ * Definition for singly-linked list.
+ * type ListNode struct {
+ *     Val int
+ *     Next *ListNode
+ * }
+ */
+func ReverseList(head *ListNode) *ListNode {
+	var prev *ListNode
+	for head != nil {
+		head.Next, prev, head = prev, head, head.Next
+	}
+	return prev
+}`,
+				Problem:     "ReverseList",
+				ProblemSeed: "func ReverseList(head *ListNode) *ListNode {\n\n}",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{"head": [1, 2, 3]}`, InputOrder: `["head"]`, ExpectedOutput: `{"result": [3, 2, 1]}`},
+					{ID: 2, Input: `{"head": []}`, InputOrder: `["head"]`, ExpectedOutput: `{"result": []}`},
+				},
+			},
+		},
+		{
+			name: "Invert binary tree with user-declared type",
+			submission: CodeSubmission{
+				Code: `type TreeNode struct {
+	Val   int
+	Left  *TreeNode
+	Right *TreeNode
+}
+
+func InvertTree(root *TreeNode) *TreeNode {
+	if root != nil {
+		root.Left, root.Right = InvertTree(root.Right), InvertTree(root.Left)
+	}
+	return root
+}`,
+				Problem:     "InvertTree",
+				ProblemSeed: "func InvertTree(root *TreeNode) *TreeNode {\n\n}",
+				ProblemExamples: []ProblemExample{
+					{ID: 1, Input: `{"root": [1, null, 2, 3]}`, InputOrder: `["root"]`, ExpectedOutput: `{"result": [1, 2, null, null, 3]}`},
+					{ID: 2, Input: `{"root": [4, 2, 7, 1, 3, 6, 9]}`, InputOrder: `["root"]`, ExpectedOutput: `{"result": [4, 7, 2, 9, 6, 3, 1]}`},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := processCode(tt.submission)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.Verdict != VerdictAccepted {
+				t.Errorf("Expected verdict %s, got %s: %+v", VerdictAccepted, output.Verdict, output)
+			}
+		})
+	}
+}
+
+func TestProcessCodeLinkedStructureOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping code execution in short mode")
+	}
+
+	submission := CodeSubmission{
+		Code:        "func Identity(head *ListNode) *ListNode {\n\treturn head\n}",
+		Problem:     "Identity",
+		ProblemSeed: "func Identity(head *ListNode) *ListNode {\n\n}",
+		ProblemExamples: []ProblemExample{
+			{ID: 1, Input: `{"head": [1, 2]}`, InputOrder: `["head"]`, ExpectedOutput: `{"result": [2, 1]}`},
+		},
+	}
+
+	output, err := processCode(submission)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(output.Results) != 1 || output.Results[0].Actual != "[1,2]" {
+		t.Errorf("Expected serialized list [1,2], got %+v", output.Results)
+	}
+}
@@ -90,12 +90,11 @@ func FormatExpectedOutput(output string) (string, error) {
 	return "", fmt.Errorf("no value found in expected output")
 }
 
-// Count the number of passing tests in the given output string.
-func CountPassingTests(output string) int {
+// Count the number of passing tests in the given results.
+func CountPassingTests(results []TestResult) int {
 	testPassed := 0
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "PASSED") {
+	for _, result := range results {
+		if result.Status == TestPassed {
 			testPassed++
 		}
 	}
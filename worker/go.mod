@@ -2,8 +2,4 @@ module github.com/smcgarril/leetgo-worker
 
 go 1.22.5
 
-require (
-	github.com/felixge/httpsnoop v1.0.3 // indirect
-	github.com/gorilla/handlers v1.5.2 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
-)
+require github.com/gorilla/mux v1.8.1
@@ -1,25 +1,90 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/smcgarril/leetgo-worker/api"
 )
 
 func main() {
+	// serve is the only command, and the default
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	}
+
+	config, args, err := api.LoadConfig("serve", args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unexpected arguments: %v (usage: leetgo-worker [serve] [flags])", args)
+	}
+	if config.Secret == "" {
+		log.Fatal("WORKER_SECRET must be set to the secret the server signs submissions with")
+	}
+	api.ExecutionLimits = config.Limits
+
+	// Submissions sharing the worker's user could read its secret from /proc
+	if err := api.ProtectProcess(); err != nil {
+		log.Fatal("Error protecting the worker's process: ", err)
+	}
+	if config.SandboxUID != 0 && os.Geteuid() != 0 {
+		log.Fatal("SANDBOX_UID requires the worker to run as root, to switch users")
+	}
+	if config.SandboxUID == 0 {
+		log.Printf("SANDBOX_UID is not set, submissions will run as the worker's user")
+	}
+	api.SandboxUID = config.SandboxUID
+
 	// Create router
 	router := mux.NewRouter()
 
 	// API routes
-	router.HandleFunc("/process-code", api.ProcessCodeHandler).Methods("POST")
+	// Submissions are only accepted from the server, which signs them. Browsers
+	// never call the worker, so it sends no CORS headers.
+	router.Handle("/process-code", api.RequireSignature(config.Secret, http.HandlerFunc(api.ProcessCodeHandler))).Methods("POST")
+	router.HandleFunc("/healthz", api.HealthHandler).Methods("GET")
+
+	server := &http.Server{
+		Addr:         config.HTTPAddr,
+		Handler:      http.MaxBytesHandler(router, config.MaxRequestBytes),
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+
+	// Stop on SIGINT or SIGTERM, which fly.io sends before replacing a machine
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Enable CORS for all origins (for development purposes)
-	corsHandler := handlers.CORS(handlers.AllowedOrigins([]string{"*"}))(router)
+	go func() {
+		log.Printf("Worker service running on %s", config.HTTPAddr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+	stop()
 
-	fmt.Println("Worker service running on port 8081")
-	log.Fatal(http.ListenAndServe(":8081", corsHandler))
+	// Stop accepting submissions and let the running ones finish, up to the deadline
+	log.Printf("Shutting down, waiting up to %s for running submissions", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Submissions still running after %s, killing them", config.ShutdownTimeout)
+		api.KillExecutions()
+		server.Close()
+	}
+	log.Printf("Worker service stopped")
 }